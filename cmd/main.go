@@ -9,6 +9,7 @@ import (
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/cors"
+	"github.com/gofiber/fiber/v3/middleware/recover"
 	"github.com/joho/godotenv"
 
 	_ "poker/docs" // Импорт сгенерированных Swagger документов
@@ -54,11 +55,28 @@ func main() {
 		log.Printf("Предупреждение: не удалось подключиться к Kafka: %v", err)
 	}
 
+	// Инициализируем рассылку событий столов по WebSocket до менеджера
+	// столов: новые столы получают задержку трансляции по умолчанию
+	services.InitTableHub()
+
 	// Инициализируем менеджер столов
 	services.InitTableManager()
 
+	// Инициализируем часы ходов до сессий и турниров: за игрока, не успевшего
+	// сходить, делается чек или фолд, в том числе в возобновленных раздачах
+	services.InitActionClock()
+
+	// Инициализируем сессии столов (непрерывные раздачи)
+	services.InitSessionManager()
+
+	// Инициализируем менеджер турниров
+	services.InitTournamentManager()
+
 	app := fiber.New()
 
+	// Паника в обработчике превращается в ответ 500, а не роняет сервер
+	app.Use(recover.New())
+
 	// CORS middleware
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "*",
@@ -99,6 +117,9 @@ func main() {
 	public.Get("/tables", handlers.GetTables)
 	public.Get("/tables/:id", handlers.GetTableByID)
 	public.Get("/tables/:id/players", handlers.GetTablePlayers)
+	public.Get("/tables/:id/spectate", handlers.SpectateTable)
+	public.Get("/tournaments", handlers.GetTournaments)
+	public.Get("/tournaments/:id", handlers.GetTournament)
 	
 	// Защищенные маршруты (требуют авторизации)
 	protected := api.Group("/", middleware.AuthMiddleware())
@@ -106,6 +127,7 @@ func main() {
 	// Пользователи
 	protected.Get("/profile", handlers.GetProfile)
 	protected.Put("/profile", handlers.UpdateProfile)
+	protected.Get("/profile/transactions", handlers.GetBalanceHistory)
 	protected.Get("/my-tables", handlers.GetMyTables)
 	
 	// Столы (действия требуют авторизации)
@@ -115,13 +137,36 @@ func main() {
 	protected.Get("/available-tables", handlers.GetAvailableTables)
 	protected.Get("/table-statistics", handlers.GetTableStatistics)
 	protected.Post("/cleanup-empty-tables", handlers.CleanupEmptyTables)
+	protected.Patch("/tables/:id", middleware.OperatorMiddleware(), handlers.UpdateTable)
 
 	// Игровые маршруты
 	protected.Post("/tables/:id/start-game", handlers.StartGame)
+	protected.Get("/tables/:id/seed", handlers.GetTableSeed)
+	protected.Post("/tables/:id/seed", handlers.SetTableClientSeed)
+	protected.Get("/tables/:id/ws", handlers.TableSocket)
 	protected.Get("/games/:gameId", handlers.GetGameState)
 	protected.Post("/games/:gameId/action", handlers.PlayerAction)
+	protected.Get("/games/:gameId/legal-actions", handlers.GetLegalActions)
+	protected.Get("/games/:gameId/fairness", handlers.GetGameFairness)
 	protected.Get("/games/:gameId/history", handlers.GetGameHistory)
+	protected.Get("/games/:gameId/hand-history", handlers.GetHandHistory)
+	protected.Get("/games/:gameId/replay", handlers.GetHandReplay)
 	protected.Get("/my-games", handlers.GetActiveGames)
+	protected.Get("/my-hand-history", handlers.GetMyHandHistory)
+
+	// Турниры
+	protected.Post("/tournaments", middleware.OperatorMiddleware(), handlers.CreateTournament)
+	protected.Get("/tournaments", handlers.GetTournaments)
+	protected.Get("/tournaments/:id", handlers.GetTournament)
+	protected.Post("/tournaments/:id/register", handlers.RegisterTournament)
+	protected.Post("/tournaments/:id/unregister", handlers.UnregisterTournament)
+	protected.Post("/tournaments/:id/rebuy", handlers.RebuyTournament)
+	protected.Post("/tournaments/:id/add-on", handlers.AddOnTournament)
+
+	// Инструменты
+	protected.Post("/tools/equity", handlers.CalculateEquity)
+	protected.Post("/tools/range-equity", handlers.CalculateRangeEquity)
+	protected.Post("/tools/hand-history/replay", handlers.ImportHandHistory)
 
 	// Маршруты с опциональной авторизацией
 	optional := api.Group("/", middleware.OptionalAuthMiddleware())
@@ -0,0 +1,234 @@
+// Package equity считает эквити рук в техасском холдеме: долю банка,
+// которую каждая рука выигрывает в среднем при всех вариантах доски.
+package equity
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"poker/game"
+	"poker/models"
+)
+
+var (
+	ErrTooFewHands   = errors.New("need at least 2 hands")
+	ErrTooManyHands  = errors.New("too many hands")
+	ErrInvalidHand   = errors.New("each hand must have exactly 2 cards")
+	ErrInvalidBoard  = errors.New("board must have 0, 3, 4 or 5 cards")
+	ErrInvalidCard   = errors.New("invalid card")
+	ErrDuplicateCard = errors.New("duplicate card")
+)
+
+const (
+	// DefaultExhaustiveLimit число вариантов доски, до которого перебор
+	// полный: префлоп один на один (1 712 304 доски) считается точно
+	DefaultExhaustiveLimit = 2_000_000
+	// DefaultIterations число симуляций Монте-Карло
+	DefaultIterations = 200_000
+
+	MaxHands = 10
+)
+
+// Options параметры расчета; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	ExhaustiveLimit int     // Максимум вариантов доски для полного перебора
+	Iterations      int     // Число симуляций, если перебор слишком долгий
+	Seed            *uint64 // Зерно Монте-Карло; nil — случайное для каждого расчета
+}
+
+// HandEquity результат одной руки
+type HandEquity struct {
+	Cards  []models.Card `json:"cards"`
+	Win    float64       `json:"win"`    // Доля единоличных побед
+	Tie    float64       `json:"tie"`    // Доля дележей банка
+	Equity float64       `json:"equity"` // Победы плюс доля банка при дележе
+}
+
+// Result эквити всех рук
+type Result struct {
+	Hands  []HandEquity `json:"hands"`
+	Boards int          `json:"boards"` // Сколько вариантов доски посчитано
+	Exact  bool         `json:"exact"`  // true — полный перебор, false — Монте-Карло
+}
+
+// Calculate считает эквити рук против частичной или пустой доски.
+// Если вариантов доски не больше ExhaustiveLimit, перебираются все,
+// иначе доска разыгрывается Iterations раз случайно.
+func Calculate(hands [][]models.Card, board []models.Card, opts Options) (*Result, error) {
+	if len(hands) < 2 {
+		return nil, ErrTooFewHands
+	}
+	if len(hands) > MaxHands {
+		return nil, ErrTooManyHands
+	}
+	if n := len(board); n != 0 && (n < 3 || n > 5) {
+		return nil, ErrInvalidBoard
+	}
+	if opts.ExhaustiveLimit <= 0 {
+		opts.ExhaustiveLimit = DefaultExhaustiveLimit
+	}
+	if opts.Iterations <= 0 {
+		opts.Iterations = DefaultIterations
+	}
+
+	var used game.CardMask
+	c := newCounter(len(hands))
+	for i, hand := range hands {
+		if len(hand) != 2 {
+			return nil, ErrInvalidHand
+		}
+		for _, card := range hand {
+			if err := addCard(&used, card); err != nil {
+				return nil, err
+			}
+		}
+		c.hands[i] = game.MaskOf(hand)
+	}
+	for _, card := range board {
+		if err := addCard(&used, card); err != nil {
+			return nil, err
+		}
+	}
+
+	// Оставшиеся в колоде карты
+	var deck []game.CardMask
+	for _, card := range game.NewOrderedDeck() {
+		if bit := game.CardBit(card); used&bit == 0 {
+			deck = append(deck, bit)
+		}
+	}
+
+	missing := 5 - len(board)
+	boardMask := game.MaskOf(board)
+	result := &Result{}
+
+	if combinations(len(deck), missing) <= opts.ExhaustiveLimit {
+		enumerate(deck, missing, boardMask, c)
+		result.Exact = true
+	} else {
+		rng := newRand(opts, uint64(len(deck)))
+		for i := 0; i < opts.Iterations; i++ {
+			// Частичная тасовка Фишера–Йетса: первые missing карт — случайная доска
+			runout := boardMask
+			for j := 0; j < missing; j++ {
+				k := j + rng.IntN(len(deck)-j)
+				deck[j], deck[k] = deck[k], deck[j]
+				runout |= deck[j]
+			}
+			c.add(runout)
+		}
+	}
+
+	result.Boards = c.boards
+	for i, hand := range hands {
+		total := float64(c.boards)
+		result.Hands = append(result.Hands, HandEquity{
+			Cards:  hand,
+			Win:    float64(c.wins[i]) / total,
+			Tie:    float64(c.ties[i]) / total,
+			Equity: (float64(c.wins[i]) + c.share[i]) / total,
+		})
+	}
+	return result, nil
+}
+
+// newRand генератор для Монте-Карло: с зерном из opts.Seed, если оно задано
+// (повторяемый результат), иначе со случайным зерном — общий генератор
+// math/rand/v2 засеивается случайно при старте процесса
+func newRand(opts Options, stream uint64) *rand.Rand {
+	seed := rand.Uint64()
+	if opts.Seed != nil {
+		seed = *opts.Seed
+	}
+	return rand.New(rand.NewPCG(seed, stream))
+}
+
+// addCard добавляет известную карту в маску, проверяя ее корректность и повторы
+func addCard(used *game.CardMask, card models.Card) error {
+	bit := game.CardBit(card)
+	if bit == 0 {
+		return fmt.Errorf("%w: %s %d", ErrInvalidCard, card.Suit, card.Value)
+	}
+	if *used&bit != 0 {
+		return fmt.Errorf("%w: %s %d", ErrDuplicateCard, card.Suit, card.Value)
+	}
+	*used |= bit
+	return nil
+}
+
+// counter накапливает исходы по вариантам доски
+type counter struct {
+	hands   []game.CardMask
+	wins    []int
+	ties    []int
+	share   []float64 // Сумма долей банка при дележе
+	boards  int
+	winners []int
+
+	// Итог последней доски для каждой руки
+	strengths []game.HandStrength
+	payoffs   []float64
+}
+
+func newCounter(hands int) *counter {
+	return &counter{
+		hands:     make([]game.CardMask, hands),
+		wins:      make([]int, hands),
+		ties:      make([]int, hands),
+		share:     make([]float64, hands),
+		strengths: make([]game.HandStrength, hands),
+		payoffs:   make([]float64, hands),
+	}
+}
+
+// add оценивает все руки на готовой доске
+func (c *counter) add(board game.CardMask) {
+	c.boards++
+	var best game.HandStrength
+	c.winners = c.winners[:0]
+	for i, hand := range c.hands {
+		strength := game.EvaluateMask(hand | board)
+		c.strengths[i] = strength
+		c.payoffs[i] = 0
+		switch {
+		case strength > best:
+			best = strength
+			c.winners = append(c.winners[:0], i)
+		case strength == best:
+			c.winners = append(c.winners, i)
+		}
+	}
+
+	if len(c.winners) == 1 {
+		c.wins[c.winners[0]]++
+		c.payoffs[c.winners[0]] = 1
+		return
+	}
+	share := 1 / float64(len(c.winners))
+	for _, i := range c.winners {
+		c.ties[i]++
+		c.share[i] += share
+		c.payoffs[i] = share
+	}
+}
+
+// enumerate перебирает все способы добавить к доске missing карт из колоды
+func enumerate(deck []game.CardMask, missing int, board game.CardMask, c *counter) {
+	if missing == 0 {
+		c.add(board)
+		return
+	}
+	for i := 0; i <= len(deck)-missing; i++ {
+		enumerate(deck[i+1:], missing-1, board|deck[i], c)
+	}
+}
+
+// combinations число сочетаний из n по k
+func combinations(n, k int) int {
+	result := 1
+	for i := 0; i < k; i++ {
+		result = result * (n - i) / (i + 1)
+	}
+	return result
+}
@@ -0,0 +1,190 @@
+package equity
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"poker/game"
+	"poker/models"
+)
+
+func parseHands(t *testing.T, notation ...string) [][]models.Card {
+	t.Helper()
+	hands := make([][]models.Card, len(notation))
+	for i, s := range notation {
+		cards, err := models.ParseCards(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hands[i] = cards
+	}
+	return hands
+}
+
+// TestCalculateExact сверяет полный перебор префлопа с известными значениями
+func TestCalculateExact(t *testing.T) {
+	tests := []struct {
+		name  string
+		hands []string
+		win   [2]float64
+		tie   float64
+	}{
+		{"AA vs KK, no shared suits", []string{"AhAs", "KdKc"}, [2]float64{0.8106, 0.1855}, 0.0038},
+		{"AA vs KK, shared suits", []string{"AhAs", "KhKc"}, [2]float64{0.8171, 0.1782}, 0.0046},
+		{"AKs vs QQ", []string{"AhKh", "QsQd"}, [2]float64{0.4602, 0.5359}, 0.0039},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Calculate(parseHands(t, tt.hands...), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Exact || result.Boards != 1712304 {
+				t.Fatalf("exact %v over %d boards, want exact over 1712304", result.Exact, result.Boards)
+			}
+			sum := 0.0
+			for i, hand := range result.Hands {
+				sum += hand.Equity
+				if math.Abs(hand.Win-tt.win[i]) > 0.0001 || math.Abs(hand.Tie-tt.tie) > 0.0001 {
+					t.Errorf("hand %d: win %.4f tie %.4f, want %.4f and %.4f", i, hand.Win, hand.Tie, tt.win[i], tt.tie)
+				}
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("equities add up to %f", sum)
+			}
+		})
+	}
+}
+
+// TestCalculateMonteCarlo проверяет, что симуляция близка к точному ответу,
+// без зерна дает разные выборки, а с зерном повторяется
+func TestCalculateMonteCarlo(t *testing.T) {
+	hands := parseHands(t, "AhAs", "KdKc")
+	exact, err := Calculate(hands, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(opts Options) *Result {
+		opts.ExhaustiveLimit, opts.Iterations = 1, 50_000
+		result, err := Calculate(hands, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Exact || result.Boards != opts.Iterations {
+			t.Fatalf("exact %v over %d boards, want Monte Carlo over %d", result.Exact, result.Boards, opts.Iterations)
+		}
+		for i, hand := range result.Hands {
+			if math.Abs(hand.Equity-exact.Hands[i].Equity) > 0.01 {
+				t.Errorf("hand %d: equity %.4f, exact %.4f", i, hand.Equity, exact.Hands[i].Equity)
+			}
+		}
+		return result
+	}
+
+	seed := uint64(42)
+	first, second := run(Options{Seed: &seed}), run(Options{Seed: &seed})
+	if first.Hands[0].Win != second.Hands[0].Win || first.Hands[0].Tie != second.Hands[0].Tie {
+		t.Errorf("same seed gave %+v and %+v", first.Hands[0], second.Hands[0])
+	}
+	// Совпасть случайно могут две выборки, но не три подряд
+	a, b, c := run(Options{}).Hands[0], run(Options{}).Hands[0], run(Options{}).Hands[0]
+	if a.Win == b.Win && b.Win == c.Win && a.Tie == b.Tie && b.Tie == c.Tie {
+		t.Error("unseeded runs gave identical samples")
+	}
+}
+
+func TestCalculateRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		hands []string
+		board string
+		want  error
+	}{
+		{"single hand", []string{"AhAs"}, "", ErrTooFewHands},
+		{"three-card hand", []string{"AhAsAd", "KdKc"}, "", ErrInvalidHand},
+		{"two-card board", []string{"AhAs", "KdKc"}, "2c3c", ErrInvalidBoard},
+		{"card in two hands", []string{"AhAs", "AhKc"}, "", ErrDuplicateCard},
+		{"hand card on the board", []string{"AhAs", "KdKc"}, "Kd7c2s", ErrDuplicateCard},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board, err := models.ParseCards(tt.board)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Calculate(parseHands(t, tt.hands...), board, Options{}); !errors.Is(err, tt.want) {
+				t.Errorf("error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestCalculateRangesMatchesHands диапазоны из одного комбо должны дать то же,
+// что и расчет по рукам
+func TestCalculateRangesMatchesHands(t *testing.T) {
+	board, err := models.ParseCards("Qh Ts 9d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hands, err := Calculate(parseHands(t, "AhAs", "KdKc"), board, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ranges [][]game.Combo
+	for _, notation := range []string{"AhAs", "KdKc"} {
+		combos, err := game.ParseRange(notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranges = append(ranges, combos)
+	}
+	result, err := CalculateRanges(ranges, board, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Exact || result.Boards != hands.Boards {
+		t.Fatalf("exact %v over %d boards, want exact over %d", result.Exact, result.Boards, hands.Boards)
+	}
+	for i, r := range result.Ranges {
+		if math.Abs(r.Equity-hands.Hands[i].Equity) > 1e-9 {
+			t.Errorf("range %d: equity %f, hand equity %f", i, r.Equity, hands.Hands[i].Equity)
+		}
+	}
+}
+
+func TestCalculateRangesRemovesBlockedCombos(t *testing.T) {
+	var ranges [][]game.Combo
+	for _, notation := range []string{"AA", "KK"} {
+		combos, err := game.ParseRange(notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranges = append(ranges, combos)
+	}
+	board, err := models.ParseCards("Ah Kd 2c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead, err := models.ParseCards("As")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := CalculateRanges(ranges, board, dead, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Без Ah и As у тузов остается одно комбо, у королей без Kd — три
+	if result.Ranges[0].Combos != 1 || result.Ranges[1].Combos != 3 {
+		t.Errorf("combos %d and %d, want 1 and 3", result.Ranges[0].Combos, result.Ranges[1].Combos)
+	}
+
+	if _, err := CalculateRanges(ranges, board, parseHands(t, "AdAc")[0], Options{}); !errors.Is(err, ErrEmptyRange) {
+		t.Errorf("error %v, want %v", err, ErrEmptyRange)
+	}
+}
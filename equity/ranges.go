@@ -0,0 +1,227 @@
+package equity
+
+import (
+	"errors"
+	"math/bits"
+
+	"poker/game"
+	"poker/models"
+)
+
+var (
+	ErrEmptyRange = errors.New("range has no combos left after card removal")
+	ErrNoDeal     = errors.New("ranges cannot be dealt without overlapping cards")
+)
+
+const (
+	// maxDealAttempts сколько раз Монте-Карло пытается раздать комбо без пересечений
+	maxDealAttempts = 1000
+
+	// fullDeck маска всех 52 карт
+	fullDeck game.CardMask = 0x1FFF1FFF1FFF1FFF
+)
+
+// CategoryEquity доля исходов, в которых диапазон собрал комбинацию,
+// и эквити диапазона в этих исходах
+type CategoryEquity struct {
+	Category  models.HandCategory `json:"category"`
+	Frequency float64             `json:"frequency"`
+	Equity    float64             `json:"equity"`
+}
+
+// RangeEquity результат одного диапазона
+type RangeEquity struct {
+	Combos     int              `json:"combos"` // Комбо после удаления заблокированных
+	Win        float64          `json:"win"`
+	Tie        float64          `json:"tie"`
+	Equity     float64          `json:"equity"`
+	Categories []CategoryEquity `json:"categories"` // От сильных комбинаций к слабым
+}
+
+// RangeResult эквити всех диапазонов
+type RangeResult struct {
+	Ranges []RangeEquity `json:"ranges"`
+	Boards int           `json:"boards"` // Сколько раздач посчитано
+	Exact  bool          `json:"exact"`
+}
+
+// CalculateRanges считает эквити диапазонов друг против друга.
+// Комбо с картами доски и мертвыми картами убираются заранее, комбо,
+// пересекающиеся между диапазонами, не раздаются вместе. Если раздач
+// (комбинаций комбо и досок) не больше ExhaustiveLimit, перебираются все.
+func CalculateRanges(ranges [][]game.Combo, board, dead []models.Card, opts Options) (*RangeResult, error) {
+	if len(ranges) < 2 {
+		return nil, ErrTooFewHands
+	}
+	if len(ranges) > MaxHands {
+		return nil, ErrTooManyHands
+	}
+	if n := len(board); n != 0 && (n < 3 || n > 5) {
+		return nil, ErrInvalidBoard
+	}
+	if opts.ExhaustiveLimit <= 0 {
+		opts.ExhaustiveLimit = DefaultExhaustiveLimit
+	}
+	if opts.Iterations <= 0 {
+		opts.Iterations = DefaultIterations
+	}
+
+	var known game.CardMask
+	for _, card := range board {
+		if err := addCard(&known, card); err != nil {
+			return nil, err
+		}
+	}
+	for _, card := range dead {
+		if err := addCard(&known, card); err != nil {
+			return nil, err
+		}
+	}
+
+	available := make([][]game.CardMask, len(ranges))
+	deals := 1
+	for i, combos := range ranges {
+		for _, combo := range game.RemoveBlocked(combos, known) {
+			if bits.OnesCount64(uint64(combo.Mask())) == 2 {
+				available[i] = append(available[i], combo.Mask())
+			}
+		}
+		if len(available[i]) == 0 {
+			return nil, ErrEmptyRange
+		}
+		deals = min(deals*len(available[i]), opts.ExhaustiveLimit+1)
+	}
+
+	missing := 5 - len(board)
+	boardMask := game.MaskOf(board)
+	remaining := 52 - len(board) - len(dead) - 2*len(ranges)
+	rc := &rangeCounter{counter: newCounter(len(ranges))}
+	for range ranges {
+		rc.categories = append(rc.categories, make([]categoryStat, len(game.HandCategories)))
+	}
+
+	result := &RangeResult{}
+	if deals*combinations(remaining, missing) <= opts.ExhaustiveLimit {
+		rc.enumerateDeals(available, 0, known, boardMask, missing)
+		result.Exact = true
+	} else if err := rc.simulate(available, known, boardMask, missing, opts); err != nil {
+		return nil, err
+	}
+
+	if rc.boards == 0 {
+		return nil, ErrNoDeal
+	}
+	result.Boards = rc.boards
+	total := float64(rc.boards)
+	for i := range ranges {
+		rangeEquity := RangeEquity{
+			Combos: len(available[i]),
+			Win:    float64(rc.wins[i]) / total,
+			Tie:    float64(rc.ties[i]) / total,
+			Equity: (float64(rc.wins[i]) + rc.share[i]) / total,
+		}
+		for j := len(game.HandCategories) - 1; j >= 1; j-- {
+			stat := rc.categories[i][j]
+			if stat.count == 0 {
+				continue
+			}
+			rangeEquity.Categories = append(rangeEquity.Categories, CategoryEquity{
+				Category:  game.HandCategories[j],
+				Frequency: float64(stat.count) / total,
+				Equity:    stat.payoff / float64(stat.count),
+			})
+		}
+		result.Ranges = append(result.Ranges, rangeEquity)
+	}
+	return result, nil
+}
+
+type categoryStat struct {
+	count  int
+	payoff float64
+}
+
+// rangeCounter дополнительно разбивает исходы по категориям комбинаций
+type rangeCounter struct {
+	*counter
+	categories [][]categoryStat
+}
+
+func (rc *rangeCounter) add(board game.CardMask) {
+	rc.counter.add(board)
+	for i, strength := range rc.strengths {
+		index := strength.Category()
+		if strength.HandCategory() == models.HandRoyalFlush {
+			index = 10
+		}
+		rc.categories[i][index].count++
+		rc.categories[i][index].payoff += rc.payoffs[i]
+	}
+}
+
+// enumerateDeals перебирает все раздачи комбо без пересечений, а для каждой — все доски
+func (rc *rangeCounter) enumerateDeals(ranges [][]game.CardMask, i int, used, board game.CardMask, missing int) {
+	if i == len(ranges) {
+		var deck []game.CardMask
+		for bit := game.CardMask(1); bit != 0; bit <<= 1 {
+			if bit&fullDeck != 0 && used&bit == 0 {
+				deck = append(deck, bit)
+			}
+		}
+		rc.enumerateBoards(deck, missing, board)
+		return
+	}
+	for _, combo := range ranges[i] {
+		if combo&used != 0 {
+			continue
+		}
+		rc.hands[i] = combo
+		rc.enumerateDeals(ranges, i+1, used|combo, board, missing)
+	}
+}
+
+func (rc *rangeCounter) enumerateBoards(deck []game.CardMask, missing int, board game.CardMask) {
+	if missing == 0 {
+		rc.add(board)
+		return
+	}
+	for i := 0; i <= len(deck)-missing; i++ {
+		rc.enumerateBoards(deck[i+1:], missing-1, board|deck[i])
+	}
+}
+
+// simulate разыгрывает случайные раздачи: комбо выбираются равномерно
+// с отбрасыванием пересекающихся, доска добирается случайными картами
+func (rc *rangeCounter) simulate(ranges [][]game.CardMask, known, board game.CardMask, missing int, opts Options) error {
+	rng := newRand(opts, uint64(len(ranges)))
+	for n := 0; n < opts.Iterations; n++ {
+		used, dealt := known, false
+		for attempt := 0; attempt < maxDealAttempts && !dealt; attempt++ {
+			used, dealt = known, true
+			for i, combos := range ranges {
+				combo := combos[rng.IntN(len(combos))]
+				if combo&used != 0 {
+					dealt = false
+					break
+				}
+				rc.hands[i] = combo
+				used |= combo
+			}
+		}
+		if !dealt {
+			return ErrNoDeal
+		}
+
+		runout := board
+		for drawn := 0; drawn < missing; {
+			bit := game.CardMask(1) << (16*rng.IntN(4) + rng.IntN(13))
+			if bit&used == 0 {
+				used |= bit
+				runout |= bit
+				drawn++
+			}
+		}
+		rc.add(runout)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package game
+
+import (
+	"poker/models"
+)
+
+// LegalActions возвращает допустимые действия игрока, который сейчас ходит,
+// или nil, если ходить некому. Правила совпадают с проверками ProcessAction.
+func (pe *PokerEngine) LegalActions() *models.LegalActions {
+	if !pe.IsBettingRound() || pe.IsRoundComplete() {
+		return nil
+	}
+
+	player := pe.playerAt(pe.game.CurrentPlayer)
+	if player == nil || !canAct(player) {
+		return nil
+	}
+
+	legal := &models.LegalActions{
+		UserUUID: player.UserUUID,
+		Position: player.Position,
+		CanFold:  true,
+	}
+
+	toCall := pe.game.CurrentBet - player.Bet
+	maxAmount := player.Bet + player.Chips
+
+	if toCall <= 0 {
+		legal.CanCheck = true
+	} else if toCall <= player.Chips {
+		legal.CanCall = true
+		legal.CallAmount = toCall
+	}
+
+	// Олл-ин больше текущей ставки возможен, только если торговля открыта
+	legal.CanAllIn = maxAmount <= pe.game.CurrentBet || pe.game.CurrentBet == 0 || !player.HasActed
+	if legal.CanAllIn {
+		legal.AllInAmount = maxAmount
+	}
+
+	switch {
+	case pe.game.CurrentBet == 0 && player.Chips > 0:
+		legal.CanBet = true
+		legal.MinAmount = min(pe.game.BigBlind, maxAmount)
+		legal.MaxAmount = maxAmount
+
+	case pe.game.CurrentBet > 0 && !player.HasActed && player.Chips > toCall:
+		legal.CanRaise = true
+		legal.MinAmount = min(pe.game.CurrentBet+pe.game.MinRaise, maxAmount)
+		legal.MaxAmount = maxAmount
+	}
+
+	return legal
+}
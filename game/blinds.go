@@ -0,0 +1,112 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+
+	"poker/models"
+)
+
+// MoveButton передвигает кнопку дилера к следующему игроку по часовой стрелке.
+// Для первой раздачи за столом DealerPosition должен быть -1.
+func (pe *PokerEngine) MoveButton() {
+	pe.sortPlayers()
+	pe.game.DealerPosition = pe.nextPosition(pe.game.DealerPosition, anyPlayer)
+}
+
+// StartHand начинает раздачу: ставит блайнды, раздает карты и передает ход
+// игроку после большого блайнда (UTG). Возвращает поставленные блайнды.
+func (pe *PokerEngine) StartHand() ([]models.GameAction, error) {
+	if len(pe.game.Players) < 2 {
+		return nil, fmt.Errorf("для раздачи нужно минимум 2 игрока")
+	}
+	pe.sortPlayers()
+
+	smallBlindPos, bigBlindPos := pe.BlindPositions()
+	blinds := []models.GameAction{
+		pe.postBlind(smallBlindPos, pe.game.SmallBlind, models.ActionPostSmallBlind),
+		pe.postBlind(bigBlindPos, pe.game.BigBlind, models.ActionPostBigBlind),
+	}
+
+	pe.game.State = models.GameStatePreFlop
+	pe.game.CurrentBet = pe.game.BigBlind
+	pe.game.MinRaise = pe.game.BigBlind
+	pe.DealCards()
+
+	// Префлоп первым ходит игрок после большого блайнда
+	pe.game.CurrentPlayer = pe.nextPosition(bigBlindPos, canAct)
+
+	return blinds, nil
+}
+
+// BlindPositions возвращает позиции малого и большого блайндов.
+// В игре один на один малый блайнд ставит дилер.
+func (pe *PokerEngine) BlindPositions() (int, int) {
+	smallBlindPos := pe.nextPosition(pe.game.DealerPosition, anyPlayer)
+	if len(pe.game.Players) == 2 {
+		smallBlindPos = pe.game.DealerPosition
+	}
+	return smallBlindPos, pe.nextPosition(smallBlindPos, anyPlayer)
+}
+
+// postBlind ставит блайнд; если фишек не хватает, игрок идет олл-ин
+func (pe *PokerEngine) postBlind(position, amount int, action models.PlayerAction) models.GameAction {
+	player := pe.playerAt(position)
+	if amount >= player.Chips {
+		amount = player.Chips
+		player.IsAllIn = true
+	}
+	pe.putChips(player, amount)
+
+	return models.GameAction{
+		GameID:   pe.game.ID,
+		UserUUID: player.UserUUID,
+		Action:   action,
+		Amount:   amount,
+		Street:   models.GameStatePreFlop,
+	}
+}
+
+// sortPlayers упорядочивает игроков по местам за столом
+func (pe *PokerEngine) sortPlayers() {
+	sort.SliceStable(pe.game.Players, func(i, j int) bool {
+		return pe.game.Players[i].Position < pe.game.Players[j].Position
+	})
+}
+
+// nextPosition возвращает позицию первого игрока после from по часовой
+// стрелке, удовлетворяющего условию, или -1. Игроки должны быть упорядочены.
+func (pe *PokerEngine) nextPosition(from int, ok func(*models.GamePlayer) bool) int {
+	players := pe.game.Players
+	start := 0
+	for start < len(players) && players[start].Position <= from {
+		start++
+	}
+
+	for i := 0; i < len(players); i++ {
+		player := &players[(start+i)%len(players)]
+		if ok(player) {
+			return player.Position
+		}
+	}
+	return -1
+}
+
+// playerAt возвращает игрока на указанной позиции
+func (pe *PokerEngine) playerAt(position int) *models.GamePlayer {
+	for i := range pe.game.Players {
+		if pe.game.Players[i].Position == position {
+			return &pe.game.Players[i]
+		}
+	}
+	return nil
+}
+
+func anyPlayer(*models.GamePlayer) bool {
+	return true
+}
+
+// canAct сообщает, может ли игрок еще делать ставки в раздаче
+func canAct(player *models.GamePlayer) bool {
+	return !player.IsFolded && !player.IsAllIn
+}
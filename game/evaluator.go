@@ -0,0 +1,200 @@
+package game
+
+import (
+	"math/bits"
+
+	"poker/models"
+)
+
+// HandStrength сравнимая сила лучшей пятикарточной комбинации: чем больше
+// значение, тем сильнее рука, равные значения — ничья.
+// Формат: категория << 20 | пять полубайтов со значениями карт (2-14)
+// в порядке значимости для сравнения.
+type HandStrength uint32
+
+// CardMask набор карт в виде битовой маски: бит suit*16 + (value-2)
+type CardMask uint64
+
+// Категории комбинаций в порядке силы
+const (
+	categoryHighCard = iota + 1
+	categoryPair
+	categoryTwoPair
+	categoryThreeOfAKind
+	categoryStraight
+	categoryFlush
+	categoryFullHouse
+	categoryFourOfAKind
+	categoryStraightFlush
+)
+
+const rankMask = 0x1FFF // 13 бит на масть
+
+// straightHigh старшая карта стрита для маски значений или 0, если стрита нет
+var straightHigh [1 << 13]uint8
+
+func init() {
+	for mask := 0; mask < len(straightHigh); mask++ {
+		for high := 12; high >= 4; high-- {
+			run := 0x1F << (high - 4)
+			if mask&run == run {
+				straightHigh[mask] = uint8(high + 2)
+				break
+			}
+		}
+		// Стрит от туза до пятерки
+		if straightHigh[mask] == 0 && mask&0x100F == 0x100F {
+			straightHigh[mask] = 5
+		}
+	}
+}
+
+// suitIndex возвращает номер масти в маске или -1
+func suitIndex(suit string) int {
+	switch suit {
+	case "hearts":
+		return 0
+	case "diamonds":
+		return 1
+	case "clubs":
+		return 2
+	case "spades":
+		return 3
+	}
+	return -1
+}
+
+// CardBit возвращает бит карты в маске
+func CardBit(card models.Card) CardMask {
+	suit := suitIndex(card.Suit)
+	if suit < 0 || card.Value < 2 || card.Value > 14 {
+		return 0
+	}
+	return CardMask(1) << (suit*16 + card.Value - 2)
+}
+
+// MaskOf собирает маску из карт
+func MaskOf(cards []models.Card) CardMask {
+	var mask CardMask
+	for _, card := range cards {
+		mask |= CardBit(card)
+	}
+	return mask
+}
+
+// Evaluate возвращает силу лучшей комбинации из 5, 6 или 7 карт.
+// Срез карт не изменяется.
+func Evaluate(cards []models.Card) HandStrength {
+	return EvaluateMask(MaskOf(cards))
+}
+
+// EvaluateMask возвращает силу лучшей комбинации для маски из 5-7 карт.
+// Не выделяет память и подходит для миллионов оценок в симуляциях.
+func EvaluateMask(mask CardMask) HandStrength {
+	var suits [4]uint16
+	for s := 0; s < 4; s++ {
+		suits[s] = uint16(mask>>(16*s)) & rankMask
+	}
+	ranks := suits[0] | suits[1] | suits[2] | suits[3]
+
+	// Флеш и стрит-флеш: при 7 картах флеш возможен только в одной масти
+	for _, suited := range suits {
+		if bits.OnesCount16(suited) >= 5 {
+			if high := straightHigh[suited]; high != 0 {
+				return category(categoryStraightFlush) | rankAt(int(high), 0)
+			}
+			return category(categoryFlush) | packRanks(suited, 5, 0)
+		}
+	}
+
+	// Маски значений по количеству карт
+	quads := suits[0] & suits[1] & suits[2] & suits[3]
+	pairsOrMore := (suits[0] & suits[1]) | (suits[0] & suits[2]) | (suits[0] & suits[3]) |
+		(suits[1] & suits[2]) | (suits[1] & suits[3]) | (suits[2] & suits[3])
+	tripsOrMore := (suits[0] & suits[1] & suits[2]) | (suits[0] & suits[1] & suits[3]) |
+		(suits[0] & suits[2] & suits[3]) | (suits[1] & suits[2] & suits[3])
+	trips := tripsOrMore &^ quads
+	pairs := pairsOrMore &^ tripsOrMore
+
+	if quads != 0 {
+		quad := topRank(quads)
+		return category(categoryFourOfAKind) | rankAt(quad, 0) | packRanks(ranks&^rankBit(quad), 1, 1)
+	}
+
+	if trips != 0 {
+		three := topRank(trips)
+		// Вторая тройка тоже годится как пара для фулл-хауса
+		if rest := (trips &^ rankBit(three)) | pairs; rest != 0 {
+			return category(categoryFullHouse) | rankAt(three, 0) | packRanks(rest, 1, 1)
+		}
+	}
+
+	if high := straightHigh[ranks]; high != 0 {
+		return category(categoryStraight) | rankAt(int(high), 0)
+	}
+
+	if trips != 0 {
+		three := topRank(trips)
+		return category(categoryThreeOfAKind) | rankAt(three, 0) | packRanks(ranks&^rankBit(three), 2, 1)
+	}
+
+	if bits.OnesCount16(pairs) >= 2 {
+		high := topRank(pairs)
+		low := topRank(pairs &^ rankBit(high))
+		kickers := ranks &^ rankBit(high) &^ rankBit(low)
+		return category(categoryTwoPair) | rankAt(high, 0) | rankAt(low, 1) | packRanks(kickers, 1, 2)
+	}
+
+	if pairs != 0 {
+		pair := topRank(pairs)
+		return category(categoryPair) | rankAt(pair, 0) | packRanks(ranks&^rankBit(pair), 3, 1)
+	}
+
+	return category(categoryHighCard) | packRanks(ranks, 5, 0)
+}
+
+// Category возвращает категорию комбинации (1 — старшая карта, 9 — стрит-флеш)
+func (hs HandStrength) Category() int {
+	return int(hs >> 20)
+}
+
+// Values возвращает значения карт, определяющие силу внутри категории
+func (hs HandStrength) Values() []int {
+	significant := [...]int{0, 5, 4, 3, 3, 1, 5, 2, 2, 1}[hs.Category()]
+	values := make([]int, 0, significant)
+	for i := 0; i < significant; i++ {
+		values = append(values, int(hs>>(16-4*i))&0xF)
+	}
+	return values
+}
+
+func category(c int) HandStrength {
+	return HandStrength(c) << 20
+}
+
+// rankAt помещает значение карты в полубайт pos (0 — самый значимый)
+func rankAt(value, pos int) HandStrength {
+	return HandStrength(value) << (16 - 4*pos)
+}
+
+// packRanks помещает n старших значений маски в полубайты, начиная с pos
+func packRanks(mask uint16, n, pos int) HandStrength {
+	var packed HandStrength
+	for ; mask != 0 && n > 0; n-- {
+		value := topRank(mask)
+		packed |= rankAt(value, pos)
+		mask &^= rankBit(value)
+		pos++
+	}
+	return packed
+}
+
+// rankBit бит значения карты (2-14) в маске масти
+func rankBit(value int) uint16 {
+	return 1 << (value - 2)
+}
+
+// topRank старшее значение карты в маске
+func topRank(mask uint16) int {
+	return bits.Len16(mask) + 1
+}
@@ -0,0 +1,188 @@
+package game
+
+import (
+	"sort"
+	"testing"
+
+	"poker/models"
+)
+
+// referenceKey простая оценка пяти карт для сверки: категория и значения
+// для сравнения внутри нее, упакованные так, что больший ключ — сильнее рука
+func referenceKey(hand []models.Card) uint64 {
+	counts := make(map[int]int)
+	flush := true
+	for _, card := range hand {
+		counts[card.Value]++
+		if card.Suit != hand[0].Suit {
+			flush = false
+		}
+	}
+
+	// Значения по убыванию количества, затем по убыванию значения
+	values := make([]int, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] > values[j]
+	})
+
+	straight := 0
+	if len(values) == 5 {
+		if values[0]-values[4] == 4 {
+			straight = values[0]
+		} else if values[0] == 14 && values[1] == 5 {
+			straight = 5
+		}
+	}
+
+	var cat int
+	switch {
+	case straight != 0 && flush:
+		cat, values = categoryStraightFlush, []int{straight}
+	case counts[values[0]] == 4:
+		cat = categoryFourOfAKind
+	case counts[values[0]] == 3 && counts[values[1]] == 2:
+		cat = categoryFullHouse
+	case flush:
+		cat = categoryFlush
+	case straight != 0:
+		cat, values = categoryStraight, []int{straight}
+	case counts[values[0]] == 3:
+		cat = categoryThreeOfAKind
+	case counts[values[0]] == 2 && counts[values[1]] == 2:
+		cat = categoryTwoPair
+	case counts[values[0]] == 2:
+		cat = categoryPair
+	default:
+		cat = categoryHighCard
+	}
+
+	key := uint64(cat)
+	for i := 0; i < 5; i++ {
+		key <<= 4
+		if i < len(values) {
+			key |= uint64(values[i])
+		}
+	}
+	return key
+}
+
+// TestEvaluateAllFiveCardHands перебирает все C(52,5) рук: число рук каждой
+// категории должно совпасть с известным, а порядок и ничьи — с простой
+// эталонной оценкой
+func TestEvaluateAllFiveCardHands(t *testing.T) {
+	deck := NewOrderedDeck()
+	counts := make(map[models.HandCategory]int)
+	strengths := make(map[uint64]HandStrength)
+	hand := make([]models.Card, 5)
+	total := 0
+
+	for a := 0; a < 52; a++ {
+		for b := a + 1; b < 52; b++ {
+			for c := b + 1; c < 52; c++ {
+				for d := c + 1; d < 52; d++ {
+					for e := d + 1; e < 52; e++ {
+						hand[0], hand[1], hand[2], hand[3], hand[4] = deck[a], deck[b], deck[c], deck[d], deck[e]
+						strength := Evaluate(hand)
+						counts[strength.HandCategory()]++
+						total++
+
+						key := referenceKey(hand)
+						if known, ok := strengths[key]; !ok {
+							strengths[key] = strength
+						} else if known != strength {
+							t.Fatalf("%v: strength %x, equal hand has %x", hand, strength, known)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if total != 2598960 {
+		t.Fatalf("enumerated %d hands, want 2598960", total)
+	}
+
+	want := map[models.HandCategory]int{
+		models.HandRoyalFlush:    4,
+		models.HandStraightFlush: 36,
+		models.HandFourOfAKind:   624,
+		models.HandFullHouse:     3744,
+		models.HandFlush:         5108,
+		models.HandStraight:      10200,
+		models.HandThreeOfAKind:  54912,
+		models.HandTwoPair:       123552,
+		models.HandPair:          1098240,
+		models.HandHighCard:      1302540,
+	}
+	for category, count := range want {
+		if counts[category] != count {
+			t.Errorf("%s: %d hands, want %d", category, counts[category], count)
+		}
+	}
+
+	// Разных по силе рук 7462; сила строго растет вместе с эталонным ключом
+	if len(strengths) != 7462 {
+		t.Errorf("%d distinct hand strengths, want 7462", len(strengths))
+	}
+	keys := make([]uint64, 0, len(strengths))
+	for key := range strengths {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for i := 1; i < len(keys); i++ {
+		if strengths[keys[i-1]] >= strengths[keys[i]] {
+			t.Fatalf("reference key %x ranks above %x, but strength %x >= %x",
+				keys[i], keys[i-1], strengths[keys[i-1]], strengths[keys[i]])
+		}
+	}
+}
+
+func TestEvaluateBestOfSeven(t *testing.T) {
+	tests := []struct {
+		name   string
+		cards  string
+		want   models.HandCategory
+		values []int
+	}{
+		{"wheel straight", "Ah 2d 3c 4s 5h Kd Kc", models.HandStraight, []int{5}},
+		{"flush beats straight", "2h 5h 9h Jh Kh Qd Tc", models.HandFlush, []int{13, 11, 9, 5, 2}},
+		{"two trips make a full house", "9h 9d 9c 4s 4h 4d Ac", models.HandFullHouse, []int{9, 4}},
+		{"three pairs use the best two", "Ah Ad Kc Ks 2h 2d 7c", models.HandTwoPair, []int{14, 13, 7}},
+		{"quads take the best kicker", "7h 7d 7c 7s 2h Ad Kc", models.HandFourOfAKind, []int{7, 14}},
+		{"royal flush", "Th Jh Qh Kh Ah 2c 3d", models.HandRoyalFlush, []int{14}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, err := models.ParseCards(tt.cards)
+			if err != nil {
+				t.Fatal(err)
+			}
+			strength := Evaluate(cards)
+			if got := strength.HandCategory(); got != tt.want {
+				t.Errorf("category %s, want %s", got, tt.want)
+			}
+			if got := strength.Values(); !equalInts(got, tt.values) {
+				t.Errorf("values %v, want %v", got, tt.values)
+			}
+		})
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
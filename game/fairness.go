@@ -0,0 +1,127 @@
+package game
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"poker/models"
+)
+
+// Честная тасовка по схеме commit-reveal:
+//  1. сервер генерирует секретное зерно и до раздачи публикует его SHA-256;
+//  2. только после этого игроки присылают свои зерна, из них складывается
+//     зерно клиента;
+//  3. колода однозначно получается из зерна сервера и зерна клиента;
+//  4. после раздачи сервер раскрывает зерно, и любой игрок может проверить
+//     хеш и повторить тасовку.
+//
+// Алгоритм тасовки: поток случайных чисел — блоки
+// HMAC-SHA256(key = hex-декодированное зерно сервера, msg = "<client_seed>:<n>"),
+// n = 0, 1, 2..., каждый блок дает 8 чисел uint32 (big-endian). Колода в
+// порядке NewOrderedDeck перемешивается Фишером–Йетсом: для i от 51 до 1
+// берется j = r mod (i+1), причем числа r >= 2^32 - 2^32 mod (i+1)
+// отбрасываются, чтобы распределение было равномерным.
+
+// NewServerSeed генерирует секретное зерно сервера (32 байта из crypto/rand в hex)
+func NewServerSeed() (string, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return "", fmt.Errorf("не удалось сгенерировать зерно: %w", err)
+	}
+	return hex.EncodeToString(seed), nil
+}
+
+// HashSeed возвращает публикуемый заранее хеш зерна сервера
+func HashSeed(serverSeed string) string {
+	sum := sha256.Sum256([]byte(serverSeed))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewOrderedDeck возвращает неперемешанную колоду: масти hearts, diamonds,
+// clubs, spades, внутри масти карты от двойки до туза
+func NewOrderedDeck() []models.Card {
+	suits := []string{"hearts", "diamonds", "clubs", "spades"}
+	ranks := []string{"2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K", "A"}
+	values := []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+
+	deck := make([]models.Card, 0, 52)
+	for _, suit := range suits {
+		for i, rank := range ranks {
+			deck = append(deck, models.Card{
+				Suit:  suit,
+				Rank:  rank,
+				Value: values[i],
+			})
+		}
+	}
+	return deck
+}
+
+// ShuffleDeck детерминированно перемешивает колоду по зерну сервера и клиента
+func ShuffleDeck(serverSeed, clientSeed string) ([]models.Card, error) {
+	key, err := hex.DecodeString(serverSeed)
+	if err != nil {
+		return nil, fmt.Errorf("некорректное зерно сервера: %w", err)
+	}
+
+	stream := &seedStream{key: key, clientSeed: clientSeed}
+	deck := NewOrderedDeck()
+	for i := len(deck) - 1; i > 0; i-- {
+		j := stream.intn(uint32(i + 1))
+		deck[i], deck[j] = deck[j], deck[i]
+	}
+	return deck, nil
+}
+
+// VerifyDeck проверяет, что раскрытое зерно совпадает с опубликованным хешем
+// и что колода получена из него и зерна клиента
+func VerifyDeck(serverSeed, seedHash, clientSeed string, deck []models.Card) bool {
+	if HashSeed(serverSeed) != seedHash {
+		return false
+	}
+
+	expected, err := ShuffleDeck(serverSeed, clientSeed)
+	if err != nil || len(expected) != len(deck) {
+		return false
+	}
+	for i := range deck {
+		if deck[i].Suit != expected[i].Suit || deck[i].Value != expected[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// seedStream поток чисел HMAC-SHA256 для тасовки
+type seedStream struct {
+	key        []byte
+	clientSeed string
+	counter    uint64
+	block      []byte
+}
+
+func (s *seedStream) next() uint32 {
+	if len(s.block) == 0 {
+		mac := hmac.New(sha256.New, s.key)
+		fmt.Fprintf(mac, "%s:%d", s.clientSeed, s.counter)
+		s.block = mac.Sum(nil)
+		s.counter++
+	}
+	value := binary.BigEndian.Uint32(s.block[:4])
+	s.block = s.block[4:]
+	return value
+}
+
+// intn возвращает равномерное число от 0 до n-1
+func (s *seedStream) intn(n uint32) int {
+	limit := ^uint32(0) - (^uint32(0)%n+1)%n
+	for {
+		if r := s.next(); r <= limit {
+			return int(r % n)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package game
+
+import (
+	"testing"
+
+	"poker/models"
+)
+
+const testServerSeed = "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"
+
+func deckNotation(deck []models.Card) string {
+	s := ""
+	for _, card := range deck {
+		s += card.String()
+	}
+	return s
+}
+
+func mustShuffle(t *testing.T, serverSeed, clientSeed string) []models.Card {
+	t.Helper()
+	deck, err := ShuffleDeck(serverSeed, clientSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deck
+}
+
+func TestHashSeed(t *testing.T) {
+	// SHA-256 от "abc" из FIPS 180-2
+	if got := HashSeed("abc"); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("HashSeed(abc) = %s", got)
+	}
+	if HashSeed(testServerSeed) == HashSeed(testServerSeed[:62]+"00") {
+		t.Error("different seeds share a hash")
+	}
+}
+
+func TestShuffleDeckDeterministic(t *testing.T) {
+	first := mustShuffle(t, testServerSeed, "alice:bob")
+	second := mustShuffle(t, testServerSeed, "alice:bob")
+	if deckNotation(first) != deckNotation(second) {
+		t.Errorf("same seeds gave different decks:\n%s\n%s", deckNotation(first), deckNotation(second))
+	}
+
+	// Алгоритм тасовки описан для игроков, и зафиксированная колода не
+	// должна меняться от версии к версии
+	if got := deckNotation(first[:10]); got != "4c3cKd9cJc6d4d7d7h8c" {
+		t.Errorf("first ten cards %s, want 4c3cKd9cJc6d4d7d7h8c", got)
+	}
+}
+
+func TestShuffleDeckDependsOnSeeds(t *testing.T) {
+	base := deckNotation(mustShuffle(t, testServerSeed, "alice:bob"))
+	tests := []struct {
+		name       string
+		serverSeed string
+		clientSeed string
+	}{
+		{"other client seed", testServerSeed, "alice:carol"},
+		{"client seed order", testServerSeed, "bob:alice"},
+		{"empty client seed", testServerSeed, ""},
+		{"other server seed", "ff" + testServerSeed[2:], "alice:bob"},
+	}
+	for _, tt := range tests {
+		if got := deckNotation(mustShuffle(t, tt.serverSeed, tt.clientSeed)); got == base {
+			t.Errorf("%s: deck did not change", tt.name)
+		}
+	}
+}
+
+func TestShuffleDeckIsPermutation(t *testing.T) {
+	ordered := make(map[models.Card]bool)
+	for _, card := range NewOrderedDeck() {
+		ordered[card] = true
+	}
+	if len(ordered) != 52 {
+		t.Fatalf("ordered deck has %d distinct cards", len(ordered))
+	}
+
+	for _, clientSeed := range []string{"", "a", "alice:bob", "0123456789"} {
+		deck := mustShuffle(t, testServerSeed, clientSeed)
+		if len(deck) != 52 {
+			t.Fatalf("client seed %q: %d cards", clientSeed, len(deck))
+		}
+		seen := make(map[models.Card]bool)
+		for _, card := range deck {
+			if !ordered[card] || seen[card] {
+				t.Fatalf("client seed %q: unexpected or repeated card %s", clientSeed, card)
+			}
+			seen[card] = true
+		}
+	}
+}
+
+func TestShuffleDeckRejectsBadServerSeed(t *testing.T) {
+	if _, err := ShuffleDeck("not hex", "alice"); err == nil {
+		t.Error("non-hex server seed accepted")
+	}
+}
+
+func TestVerifyDeck(t *testing.T) {
+	deck := mustShuffle(t, testServerSeed, "alice:bob")
+	seedHash := HashSeed(testServerSeed)
+
+	swapped := append([]models.Card(nil), deck...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+
+	otherSeed := "ff" + testServerSeed[2:]
+	tests := []struct {
+		name       string
+		serverSeed string
+		seedHash   string
+		clientSeed string
+		deck       []models.Card
+		want       bool
+	}{
+		{"honest deck", testServerSeed, seedHash, "alice:bob", deck, true},
+		{"seed does not match the published hash", otherSeed, seedHash, "alice:bob", mustShuffle(t, otherSeed, "alice:bob"), false},
+		{"other client seed", testServerSeed, seedHash, "alice:carol", deck, false},
+		{"cards swapped", testServerSeed, seedHash, "alice:bob", swapped, false},
+		{"card missing", testServerSeed, seedHash, "alice:bob", deck[:51], false},
+		{"bad server seed", "not hex", HashSeed("not hex"), "alice:bob", deck, false},
+	}
+	for _, tt := range tests {
+		if got := VerifyDeck(tt.serverSeed, tt.seedHash, tt.clientSeed, tt.deck); got != tt.want {
+			t.Errorf("%s: VerifyDeck = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package game
+
+import (
+	"fmt"
+
+	"poker/models"
+)
+
+// HandCategories категории комбинаций по номеру ранга HandRank.Rank
+var HandCategories = [...]models.HandCategory{
+	1:  models.HandHighCard,
+	2:  models.HandPair,
+	3:  models.HandTwoPair,
+	4:  models.HandThreeOfAKind,
+	5:  models.HandStraight,
+	6:  models.HandFlush,
+	7:  models.HandFullHouse,
+	8:  models.HandFourOfAKind,
+	9:  models.HandStraightFlush,
+	10: models.HandRoyalFlush,
+}
+
+// HandCategory возвращает категорию комбинации; стрит-флеш до туза — роял-флеш
+func (hs HandStrength) HandCategory() models.HandCategory {
+	if hs.Category() == categoryStraightFlush && hs.Values()[0] == 14 {
+		return models.HandRoyalFlush
+	}
+	return HandCategories[hs.Category()]
+}
+
+// groupSizes сколько карт каждого значения из Values() входит в комбинацию
+var groupSizes = [...][]int{
+	categoryHighCard:     {1, 1, 1, 1, 1},
+	categoryPair:         {2, 1, 1, 1},
+	categoryTwoPair:      {2, 2, 1},
+	categoryThreeOfAKind: {3, 1, 1},
+	categoryFullHouse:    {3, 2},
+	categoryFourOfAKind:  {4, 1},
+}
+
+// bestFive выбирает из карт пять, составляющих комбинацию, в порядке значимости
+func bestFive(cards []models.Card, strength HandStrength) []models.Card {
+	values := strength.Values()
+	switch strength.Category() {
+	case categoryStraight, categoryStraightFlush, categoryFlush:
+		suit := ""
+		if strength.Category() != categoryStraight {
+			suit = flushSuit(cards)
+		}
+		if strength.Category() != categoryFlush {
+			values = straightValues(values[0])
+		}
+		five := make([]models.Card, 0, 5)
+		for _, value := range values {
+			five = append(five, takeCards(cards, value, suit, 1, five)...)
+		}
+		return five
+	}
+
+	five := make([]models.Card, 0, 5)
+	for i, size := range groupSizes[strength.Category()] {
+		five = append(five, takeCards(cards, values[i], "", size, five)...)
+	}
+	return five
+}
+
+// takeCards берет n карт значения value (и масти suit, если она задана),
+// которых еще нет среди выбранных
+func takeCards(cards []models.Card, value int, suit string, n int, chosen []models.Card) []models.Card {
+	taken := make([]models.Card, 0, n)
+	for _, card := range cards {
+		if len(taken) == n {
+			break
+		}
+		if card.Value != value || (suit != "" && card.Suit != suit) || containsCard(chosen, card) || containsCard(taken, card) {
+			continue
+		}
+		taken = append(taken, card)
+	}
+	return taken
+}
+
+func containsCard(cards []models.Card, card models.Card) bool {
+	for _, c := range cards {
+		if c.Suit == card.Suit && c.Value == card.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// flushSuit масть, в которой набралось пять карт
+func flushSuit(cards []models.Card) string {
+	counts := make(map[string]int)
+	for _, card := range cards {
+		counts[card.Suit]++
+		if counts[card.Suit] >= 5 {
+			return card.Suit
+		}
+	}
+	return ""
+}
+
+// straightValues значения карт стрита от старшей; стрит до пятерки начинается с пятерки
+func straightValues(high int) []int {
+	if high == 5 {
+		return []int{5, 4, 3, 2, 14}
+	}
+	return []int{high, high - 1, high - 2, high - 3, high - 4}
+}
+
+// Названия значений карт от двойки до туза
+var (
+	valueNamesEn  = [...]string{2: "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten", "Jack", "Queen", "King", "Ace"}
+	pluralNamesEn = [...]string{2: "Twos", "Threes", "Fours", "Fives", "Sixes", "Sevens", "Eights", "Nines", "Tens", "Jacks", "Queens", "Kings", "Aces"}
+
+	// Именительный падеж: "туз"
+	valueNamesRu = [...]string{2: "двойка", "тройка", "четверка", "пятерка", "шестерка", "семерка", "восьмерка", "девятка", "десятка", "валет", "дама", "король", "туз"}
+	// Родительный падеж: "до туза"
+	genitiveNamesRu = [...]string{2: "двойки", "тройки", "четверки", "пятерки", "шестерки", "семерки", "восьмерки", "девятки", "десятки", "валета", "дамы", "короля", "туза"}
+	// Именительный падеж множественного числа: "тузы и короли"
+	nominativePluralRu = [...]string{2: "двойки", "тройки", "четверки", "пятерки", "шестерки", "семерки", "восьмерки", "девятки", "десятки", "валеты", "дамы", "короли", "тузы"}
+	// Родительный падеж множественного числа: "пара тузов"
+	pluralNamesRu = [...]string{2: "двоек", "троек", "четверок", "пятерок", "шестерок", "семерок", "восьмерок", "девяток", "десяток", "валетов", "дам", "королей", "тузов"}
+)
+
+// Describe возвращает описание комбинации на языке lang ("ru" или "en").
+// Для неизвестного языка используется русский.
+func (hr HandRank) Describe(lang string) string {
+	if hr.Rank < 1 || hr.Rank >= len(HandCategories) || len(hr.Kickers) == 0 {
+		return ""
+	}
+	if lang == "en" {
+		return hr.describeEn()
+	}
+	return hr.describeRu()
+}
+
+func (hr HandRank) describeEn() string {
+	k := hr.Kickers
+	switch hr.Rank {
+	case 10:
+		return "Royal Flush"
+	case 9:
+		return fmt.Sprintf("Straight Flush, %s high", valueNamesEn[k[0]])
+	case 8:
+		return fmt.Sprintf("Four of a Kind, %s", pluralNamesEn[k[0]])
+	case 7:
+		return fmt.Sprintf("Full House, %s full of %s", pluralNamesEn[k[0]], pluralNamesEn[k[1]])
+	case 6:
+		return fmt.Sprintf("Flush, %s high", valueNamesEn[k[0]])
+	case 5:
+		return fmt.Sprintf("Straight, %s high", valueNamesEn[k[0]])
+	case 4:
+		return fmt.Sprintf("Three of a Kind, %s", pluralNamesEn[k[0]])
+	case 3:
+		return fmt.Sprintf("Two Pair, %s and %s", pluralNamesEn[k[0]], pluralNamesEn[k[1]])
+	case 2:
+		return fmt.Sprintf("Pair of %s", pluralNamesEn[k[0]])
+	}
+	return fmt.Sprintf("High Card, %s", valueNamesEn[k[0]])
+}
+
+func (hr HandRank) describeRu() string {
+	k := hr.Kickers
+	switch hr.Rank {
+	case 10:
+		return "Роял-флеш"
+	case 9:
+		return fmt.Sprintf("Стрит-флеш до %s", genitiveNamesRu[k[0]])
+	case 8:
+		return fmt.Sprintf("Каре %s", pluralNamesRu[k[0]])
+	case 7:
+		return fmt.Sprintf("Фулл-хаус из %s и %s", pluralNamesRu[k[0]], pluralNamesRu[k[1]])
+	case 6:
+		return fmt.Sprintf("Флеш до %s", genitiveNamesRu[k[0]])
+	case 5:
+		return fmt.Sprintf("Стрит до %s", genitiveNamesRu[k[0]])
+	case 4:
+		return fmt.Sprintf("Тройка %s", pluralNamesRu[k[0]])
+	case 3:
+		return fmt.Sprintf("Две пары: %s и %s", nominativePluralRu[k[0]], nominativePluralRu[k[1]])
+	case 2:
+		return fmt.Sprintf("Пара %s", pluralNamesRu[k[0]])
+	}
+	return fmt.Sprintf("Старшая карта: %s", valueNamesRu[k[0]])
+}
+
+// Result возвращает комбинацию игрока для итога вскрытия
+func (hr HandRank) Result(userUUID string) models.HandResult {
+	return models.HandResult{
+		UserUUID: userUUID,
+		Category: hr.Category,
+		Rank:     hr.Rank,
+		Cards:    hr.Cards,
+		Description: map[string]string{
+			"ru": hr.Describe("ru"),
+			"en": hr.Describe("en"),
+		},
+	}
+}
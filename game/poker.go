@@ -2,9 +2,6 @@ package game
 
 import (
 	"fmt"
-	"math/rand"
-	"sort"
-	"time"
 
 	"poker/models"
 )
@@ -19,42 +16,39 @@ func NewPokerEngine(game *models.Game) *PokerEngine {
 	return &PokerEngine{game: game}
 }
 
-// CreateDeck создает новую колоду карт
+// CreateDeck создает новую колоду, перемешанную со случайным зерном из crypto/rand.
+// Для раздач используется ShuffleDeck, чтобы колоду можно было проверить.
 func CreateDeck() []models.Card {
-	suits := []string{"hearts", "diamonds", "clubs", "spades"}
-	ranks := []string{"2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K", "A"}
-	values := []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
-
-	var deck []models.Card
-	for _, suit := range suits {
-		for i, rank := range ranks {
-			deck = append(deck, models.Card{
-				Suit:  suit,
-				Rank:  rank,
-				Value: values[i],
-			})
-		}
+	serverSeed, err := NewServerSeed()
+	if err != nil {
+		panic(err)
 	}
 
-	// Перемешиваем колоду
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(deck), func(i, j int) {
-		deck[i], deck[j] = deck[j], deck[i]
-	})
-
+	deck, err := ShuffleDeck(serverSeed, "")
+	if err != nil {
+		panic(err)
+	}
 	return deck
 }
 
-// DealCards раздает карты игрокам
+// DealCards раздает карты игрокам по одной, начиная слева от дилера
 func (pe *PokerEngine) DealCards() {
+	var order []*models.GamePlayer
+	first := pe.nextPosition(pe.game.DealerPosition, anyPlayer)
+	for i := 0; i < len(pe.game.Players); i++ {
+		player := pe.playerAt(first)
+		first = pe.nextPosition(first, anyPlayer)
+		if !player.IsFolded {
+			player.Cards = []models.Card{}
+			order = append(order, player)
+		}
+	}
+
 	// Каждому игроку по 2 карты
-	for i := range pe.game.Players {
-		if !pe.game.Players[i].IsFolded {
-			pe.game.Players[i].Cards = []models.Card{
-				pe.game.Deck[0],
-				pe.game.Deck[1],
-			}
-			pe.game.Deck = pe.game.Deck[2:]
+	for round := 0; round < 2; round++ {
+		for _, player := range order {
+			player.Cards = append(player.Cards, pe.game.Deck[0])
+			pe.game.Deck = pe.game.Deck[1:]
 		}
 	}
 }
@@ -90,27 +84,13 @@ func (pe *PokerEngine) DealRiver() {
 	pe.game.Deck = pe.game.Deck[1:]
 }
 
-// GetNextPlayer возвращает следующего активного игрока
+// GetNextPlayer возвращает позицию следующего игрока, который может
+// делать ставки (не сбросил карты и не в олл-ине), или -1
 func (pe *PokerEngine) GetNextPlayer() int {
-	activePlayers := pe.GetActivePlayers()
-	if len(activePlayers) <= 1 {
+	if len(pe.GetActivePlayers()) <= 1 {
 		return -1
 	}
-
-	currentIndex := -1
-	for i, player := range activePlayers {
-		if player.Position == pe.game.CurrentPlayer {
-			currentIndex = i
-			break
-		}
-	}
-
-	if currentIndex == -1 {
-		return activePlayers[0].Position
-	}
-
-	nextIndex := (currentIndex + 1) % len(activePlayers)
-	return activePlayers[nextIndex].Position
+	return pe.nextPosition(pe.game.CurrentPlayer, canAct)
 }
 
 // GetActivePlayers возвращает активных игроков (не сфолдивших)
@@ -126,6 +106,9 @@ func (pe *PokerEngine) GetActivePlayers() []models.GamePlayer {
 
 // CanPlayerAct проверяет, может ли игрок действовать
 func (pe *PokerEngine) CanPlayerAct(userUUID string) bool {
+	if !pe.IsBettingRound() {
+		return false
+	}
 	for _, player := range pe.game.Players {
 		if player.UserUUID == userUUID && player.Position == pe.game.CurrentPlayer {
 			return !player.IsFolded && !player.IsAllIn
@@ -162,27 +145,29 @@ func (pe *PokerEngine) ProcessAction(userUUID string, action models.PlayerAction
 	case models.ActionCall:
 		callAmount := pe.game.CurrentBet - player.Bet
 		if callAmount > player.Chips {
-			callAmount = player.Chips
-			player.IsAllIn = true
+			return fmt.Errorf("недостаточно фишек для колла %d, доступен только олл-ин на %d", callAmount, player.Chips)
 		}
-		player.Chips -= callAmount
-		player.Bet += callAmount
-		pe.game.Pot += callAmount
+		pe.putChips(player, callAmount)
 		player.LastAction = models.ActionCall
 
 	case models.ActionRaise:
-		if amount < pe.game.CurrentBet*2 {
-			return fmt.Errorf("размер рейза слишком мал")
+		if pe.game.CurrentBet == 0 {
+			return fmt.Errorf("нельзя рейзить без ставки")
+		}
+		if player.HasActed {
+			return fmt.Errorf("торговля не переоткрыта, можно только уравнять или сбросить")
 		}
-		raiseAmount := amount - player.Bet
-		if raiseAmount > player.Chips {
-			raiseAmount = player.Chips
-			player.IsAllIn = true
+		if amount-player.Bet > player.Chips {
+			return fmt.Errorf("рейз до %d превышает стек, максимум %d", amount, player.Bet+player.Chips)
 		}
-		player.Chips -= raiseAmount
-		player.Bet += raiseAmount
-		pe.game.Pot += raiseAmount
-		pe.game.CurrentBet = player.Bet
+		if amount <= pe.game.CurrentBet {
+			return fmt.Errorf("рейз должен превышать текущую ставку %d", pe.game.CurrentBet)
+		}
+		if amount-player.Bet < player.Chips && amount-pe.game.CurrentBet < pe.game.MinRaise {
+			return fmt.Errorf("минимальный рейз до %d", pe.game.CurrentBet+pe.game.MinRaise)
+		}
+		pe.putChips(player, amount-player.Bet)
+		pe.raiseTo(player)
 		player.LastAction = models.ActionRaise
 
 	case models.ActionCheck:
@@ -196,32 +181,103 @@ func (pe *PokerEngine) ProcessAction(userUUID string, action models.PlayerAction
 			return fmt.Errorf("нельзя ставить, уже есть ставка")
 		}
 		if amount > player.Chips {
-			amount = player.Chips
-			player.IsAllIn = true
+			return fmt.Errorf("ставка %d превышает стек %d", amount, player.Chips)
+		}
+		if amount < player.Chips && amount < pe.game.BigBlind {
+			return fmt.Errorf("минимальная ставка %d", pe.game.BigBlind)
 		}
-		player.Chips -= amount
-		player.Bet += amount
-		pe.game.Pot += amount
-		pe.game.CurrentBet = amount
+		pe.putChips(player, amount)
+		pe.raiseTo(player)
 		player.LastAction = models.ActionBet
+
+	case models.ActionAllIn:
+		if player.Chips == 0 {
+			return fmt.Errorf("нет фишек для олл-ина")
+		}
+		allInTo := player.Bet + player.Chips
+		if allInTo > pe.game.CurrentBet && pe.game.CurrentBet > 0 && player.HasActed {
+			return fmt.Errorf("торговля не переоткрыта, можно только уравнять или сбросить")
+		}
+		pe.putChips(player, player.Chips)
+		if player.Bet > pe.game.CurrentBet {
+			pe.raiseTo(player)
+		}
+
+	default:
+		return fmt.Errorf("неизвестное действие: %s", action)
+	}
+
+	// Любое действие, после которого у игрока не осталось фишек, — олл-ин
+	if player.Chips == 0 && !player.IsFolded {
+		player.IsAllIn = true
+		player.LastAction = models.ActionAllIn
 	}
 
+	player.HasActed = true
+
 	// Переходим к следующему игроку
 	pe.game.CurrentPlayer = pe.GetNextPlayer()
 
 	return nil
 }
 
-// IsRoundComplete проверяет, завершен ли раунд торговли
+// raiseTo делает ставку игрока текущей. Полный рейз задает новый минимальный
+// рейз и переоткрывает торговлю для остальных; короткий олл-ин — нет.
+func (pe *PokerEngine) raiseTo(player *models.GamePlayer) {
+	raiseSize := player.Bet - pe.game.CurrentBet
+	pe.game.CurrentBet = player.Bet
+
+	if raiseSize >= pe.game.MinRaise {
+		pe.game.MinRaise = raiseSize
+		for i := range pe.game.Players {
+			if pe.game.Players[i].UserUUID != player.UserUUID {
+				pe.game.Players[i].HasActed = false
+			}
+		}
+	}
+}
+
+// putChips переносит фишки игрока в банк и учитывает его вклад за раздачу
+func (pe *PokerEngine) putChips(player *models.GamePlayer, amount int) {
+	player.Chips -= amount
+	player.Bet += amount
+	player.TotalBet += amount
+	pe.game.Pot += amount
+}
+
+// findPlayer возвращает игрока раздачи по UUID
+func (pe *PokerEngine) findPlayer(userUUID string) *models.GamePlayer {
+	for i := range pe.game.Players {
+		if pe.game.Players[i].UserUUID == userUUID {
+			return &pe.game.Players[i]
+		}
+	}
+	return nil
+}
+
+// IsRoundComplete проверяет, завершен ли раунд торговли: каждый игрок,
+// способный ставить, действовал после последней агрессии и уравнял ставку
 func (pe *PokerEngine) IsRoundComplete() bool {
 	activePlayers := pe.GetActivePlayers()
 	if len(activePlayers) <= 1 {
 		return true
 	}
 
-	// Проверяем, что все активные игроки сделали одинаковые ставки
+	var canBet []models.GamePlayer
 	for _, player := range activePlayers {
-		if !player.IsAllIn && player.Bet != pe.game.CurrentBet {
+		if !player.IsAllIn {
+			canBet = append(canBet, player)
+		}
+	}
+
+	// Остальные в олл-ине: последнему игроку отвечать некому,
+	// если он уже уравнял ставку
+	if len(canBet) == 0 || (len(canBet) == 1 && canBet[0].Bet >= pe.game.CurrentBet) {
+		return true
+	}
+
+	for _, player := range canBet {
+		if !player.HasActed || player.Bet != pe.game.CurrentBet {
 			return false
 		}
 	}
@@ -229,12 +285,30 @@ func (pe *PokerEngine) IsRoundComplete() bool {
 	return true
 }
 
-// AdvanceGameState переводит игру в следующее состояние
-func (pe *PokerEngine) AdvanceGameState() {
+// IsBettingRound проверяет, идет ли сейчас улица с торговлей
+func (pe *PokerEngine) IsBettingRound() bool {
+	switch pe.game.State {
+	case models.GameStatePreFlop, models.GameStateFlop, models.GameStateTurn, models.GameStateRiver:
+		return true
+	}
+	return false
+}
+
+// AdvanceGameState переводит игру в следующее состояние. Ошибка возможна
+// только при старте раздачи из ожидания — тогда состояние не меняется.
+func (pe *PokerEngine) AdvanceGameState() error {
+	// Все, кроме одного, сбросили карты: торговля окончена, карты не открываются
+	if pe.IsBettingRound() && len(pe.GetActivePlayers()) == 1 {
+		pe.game.State = models.GameStateShowdown
+		pe.DetermineWinner()
+		return nil
+	}
+
 	switch pe.game.State {
 	case models.GameStateWaiting:
-		pe.game.State = models.GameStatePreFlop
-		pe.DealCards()
+		if _, err := pe.StartHand(); err != nil {
+			return err
+		}
 		
 	case models.GameStatePreFlop:
 		pe.game.State = models.GameStateFlop
@@ -258,384 +332,150 @@ func (pe *PokerEngine) AdvanceGameState() {
 	case models.GameStateShowdown:
 		pe.game.State = models.GameStateFinished
 	}
+	return nil
 }
 
-// ResetBets сбрасывает ставки для нового раунда
-func (pe *PokerEngine) ResetBets() {
-	pe.game.CurrentBet = 0
-	for i := range pe.game.Players {
-		pe.game.Players[i].Bet = 0
-		pe.game.Players[i].LastAction = ""
-	}
-	
-	// Начинаем с игрока после дилера
-	activePlayers := pe.GetActivePlayers()
-	if len(activePlayers) > 0 {
-		pe.game.CurrentPlayer = activePlayers[0].Position
-	}
-}
-
-// DetermineWinner определяет победителя
-func (pe *PokerEngine) DetermineWinner() {
-	activePlayers := pe.GetActivePlayers()
-	if len(activePlayers) == 1 {
-		// Только один игрок остался
-		winner := &activePlayers[0]
-		winner.Chips += pe.game.Pot
-		pe.game.Pot = 0
-		return
+// IsRunoutNeeded проверяет, что торговля в раздаче окончена, но до вскрытия
+// остались улицы: все оставшиеся игроки, кроме максимум одного, в олл-ине
+func (pe *PokerEngine) IsRunoutNeeded() bool {
+	if !pe.IsBettingRound() || len(pe.GetActivePlayers()) <= 1 || !pe.IsRoundComplete() {
+		return false
 	}
 
-	// Определяем лучшие комбинации
-	bestHands := make(map[string]HandRank)
-	for _, player := range activePlayers {
-		allCards := append(player.Cards, pe.game.CommunityCards...)
-		bestHands[player.UserUUID] = GetBestHand(allCards)
-	}
-
-	// Находим победителя
-	var winners []string
-	var bestRank HandRank
-	
-	for userUUID, hand := range bestHands {
-		if len(winners) == 0 || hand.Rank > bestRank.Rank {
-			winners = []string{userUUID}
-			bestRank = hand
-		} else if hand.Rank == bestRank.Rank {
-			// Сравниваем кикеры
-			if compareKickers(hand.Kickers, bestRank.Kickers) > 0 {
-				winners = []string{userUUID}
-				bestRank = hand
-			} else if compareKickers(hand.Kickers, bestRank.Kickers) == 0 {
-				winners = append(winners, userUUID)
-			}
-		}
-	}
-
-	// Распределяем банк между победителями
-	winAmount := pe.game.Pot / len(winners)
-	for _, winnerUUID := range winners {
-		for i := range pe.game.Players {
-			if pe.game.Players[i].UserUUID == winnerUUID {
-				pe.game.Players[i].Chips += winAmount
-				break
-			}
+	canBet := 0
+	for i := range pe.game.Players {
+		if canAct(&pe.game.Players[i]) {
+			canBet++
 		}
 	}
-	
-	pe.game.Pot = 0
-}
-
-// HandRank представляет ранг руки
-type HandRank struct {
-	Rank    int   `json:"rank"`    // 1-10 (1=старшая карта, 10=роял флеш)
-	Kickers []int `json:"kickers"` // Кикеры для сравнения
-}
-
-// GetBestHand определяет лучшую комбинацию из 7 карт
-func GetBestHand(cards []models.Card) HandRank {
-	if len(cards) < 5 {
-		return HandRank{Rank: 1, Kickers: []int{}}
-	}
-
-	// Сортируем карты по убыванию
-	sort.Slice(cards, func(i, j int) bool {
-		return cards[i].Value > cards[j].Value
-	})
-
-	// Проверяем все возможные комбинации
-	if isRoyalFlush(cards) {
-		return HandRank{Rank: 10, Kickers: []int{14}}
-	}
-	if rank := isStraightFlush(cards); rank.Rank > 0 {
-		return rank
-	}
-	if rank := isFourOfAKind(cards); rank.Rank > 0 {
-		return rank
-	}
-	if rank := isFullHouse(cards); rank.Rank > 0 {
-		return rank
-	}
-	if rank := isFlush(cards); rank.Rank > 0 {
-		return rank
-	}
-	if rank := isStraight(cards); rank.Rank > 0 {
-		return rank
-	}
-	if rank := isThreeOfAKind(cards); rank.Rank > 0 {
-		return rank
-	}
-	if rank := isTwoPair(cards); rank.Rank > 0 {
-		return rank
-	}
-	if rank := isPair(cards); rank.Rank > 0 {
-		return rank
-	}
-
-	// Старшая карта
-	kickers := make([]int, 0, 5)
-	for i := 0; i < 5 && i < len(cards); i++ {
-		kickers = append(kickers, cards[i].Value)
-	}
-	return HandRank{Rank: 1, Kickers: kickers}
-}
-
-// Функции для определения комбинаций
-func isRoyalFlush(cards []models.Card) bool {
-	return isStraightFlush(cards).Rank > 0 && cards[0].Value == 14
+	return canBet <= 1
 }
 
-func isStraightFlush(cards []models.Card) HandRank {
-	flushSuit := getFlushSuit(cards)
-	if flushSuit == "" {
-		return HandRank{}
+// RunOutBoard раздает оставшиеся улицы без торговли и переходит к вскрытию.
+// Возвращает событие состояния на каждую улицу для анимации на клиентах.
+func (pe *PokerEngine) RunOutBoard() []models.GameStateEvent {
+	var events []models.GameStateEvent
+	for pe.IsBettingRound() {
+		// Улицы торговли переходят дальше без ошибок
+		_ = pe.AdvanceGameState()
+		events = append(events, pe.StateEvent())
 	}
-
-	var flushCards []models.Card
-	for _, card := range cards {
-		if card.Suit == flushSuit {
-			flushCards = append(flushCards, card)
-		}
-	}
-
-	if straight := isStraight(flushCards); straight.Rank > 0 {
-		return HandRank{Rank: 9, Kickers: straight.Kickers}
-	}
-
-	return HandRank{}
+	return events
 }
 
-func isFourOfAKind(cards []models.Card) HandRank {
-	counts := make(map[int]int)
-	for _, card := range cards {
-		counts[card.Value]++
+// StateEvent возвращает событие с текущим публичным состоянием раздачи
+func (pe *PokerEngine) StateEvent() models.GameStateEvent {
+	return models.GameStateEvent{
+		State:          pe.game.State,
+		CommunityCards: append([]models.Card(nil), pe.game.CommunityCards...),
+		Pot:            pe.game.Pot,
+		CurrentBet:     pe.game.CurrentBet,
+		CurrentPlayer:  pe.game.CurrentPlayer,
+		LegalActions:   pe.LegalActions(),
 	}
-
-	var fourValue, kicker int
-	for value, count := range counts {
-		if count == 4 {
-			fourValue = value
-		} else if count >= 1 && value > kicker {
-			kicker = value
-		}
-	}
-
-	if fourValue > 0 {
-		return HandRank{Rank: 8, Kickers: []int{fourValue, kicker}}
-	}
-
-	return HandRank{}
 }
 
-func isFullHouse(cards []models.Card) HandRank {
-	counts := make(map[int]int)
-	for _, card := range cards {
-		counts[card.Value]++
-	}
-
-	var threeValue, pairValue int
-	for value, count := range counts {
-		if count >= 3 && value > threeValue {
-			threeValue = value
-		}
+// ResetBets сбрасывает ставки для нового раунда
+func (pe *PokerEngine) ResetBets() {
+	pe.game.CurrentBet = 0
+	pe.game.MinRaise = pe.game.BigBlind
+	for i := range pe.game.Players {
+		pe.game.Players[i].Bet = 0
+		pe.game.Players[i].HasActed = false
+		pe.game.Players[i].LastAction = ""
 	}
 	
-	for value, count := range counts {
-		if count >= 2 && value != threeValue && value > pairValue {
-			pairValue = value
-		}
-	}
-
-	if threeValue > 0 && pairValue > 0 {
-		return HandRank{Rank: 7, Kickers: []int{threeValue, pairValue}}
-	}
-
-	return HandRank{}
+	// Начинаем с первого игрока слева от дилера
+	pe.game.CurrentPlayer = pe.nextPosition(pe.game.DealerPosition, canAct)
 }
 
-func isFlush(cards []models.Card) HandRank {
-	flushSuit := getFlushSuit(cards)
-	if flushSuit == "" {
-		return HandRank{}
-	}
-
-	var flushCards []models.Card
-	for _, card := range cards {
-		if card.Suit == flushSuit {
-			flushCards = append(flushCards, card)
-		}
-	}
-
-	sort.Slice(flushCards, func(i, j int) bool {
-		return flushCards[i].Value > flushCards[j].Value
-	})
-
-	kickers := make([]int, 0, 5)
-	for i := 0; i < 5 && i < len(flushCards); i++ {
-		kickers = append(kickers, flushCards[i].Value)
-	}
-
-	return HandRank{Rank: 6, Kickers: kickers}
-}
-
-func isStraight(cards []models.Card) HandRank {
-	// Убираем дубликаты
-	uniqueValues := make(map[int]bool)
-	for _, card := range cards {
-		uniqueValues[card.Value] = true
-	}
-
-	var values []int
-	for value := range uniqueValues {
-		values = append(values, value)
-	}
-	sort.Sort(sort.Reverse(sort.IntSlice(values)))
-
-	// Проверяем стрит
-	for i := 0; i <= len(values)-5; i++ {
-		if values[i]-values[i+4] == 4 {
-			return HandRank{Rank: 5, Kickers: []int{values[i]}}
-		}
-	}
-
-	// Проверяем A-2-3-4-5 стрит
-	if len(values) >= 5 && values[0] == 14 {
-		lowStraight := []int{14, 5, 4, 3, 2}
-		hasLowStraight := true
-		for _, val := range lowStraight {
-			if !uniqueValues[val] {
-				hasLowStraight = false
-				break
-			}
-		}
-		if hasLowStraight {
-			return HandRank{Rank: 5, Kickers: []int{5}}
-		}
-	}
-
-	return HandRank{}
-}
-
-func isThreeOfAKind(cards []models.Card) HandRank {
-	counts := make(map[int]int)
-	for _, card := range cards {
-		counts[card.Value]++
-	}
+// DetermineWinner определяет победителей основного и побочных банков
+// и сохраняет итог раздачи в Game.Showdown
+func (pe *PokerEngine) DetermineWinner() *models.ShowdownResult {
+	result := &models.ShowdownResult{}
+	activePlayers := pe.GetActivePlayers()
 
-	var threeValue int
-	var kickers []int
-	
-	for value, count := range counts {
-		if count == 3 {
-			threeValue = value
-		} else {
-			for i := 0; i < count; i++ {
-				kickers = append(kickers, value)
-			}
+	if len(activePlayers) == 1 {
+		// Только один игрок остался, он забирает весь банк
+		pot := models.PotResult{
+			Amount:   pe.game.Pot,
+			Eligible: []string{activePlayers[0].UserUUID},
+			Winners:  []string{activePlayers[0].UserUUID},
 		}
-	}
-
-	if threeValue > 0 {
-		sort.Sort(sort.Reverse(sort.IntSlice(kickers)))
-		if len(kickers) > 2 {
-			kickers = kickers[:2]
+		pe.splitPot(&pot)
+		result.Pots = append(result.Pots, pot)
+	} else {
+		// Определяем лучшие комбинации
+		bestHands := make(map[string]HandRank)
+		for _, player := range activePlayers {
+			allCards := make([]models.Card, 0, len(player.Cards)+len(pe.game.CommunityCards))
+			allCards = append(allCards, player.Cards...)
+			allCards = append(allCards, pe.game.CommunityCards...)
+			bestHands[player.UserUUID] = GetBestHand(allCards)
+			result.Hands = append(result.Hands, bestHands[player.UserUUID].Result(player.UserUUID))
 		}
-		return HandRank{Rank: 4, Kickers: append([]int{threeValue}, kickers...)}
-	}
 
-	return HandRank{}
-}
-
-func isTwoPair(cards []models.Card) HandRank {
-	counts := make(map[int]int)
-	for _, card := range cards {
-		counts[card.Value]++
-	}
-
-	var pairs []int
-	var kickers []int
-	
-	for value, count := range counts {
-		if count >= 2 {
-			pairs = append(pairs, value)
-			if count > 2 {
-				for i := 0; i < count-2; i++ {
-					kickers = append(kickers, value)
+		// Каждый банк разыгрывается отдельно среди своих претендентов
+		for _, pot := range BuildPots(pe.game.Players) {
+			var bestRank HandRank
+			for _, userUUID := range pot.Eligible {
+				hand := bestHands[userUUID]
+				switch cmp := compareHands(hand, bestRank); {
+				case len(pot.Winners) == 0 || cmp > 0:
+					pot.Winners = []string{userUUID}
+					bestRank = hand
+				case cmp == 0:
+					pot.Winners = append(pot.Winners, userUUID)
 				}
 			}
-		} else {
-			for i := 0; i < count; i++ {
-				kickers = append(kickers, value)
-			}
+			pe.splitPot(&pot)
+			result.Pots = append(result.Pots, pot)
 		}
 	}
 
-	if len(pairs) >= 2 {
-		sort.Sort(sort.Reverse(sort.IntSlice(pairs)))
-		sort.Sort(sort.Reverse(sort.IntSlice(kickers)))
-		
-		if len(kickers) > 1 {
-			kickers = kickers[:1]
-		}
-		
-		return HandRank{Rank: 3, Kickers: append(pairs[:2], kickers...)}
-	}
-
-	return HandRank{}
+	pe.game.Pot = 0
+	pe.game.Showdown = result
+	return result
 }
 
-func isPair(cards []models.Card) HandRank {
-	counts := make(map[int]int)
-	for _, card := range cards {
-		counts[card.Value]++
-	}
-
-	var pairValue int
-	var kickers []int
-	
-	for value, count := range counts {
-		if count == 2 {
-			pairValue = value
-		} else {
-			for i := 0; i < count; i++ {
-				kickers = append(kickers, value)
-			}
-		}
-	}
-
-	if pairValue > 0 {
-		sort.Sort(sort.Reverse(sort.IntSlice(kickers)))
-		if len(kickers) > 3 {
-			kickers = kickers[:3]
-		}
-		return HandRank{Rank: 2, Kickers: append([]int{pairValue}, kickers...)}
-	}
+// HandRank представляет ранг руки
+type HandRank struct {
+	Rank     int          `json:"rank"`     // 1-10 (1=старшая карта, 10=роял флеш)
+	Kickers  []int        `json:"kickers"`  // Кикеры для сравнения
+	Strength HandStrength `json:"strength"` // Сравнимая сила комбинации
 
-	return HandRank{}
+	Category models.HandCategory `json:"category"`
+	Cards    []models.Card       `json:"cards"` // Пять карт, составивших комбинацию
 }
 
-func getFlushSuit(cards []models.Card) string {
-	suitCounts := make(map[string]int)
-	for _, card := range cards {
-		suitCounts[card.Suit]++
+// GetBestHand определяет лучшую комбинацию из 5-7 карт.
+// Срез карт не изменяется.
+func GetBestHand(cards []models.Card) HandRank {
+	if len(cards) < 5 {
+		return HandRank{Rank: 1, Kickers: []int{}}
 	}
 
-	for suit, count := range suitCounts {
-		if count >= 5 {
-			return suit
-		}
+	strength := Evaluate(cards)
+	rank := strength.Category()
+	if rank == categoryStraightFlush && strength.Values()[0] == 14 {
+		rank = 10
+	}
+	return HandRank{
+		Rank:     rank,
+		Kickers:  strength.Values(),
+		Strength: strength,
+		Category: HandCategories[rank],
+		Cards:    bestFive(cards, strength),
 	}
-
-	return ""
 }
 
-func compareKickers(kickers1, kickers2 []int) int {
-	for i := 0; i < len(kickers1) && i < len(kickers2); i++ {
-		if kickers1[i] > kickers2[i] {
-			return 1
-		} else if kickers1[i] < kickers2[i] {
-			return -1
-		}
+// compareHands сравнивает две руки по силе комбинации
+func compareHands(hand1, hand2 HandRank) int {
+	switch {
+	case hand1.Strength > hand2.Strength:
+		return 1
+	case hand1.Strength < hand2.Strength:
+		return -1
 	}
 	return 0
-}
\ No newline at end of file
+}
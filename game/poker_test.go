@@ -0,0 +1,373 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+
+	"poker/models"
+)
+
+// newTestHand начинает раздачу с блайндами 5/10: игроки p0, p1... сидят на
+// местах 0, 1..., кнопка у p0, колода перемешана с фиксированным зерном
+func newTestHand(t *testing.T, stacks ...int) (*models.Game, *PokerEngine) {
+	t.Helper()
+	deck, err := ShuffleDeck("00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &models.Game{
+		ID:             "game",
+		State:          models.GameStateWaiting,
+		Deck:           deck,
+		DealerPosition: -1,
+		SmallBlind:     5,
+		BigBlind:       10,
+	}
+	for i, stack := range stacks {
+		g.Players = append(g.Players, models.GamePlayer{
+			UserUUID: fmt.Sprintf("p%d", i),
+			Position: i,
+			Chips:    stack,
+		})
+	}
+
+	engine := NewPokerEngine(g)
+	engine.MoveButton()
+	if _, err := engine.StartHand(); err != nil {
+		t.Fatal(err)
+	}
+	return g, engine
+}
+
+// act выполняет действие и проверяет, что движок его принял
+func act(t *testing.T, engine *PokerEngine, user string, action models.PlayerAction, amount int) {
+	t.Helper()
+	if err := engine.ProcessAction(user, action, amount); err != nil {
+		t.Fatalf("%s %s %d: %v", user, action, amount, err)
+	}
+}
+
+func chipsInPlay(g *models.Game) int {
+	total := g.Pot
+	for _, player := range g.Players {
+		total += player.Chips
+	}
+	return total
+}
+
+func TestBlindOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		players     int
+		smallBlind  string
+		bigBlind    string
+		firstToAct  int
+		firstOnFlop int
+	}{
+		{"heads-up: dealer posts the small blind and acts first preflop", 2, "p0", "p1", 0, 1},
+		{"three-handed", 3, "p1", "p2", 0, 1},
+		{"six-handed", 6, "p1", "p2", 3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stacks := make([]int, tt.players)
+			for i := range stacks {
+				stacks[i] = 1000
+			}
+			g, engine := newTestHand(t, stacks...)
+
+			if g.DealerPosition != 0 {
+				t.Fatalf("dealer at %d, want 0", g.DealerPosition)
+			}
+			for _, player := range g.Players {
+				want := 0
+				switch player.UserUUID {
+				case tt.smallBlind:
+					want = 5
+				case tt.bigBlind:
+					want = 10
+				}
+				if player.Bet != want {
+					t.Errorf("%s posted %d, want %d", player.UserUUID, player.Bet, want)
+				}
+				if len(player.Cards) != 2 {
+					t.Errorf("%s got %d cards", player.UserUUID, len(player.Cards))
+				}
+			}
+			if g.CurrentPlayer != tt.firstToAct {
+				t.Errorf("preflop starts at %d, want %d", g.CurrentPlayer, tt.firstToAct)
+			}
+
+			// Все уравнивают, большой блайнд чекает
+			for !engine.IsRoundComplete() {
+				legal := engine.LegalActions()
+				action := models.ActionCall
+				if legal.CanCheck {
+					action = models.ActionCheck
+				}
+				act(t, engine, legal.UserUUID, action, 0)
+			}
+			if err := engine.AdvanceGameState(); err != nil {
+				t.Fatal(err)
+			}
+			if g.State != models.GameStateFlop || len(g.CommunityCards) != 3 {
+				t.Fatalf("state %s with %d cards, want flop", g.State, len(g.CommunityCards))
+			}
+			if g.CurrentPlayer != tt.firstOnFlop {
+				t.Errorf("flop starts at %d, want %d", g.CurrentPlayer, tt.firstOnFlop)
+			}
+		})
+	}
+}
+
+func TestButtonMovesHeadsUp(t *testing.T) {
+	g, engine := newTestHand(t, 1000, 1000)
+	engine.MoveButton()
+	if g.DealerPosition != 1 {
+		t.Fatalf("button moved to %d, want 1", g.DealerPosition)
+	}
+	small, big := engine.BlindPositions()
+	if small != 1 || big != 0 {
+		t.Errorf("blinds at %d/%d, want 1/0", small, big)
+	}
+}
+
+func TestShortBlindGoesAllIn(t *testing.T) {
+	g, _ := newTestHand(t, 1000, 3, 1000)
+	small := g.Players[1]
+	if small.Bet != 3 || small.Chips != 0 || !small.IsAllIn {
+		t.Errorf("short small blind: bet %d, chips %d, all-in %v", small.Bet, small.Chips, small.IsAllIn)
+	}
+}
+
+func TestMinRaise(t *testing.T) {
+	type step struct {
+		user    string
+		action  models.PlayerAction
+		amount  int
+		wantErr bool
+	}
+	tests := []struct {
+		name         string
+		steps        []step
+		wantBet      int
+		wantMinRaise int
+	}{
+		{
+			name: "open raise sets the raise size",
+			steps: []step{
+				{"p0", models.ActionRaise, 30, false},
+			},
+			wantBet: 30, wantMinRaise: 20,
+		},
+		{
+			name: "raise below the minimum is rejected",
+			steps: []step{
+				{"p0", models.ActionRaise, 15, true},
+				{"p0", models.ActionRaise, 30, false},
+				{"p1", models.ActionRaise, 45, true},
+				{"p1", models.ActionRaise, 50, false},
+			},
+			wantBet: 50, wantMinRaise: 20,
+		},
+		{
+			name: "bigger re-raise raises the minimum",
+			steps: []step{
+				{"p0", models.ActionRaise, 30, false},
+				{"p1", models.ActionRaise, 100, false},
+				{"p2", models.ActionRaise, 150, true},
+				{"p2", models.ActionRaise, 170, false},
+			},
+			wantBet: 170, wantMinRaise: 70,
+		},
+		{
+			name: "raise to the current bet is rejected",
+			steps: []step{
+				{"p0", models.ActionRaise, 10, true},
+			},
+			wantBet: 10, wantMinRaise: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, engine := newTestHand(t, 1000, 1000, 1000)
+			for _, s := range tt.steps {
+				err := engine.ProcessAction(s.user, s.action, s.amount)
+				if (err != nil) != s.wantErr {
+					t.Fatalf("%s %s to %d: error %v, want error %v", s.user, s.action, s.amount, err, s.wantErr)
+				}
+			}
+			if g.CurrentBet != tt.wantBet || g.MinRaise != tt.wantMinRaise {
+				t.Errorf("bet %d, min raise %d, want %d and %d", g.CurrentBet, g.MinRaise, tt.wantBet, tt.wantMinRaise)
+			}
+		})
+	}
+}
+
+func TestShortAllInDoesNotReopenBetting(t *testing.T) {
+	// p0 на кнопке, p1 малый блайнд с 150 фишками, p2 большой блайнд
+	g, engine := newTestHand(t, 1000, 150, 1000)
+
+	act(t, engine, "p0", models.ActionRaise, 100)
+	// Олл-ин до 150 — рейз на 50 при минимальном 90
+	act(t, engine, "p1", models.ActionAllIn, 0)
+	if g.CurrentBet != 150 || g.MinRaise != 90 {
+		t.Fatalf("bet %d, min raise %d after short all-in, want 150 and 90", g.CurrentBet, g.MinRaise)
+	}
+
+	// Большой блайнд еще не действовал и может рейзить
+	legal := engine.LegalActions()
+	if legal.UserUUID != "p2" || !legal.CanRaise {
+		t.Fatalf("big blind legal actions %+v, want a raise", legal)
+	}
+	act(t, engine, "p2", models.ActionCall, 0)
+
+	// Игрок, уже действовавший после последнего полного рейза, может только
+	// уравнять или сбросить
+	legal = engine.LegalActions()
+	if legal.UserUUID != "p0" || legal.CanRaise || legal.CanAllIn || !legal.CanCall || legal.CallAmount != 50 {
+		t.Fatalf("raiser legal actions %+v, want call 50 only", legal)
+	}
+	if err := engine.ProcessAction("p0", models.ActionRaise, 300); err == nil {
+		t.Fatal("raise accepted after a short all-in")
+	}
+	if err := engine.ProcessAction("p0", models.ActionAllIn, 0); err == nil {
+		t.Fatal("all-in raise accepted after a short all-in")
+	}
+	act(t, engine, "p0", models.ActionCall, 0)
+
+	if !engine.IsRoundComplete() {
+		t.Error("round not complete after everyone called the all-in")
+	}
+}
+
+func TestFullAllInReopensBetting(t *testing.T) {
+	g, engine := newTestHand(t, 1000, 300, 1000)
+
+	act(t, engine, "p0", models.ActionRaise, 100)
+	// Олл-ин до 300 — полный рейз на 200
+	act(t, engine, "p1", models.ActionAllIn, 0)
+	if g.MinRaise != 200 {
+		t.Fatalf("min raise %d, want 200", g.MinRaise)
+	}
+	act(t, engine, "p2", models.ActionCall, 0)
+
+	legal := engine.LegalActions()
+	if legal.UserUUID != "p0" || !legal.CanRaise || legal.MinAmount != 500 {
+		t.Fatalf("raiser legal actions %+v, want a raise from 500", legal)
+	}
+}
+
+func TestRoundCompletion(t *testing.T) {
+	g, engine := newTestHand(t, 1000, 1000, 1000)
+
+	act(t, engine, "p0", models.ActionCall, 0)
+	act(t, engine, "p1", models.ActionCall, 0)
+	// Все уравняли, но у большого блайнда остается право хода
+	if engine.IsRoundComplete() {
+		t.Fatal("round complete before the big blind's option")
+	}
+	act(t, engine, "p2", models.ActionRaise, 40)
+	if engine.IsRoundComplete() {
+		t.Fatal("round complete right after a raise")
+	}
+	act(t, engine, "p0", models.ActionFold, 0)
+	act(t, engine, "p1", models.ActionCall, 0)
+	if !engine.IsRoundComplete() {
+		t.Fatal("round not complete after the raise was called")
+	}
+
+	if err := engine.AdvanceGameState(); err != nil {
+		t.Fatal(err)
+	}
+	if g.State != models.GameStateFlop || g.CurrentBet != 0 || g.CurrentPlayer != 1 {
+		t.Fatalf("state %s, bet %d, player %d, want flop from p1", g.State, g.CurrentBet, g.CurrentPlayer)
+	}
+
+	act(t, engine, "p1", models.ActionCheck, 0)
+	if engine.IsRoundComplete() {
+		t.Fatal("round complete before everyone acted")
+	}
+	act(t, engine, "p2", models.ActionCheck, 0)
+	if !engine.IsRoundComplete() {
+		t.Fatal("round not complete after everyone checked")
+	}
+}
+
+func TestFoldEndsHand(t *testing.T) {
+	g, engine := newTestHand(t, 1000, 1000, 1000)
+	total := chipsInPlay(g)
+
+	act(t, engine, "p0", models.ActionFold, 0)
+	act(t, engine, "p1", models.ActionFold, 0)
+	if !engine.IsRoundComplete() {
+		t.Fatal("round not complete with one player left")
+	}
+	if err := engine.AdvanceGameState(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.State != models.GameStateShowdown || len(g.Showdown.Hands) != 0 {
+		t.Fatalf("state %s with %d hands shown, want showdown without cards", g.State, len(g.Showdown.Hands))
+	}
+	if g.Players[2].Chips != 1005 {
+		t.Errorf("big blind has %d chips, want 1005", g.Players[2].Chips)
+	}
+	if chipsInPlay(g) != total {
+		t.Errorf("%d chips after the hand, want %d", chipsInPlay(g), total)
+	}
+}
+
+func TestAllInRunout(t *testing.T) {
+	// Неуравненный остаток самой большой ставки — отдельный банк с одним
+	// претендентом
+	tests := []struct {
+		name   string
+		stacks []int
+		pots   int
+	}{
+		{"heads-up", []int{500, 1000}, 2},
+		{"three players, uneven stacks", []int{1000, 200, 500}, 3},
+		{"four players, uneven stacks", []int{300, 1000, 600, 1000}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, engine := newTestHand(t, tt.stacks...)
+			total := chipsInPlay(g)
+
+			// Все идут олл-ин или уравнивают самый большой олл-ин
+			for !engine.IsRoundComplete() {
+				legal := engine.LegalActions()
+				if legal.CanAllIn {
+					act(t, engine, legal.UserUUID, models.ActionAllIn, 0)
+				} else {
+					act(t, engine, legal.UserUUID, models.ActionCall, 0)
+				}
+			}
+			if err := engine.AdvanceGameState(); err != nil {
+				t.Fatal(err)
+			}
+			if !engine.IsRunoutNeeded() {
+				t.Fatalf("no runout needed in state %s", g.State)
+			}
+
+			events := engine.RunOutBoard()
+			if len(events) != 3 {
+				t.Errorf("%d street events, want turn, river and showdown", len(events))
+			}
+			if g.State != models.GameStateShowdown || len(g.CommunityCards) != 5 {
+				t.Fatalf("state %s with %d cards, want showdown with a full board", g.State, len(g.CommunityCards))
+			}
+			if len(g.Showdown.Pots) != tt.pots {
+				t.Errorf("%d pots, want %d", len(g.Showdown.Pots), tt.pots)
+			}
+			if g.Pot != 0 || chipsInPlay(g) != total {
+				t.Errorf("%d chips after the hand and %d in the pot, want %d", chipsInPlay(g), g.Pot, total)
+			}
+		})
+	}
+}
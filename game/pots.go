@@ -0,0 +1,115 @@
+package game
+
+import (
+	"sort"
+
+	"poker/models"
+)
+
+// BuildPots разбивает банк на основной и побочные по вкладам игроков.
+// Каждый банк разыгрывают только игроки, покрывшие его уровень ставки.
+func BuildPots(players []models.GamePlayer) []models.PotResult {
+	// Уровни вкладов игроков, оставшихся в раздаче
+	levelSet := make(map[int]bool)
+	for _, player := range players {
+		if !player.IsFolded && player.TotalBet > 0 {
+			levelSet[player.TotalBet] = true
+		}
+	}
+
+	var levels []int
+	for level := range levelSet {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+
+	var pots []models.PotResult
+	previous := 0
+	for _, level := range levels {
+		pot := models.PotResult{}
+		for _, player := range players {
+			pot.Amount += min(player.TotalBet, level) - min(player.TotalBet, previous)
+			if !player.IsFolded && player.TotalBet >= level {
+				pot.Eligible = append(pot.Eligible, player.UserUUID)
+			}
+		}
+
+		// Банки с одинаковым составом претендентов объединяем
+		if n := len(pots); n > 0 && sameEligible(pots[n-1].Eligible, pot.Eligible) {
+			pots[n-1].Amount += pot.Amount
+		} else {
+			pots = append(pots, pot)
+		}
+		previous = level
+	}
+
+	// Фишки сфолдивших сверх максимального уровня уходят в последний банк
+	var rest int
+	for _, player := range players {
+		if player.TotalBet > previous {
+			rest += player.TotalBet - previous
+		}
+	}
+	if rest > 0 && len(pots) > 0 {
+		pots[len(pots)-1].Amount += rest
+	}
+
+	return pots
+}
+
+// splitPot делит банк между победителями. Лишние фишки при нечетном делении
+// получают победители, сидящие ближе всего слева от дилера.
+func (pe *PokerEngine) splitPot(pot *models.PotResult) {
+	winners := pe.orderFromDealer(pot.Winners)
+	pot.Winners = winners
+	pot.Payouts = make(map[string]int, len(winners))
+
+	share := pot.Amount / len(winners)
+	remainder := pot.Amount % len(winners)
+	for i, userUUID := range winners {
+		amount := share
+		if i < remainder {
+			amount++
+		}
+		pot.Payouts[userUUID] = amount
+
+		if player := pe.findPlayer(userUUID); player != nil {
+			player.Chips += amount
+		}
+	}
+}
+
+// orderFromDealer сортирует игроков по порядку мест, начиная слева от дилера
+func (pe *PokerEngine) orderFromDealer(userUUIDs []string) []string {
+	positions := make(map[string]int, len(userUUIDs))
+	maxPosition := 0
+	for _, player := range pe.game.Players {
+		positions[player.UserUUID] = player.Position
+		if player.Position > maxPosition {
+			maxPosition = player.Position
+		}
+	}
+
+	seats := maxPosition + 1
+	distance := func(userUUID string) int {
+		return ((positions[userUUID]-pe.game.DealerPosition-1)%seats + seats) % seats
+	}
+
+	ordered := append([]string(nil), userUUIDs...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return distance(ordered[i]) < distance(ordered[j])
+	})
+	return ordered
+}
+
+func sameEligible(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package game
+
+import (
+	"testing"
+
+	"poker/models"
+)
+
+func TestBuildPots(t *testing.T) {
+	type contribution struct {
+		user   string
+		total  int
+		folded bool
+	}
+	tests := []struct {
+		name    string
+		players []contribution
+		want    []models.PotResult
+	}{
+		{
+			name: "single pot",
+			players: []contribution{
+				{"a", 100, false},
+				{"b", 100, false},
+				{"c", 100, false},
+			},
+			want: []models.PotResult{
+				{Amount: 300, Eligible: []string{"a", "b", "c"}},
+			},
+		},
+		{
+			name: "three players, short all-in",
+			players: []contribution{
+				{"a", 50, false},
+				{"b", 100, false},
+				{"c", 100, false},
+			},
+			want: []models.PotResult{
+				{Amount: 150, Eligible: []string{"a", "b", "c"}},
+				{Amount: 100, Eligible: []string{"b", "c"}},
+			},
+		},
+		{
+			name: "three players, two uneven all-ins",
+			players: []contribution{
+				{"a", 30, false},
+				{"b", 70, false},
+				{"c", 200, false},
+			},
+			want: []models.PotResult{
+				{Amount: 90, Eligible: []string{"a", "b", "c"}},
+				{Amount: 80, Eligible: []string{"b", "c"}},
+				{Amount: 130, Eligible: []string{"c"}},
+			},
+		},
+		{
+			name: "four players, three all-in levels",
+			players: []contribution{
+				{"a", 20, false},
+				{"b", 50, false},
+				{"c", 100, false},
+				{"d", 100, false},
+			},
+			want: []models.PotResult{
+				{Amount: 80, Eligible: []string{"a", "b", "c", "d"}},
+				{Amount: 90, Eligible: []string{"b", "c", "d"}},
+				{Amount: 100, Eligible: []string{"c", "d"}},
+			},
+		},
+		{
+			name: "four players, folded chips stay in the pots",
+			players: []contribution{
+				{"a", 30, false},
+				{"b", 60, true},
+				{"c", 100, false},
+				{"d", 100, false},
+			},
+			want: []models.PotResult{
+				{Amount: 120, Eligible: []string{"a", "c", "d"}},
+				{Amount: 170, Eligible: []string{"c", "d"}},
+			},
+		},
+		{
+			name: "folded chips above every all-in go to the last pot",
+			players: []contribution{
+				{"a", 10, false},
+				{"b", 40, true},
+				{"c", 10, false},
+			},
+			want: []models.PotResult{
+				{Amount: 60, Eligible: []string{"a", "c"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			players := make([]models.GamePlayer, len(tt.players))
+			total := 0
+			for i, p := range tt.players {
+				players[i] = models.GamePlayer{UserUUID: p.user, Position: i, TotalBet: p.total, IsFolded: p.folded}
+				total += p.total
+			}
+
+			pots := BuildPots(players)
+			if len(pots) != len(tt.want) {
+				t.Fatalf("got %d pots %+v, want %d", len(pots), pots, len(tt.want))
+			}
+			sum := 0
+			for i, pot := range pots {
+				sum += pot.Amount
+				if pot.Amount != tt.want[i].Amount || !equalStrings(pot.Eligible, tt.want[i].Eligible) {
+					t.Errorf("pot %d: %d for %v, want %d for %v",
+						i, pot.Amount, pot.Eligible, tt.want[i].Amount, tt.want[i].Eligible)
+				}
+			}
+			if sum != total {
+				t.Errorf("pots hold %d chips, players put in %d", sum, total)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"poker/models"
+)
+
+// Combo конкретная стартовая рука из двух карт
+type Combo [2]models.Card
+
+// Mask возвращает маску карт комбо
+func (c Combo) Mask() CardMask {
+	return CardBit(c[0]) | CardBit(c[1])
+}
+
+// rankChars символы значений в нотации диапазонов: индекс — значение карты
+const rankChars = "??23456789TJQKA"
+
+var suitChars = map[byte]string{'h': "hearts", 'd': "diamonds", 'c': "clubs", 's': "spades"}
+
+// ParseRange разбирает диапазон в стандартной нотации, например
+// "TT+, AKs, KQo, A5s-A2s, AhKh". Поддерживаются пары ("77", "TT+",
+// "TT-77"), одномастные и разномастные руки ("AKs", "AKo", "AK" — обе),
+// "ATs+" (кикер от T до K), интервалы с общей старшей картой ("A5s-A2s")
+// и конкретные комбо ("AhKh"). Повторы убираются.
+func ParseRange(notation string) ([]Combo, error) {
+	var combos []Combo
+	seen := make(map[CardMask]bool)
+	add := func(c Combo) {
+		if mask := c.Mask(); !seen[mask] {
+			seen[mask] = true
+			combos = append(combos, c)
+		}
+	}
+
+	for _, token := range strings.Split(notation, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		if err := parseRangeToken(token, add); err != nil {
+			return nil, err
+		}
+	}
+	return combos, nil
+}
+
+func parseRangeToken(token string, add func(Combo)) error {
+	invalid := fmt.Errorf("invalid range token %q", token)
+
+	// Конкретное комбо: AhKd
+	if len(token) == 4 && suitChars[token[1]] != "" && suitChars[token[3]] != "" {
+		high, low := rankValue(token[0]), rankValue(token[2])
+		if high == 0 || low == 0 || token[:2] == token[2:] {
+			return invalid
+		}
+		add(Combo{models.NewCard(high, suitChars[token[1]]), models.NewCard(low, suitChars[token[3]])})
+		return nil
+	}
+
+	from, to, ok := strings.Cut(token, "-")
+	high, low, suited, plus, valid := parseHand(from)
+	if !valid {
+		return invalid
+	}
+
+	// Границы значений младшей карты (у пар — обеих карт)
+	lowFrom, lowTo := low, low
+	switch {
+	case ok:
+		high2, low2, suited2, plus2, valid2 := parseHand(to)
+		if !valid2 || plus || plus2 || suited2 != suited || (high == low) != (high2 == low2) {
+			return invalid
+		}
+		if high != low && high2 != high {
+			return invalid
+		}
+		lowFrom, lowTo = min(low, low2), max(low, low2)
+	case plus && high == low:
+		lowTo = 14
+	case plus:
+		lowTo = high - 1
+	}
+
+	for value := lowFrom; value <= lowTo; value++ {
+		if high == low {
+			addPairs(value, add)
+		} else {
+			addUnpaired(high, value, suited, add)
+		}
+	}
+	return nil
+}
+
+// parseHand разбирает "AK", "AKs", "AKo", "TT" с необязательным "+".
+// suited: 's' — одномастные, 'o' — разномастные, 0 — все.
+func parseHand(hand string) (high, low int, suited byte, plus, ok bool) {
+	if strings.HasSuffix(hand, "+") {
+		plus = true
+		hand = hand[:len(hand)-1]
+	}
+	if len(hand) == 3 {
+		suited = hand[2]
+		hand = hand[:2]
+		if suited != 's' && suited != 'o' {
+			return 0, 0, 0, false, false
+		}
+	}
+	if len(hand) != 2 {
+		return 0, 0, 0, false, false
+	}
+
+	high, low = rankValue(hand[0]), rankValue(hand[1])
+	if high == 0 || low == 0 || (high == low && suited != 0) {
+		return 0, 0, 0, false, false
+	}
+	if low > high {
+		high, low = low, high
+	}
+	return high, low, suited, plus, true
+}
+
+func rankValue(char byte) int {
+	if i := strings.IndexByte(rankChars, char); i >= 2 {
+		return i
+	}
+	return 0
+}
+
+var rangeSuits = [...]string{"hearts", "diamonds", "clubs", "spades"}
+
+// addPairs добавляет 6 комбо пары
+func addPairs(value int, add func(Combo)) {
+	for i := 0; i < len(rangeSuits); i++ {
+		for j := i + 1; j < len(rangeSuits); j++ {
+			add(Combo{models.NewCard(value, rangeSuits[i]), models.NewCard(value, rangeSuits[j])})
+		}
+	}
+}
+
+// addUnpaired добавляет 4 одномастных и/или 12 разномастных комбо
+func addUnpaired(high, low int, suited byte, add func(Combo)) {
+	for _, highSuit := range rangeSuits {
+		for _, lowSuit := range rangeSuits {
+			same := highSuit == lowSuit
+			if (suited == 's' && !same) || (suited == 'o' && same) {
+				continue
+			}
+			add(Combo{models.NewCard(high, highSuit), models.NewCard(low, lowSuit)})
+		}
+	}
+}
+
+// RemoveBlocked убирает комбо, в которых есть известные карты
+// (доска, мертвые карты, руки других игроков)
+func RemoveBlocked(combos []Combo, known CardMask) []Combo {
+	available := make([]Combo, 0, len(combos))
+	for _, combo := range combos {
+		if combo.Mask()&known == 0 {
+			available = append(available, combo)
+		}
+	}
+	return available
+}
@@ -0,0 +1,130 @@
+package game
+
+import (
+	"sort"
+	"testing"
+
+	"poker/models"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		notation string
+		combos   int
+		hands    []string // Классы рук, которые должны попасть в диапазон
+	}{
+		{"77", 6, []string{"77"}},
+		{"TT+", 30, []string{"TT", "JJ", "QQ", "KK", "AA"}},
+		{"TT-77", 24, []string{"77", "88", "99", "TT"}},
+		{"AKs", 4, []string{"AKs"}},
+		{"AKo", 12, []string{"AKo"}},
+		{"AK", 16, []string{"AKs", "AKo"}},
+		{"KA", 16, []string{"AKs", "AKo"}},
+		{"ATs+", 16, []string{"ATs", "AJs", "AQs", "AKs"}},
+		{"A5s-A2s", 16, []string{"A2s", "A3s", "A4s", "A5s"}},
+		{"A2s-A5s", 16, []string{"A2s", "A3s", "A4s", "A5s"}},
+		{"AhKh", 1, []string{"AKs"}},
+		{"AdKc", 1, []string{"AKo"}},
+		{"TT+, AKs, KQo, A5s-A2s", 30 + 4 + 12 + 16, []string{"TT", "JJ", "QQ", "KK", "AA", "AKs", "KQo", "A2s", "A3s", "A4s", "A5s"}},
+		{"AKs, AK, AhKh", 16, []string{"AKs", "AKo"}},
+		{" QQ , , JJ ", 12, []string{"JJ", "QQ"}},
+		{"", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.notation, func(t *testing.T) {
+			combos, err := ParseRange(tt.notation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(combos) != tt.combos {
+				t.Errorf("%d combos, want %d", len(combos), tt.combos)
+			}
+
+			seen := make(map[CardMask]bool)
+			classes := make(map[string]bool)
+			for _, combo := range combos {
+				if combo[0] == combo[1] || !combo[0].Valid() || !combo[1].Valid() {
+					t.Fatalf("invalid combo %v", combo)
+				}
+				if seen[combo.Mask()] {
+					t.Fatalf("combo %v repeated", combo)
+				}
+				seen[combo.Mask()] = true
+				classes[handClass(combo)] = true
+			}
+
+			var got []string
+			for class := range classes {
+				got = append(got, class)
+			}
+			want := append([]string(nil), tt.hands...)
+			sort.Strings(got)
+			sort.Strings(want)
+			if !equalStrings(got, want) {
+				t.Errorf("hands %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, notation := range []string{
+		"AKx",      // неизвестный суффикс
+		"AAs",      // пара не бывает одномастной
+		"A1s",      // нет такого значения
+		"AK+s",     // плюс не на своем месте
+		"AKs-KQs",  // разные старшие карты в интервале
+		"A5s-A2o",  // разная масть концов интервала
+		"TT-A5s",   // пара и не пара в интервале
+		"TT+-77",   // плюс в интервале
+		"AhAh",     // одна карта дважды
+		"AhKx",     // неизвестная масть
+		"QQ, JJ,9", // ошибка после корректных токенов
+	} {
+		t.Run(notation, func(t *testing.T) {
+			if combos, err := ParseRange(notation); err == nil {
+				t.Errorf("parsed into %d combos, want an error", len(combos))
+			}
+		})
+	}
+}
+
+func TestRemoveBlocked(t *testing.T) {
+	combos, err := ParseRange("AA, AKs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	board, err := models.ParseCards("Ah 7c 2d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Туз червей убирает 3 комбо тузов и AhKh
+	available := RemoveBlocked(combos, MaskOf(board))
+	if len(available) != 6 {
+		t.Errorf("%d combos left, want 6", len(available))
+	}
+	for _, combo := range available {
+		if combo.Mask()&MaskOf(board) != 0 {
+			t.Errorf("blocked combo %v left", combo)
+		}
+	}
+}
+
+// handClass запись класса руки: "AA", "AKs", "AKo"
+func handClass(combo Combo) string {
+	high, low := combo[0], combo[1]
+	if low.Value > high.Value {
+		high, low = low, high
+	}
+	class := string([]byte{rankChars[high.Value], rankChars[low.Value]})
+	switch {
+	case high.Value == low.Value:
+		return class
+	case high.Suit == low.Suit:
+		return class + "s"
+	default:
+		return class + "o"
+	}
+}
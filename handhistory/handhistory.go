@@ -0,0 +1,229 @@
+// Package handhistory выгружает завершенные раздачи в форматы,
+// которые понимают трекеры и другие покерные программы.
+package handhistory
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+
+	"poker/models"
+)
+
+var ErrHandNotFinished = errors.New("hand is not finished yet")
+
+// hand разобранная раздача: общие для всех форматов данные
+type hand struct {
+	game    *models.Game
+	actions []models.GameAction
+	viewer  string // Игрок, запросивший историю: видит свои карты
+
+	seats      []models.GamePlayer // По местам за столом
+	names      map[string]string
+	stacks     map[string]int // Стек на начало раздачи
+	payouts    map[string]int
+	shown      map[string]models.HandResult
+	smallBlind string
+	bigBlind   string
+	uncalled   int    // Невостребованная часть последней ставки
+	uncalledTo string // Кому она вернулась
+}
+
+// newHand собирает данные раздачи; раздача должна быть завершена
+func newHand(g *models.Game, actions []models.GameAction, viewer string) (*hand, error) {
+	if g.State != models.GameStateFinished || g.Showdown == nil {
+		return nil, ErrHandNotFinished
+	}
+
+	h := &hand{
+		game:    g,
+		actions: actions,
+		viewer:  viewer,
+		names:   make(map[string]string),
+		stacks:  make(map[string]int),
+		payouts: make(map[string]int),
+		shown:   make(map[string]models.HandResult),
+	}
+
+	h.seats = append(h.seats, g.Players...)
+	sort.Slice(h.seats, func(i, j int) bool {
+		return h.seats[i].Position < h.seats[j].Position
+	})
+
+	for _, pot := range g.Showdown.Pots {
+		for userUUID, amount := range pot.Payouts {
+			h.payouts[userUUID] += amount
+		}
+	}
+	for _, result := range g.Showdown.Hands {
+		h.shown[result.UserUUID] = result
+	}
+
+	// Фишки игрока к концу раздачи = стек - вложено + выигрыш
+	for _, player := range h.seats {
+		h.names[player.UserUUID] = playerName(player)
+		h.stacks[player.UserUUID] = player.Chips + player.TotalBet - h.payouts[player.UserUUID]
+	}
+
+	for _, action := range actions {
+		switch action.Action {
+		case models.ActionPostSmallBlind:
+			h.smallBlind = action.UserUUID
+		case models.ActionPostBigBlind:
+			h.bigBlind = action.UserUUID
+		}
+	}
+
+	// Часть самой большой ставки, которую никто не уравнял, возвращается
+	top, second := 0, 0
+	for _, player := range h.seats {
+		switch {
+		case player.TotalBet > top:
+			top, second = player.TotalBet, top
+			h.uncalledTo = player.UserUUID
+		case player.TotalBet > second:
+			second = player.TotalBet
+		}
+	}
+	h.uncalled = top - second
+	return h, nil
+}
+
+// totalPot банк без невостребованной ставки
+func (h *hand) totalPot() int {
+	total := 0
+	for _, player := range h.seats {
+		total += player.TotalBet
+	}
+	return total - h.uncalled
+}
+
+// collected выигрыш игрока без возвращенной ему невостребованной ставки
+func (h *hand) collected(userUUID string) int {
+	won := h.payouts[userUUID]
+	if userUUID == h.uncalledTo {
+		won -= h.uncalled
+	}
+	return won
+}
+
+// holeCards карты игрока, если их можно показать запросившему
+func (h *hand) holeCards(player models.GamePlayer) []models.Card {
+	if _, ok := h.shown[player.UserUUID]; ok || player.UserUUID == h.viewer {
+		return player.Cards
+	}
+	return nil
+}
+
+// boardFor карты доски, открытые к улице
+func (h *hand) boardFor(street models.GameState) []models.Card {
+	count := map[models.GameState]int{
+		models.GameStateFlop:  3,
+		models.GameStateTurn:  4,
+		models.GameStateRiver: 5,
+	}[street]
+	if len(h.game.CommunityCards) < count {
+		return nil
+	}
+	return h.game.CommunityCards[:count]
+}
+
+// streetActions действия улицы; действия без улицы считаются префлопом
+func (h *hand) streetActions(street models.GameState) []models.GameAction {
+	var actions []models.GameAction
+	for _, action := range h.actions {
+		actionStreet := action.Street
+		if actionStreet == "" {
+			actionStreet = models.GameStatePreFlop
+		}
+		if actionStreet == street {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// streetAction действие с уточненным видом ставки
+type streetAction struct {
+	models.GameAction
+	Kind  models.PlayerAction // Для ставок — bet, raise или call, иначе как в Action
+	To    int                 // Ставка игрока на улице после действия
+	By    int                 // На сколько рейз поднял ставку
+	AllIn bool
+}
+
+// classifyStreet определяет вид каждой ставки улицы: олл-ин в журнале
+// становится бетом, рейзом или коллом. committed — сколько фишек игроки
+// вложили с начала раздачи, обновляется по ходу.
+func (h *hand) classifyStreet(street models.GameState, committed map[string]int) []streetAction {
+	var classified []streetAction
+	bets := make(map[string]int)
+	currentBet := 0
+	for _, action := range h.streetActions(street) {
+		bets[action.UserUUID] += action.Amount
+		committed[action.UserUUID] += action.Amount
+		a := streetAction{
+			GameAction: action,
+			Kind:       action.Action,
+			To:         bets[action.UserUUID],
+			AllIn:      action.Amount > 0 && committed[action.UserUUID] >= h.stacks[action.UserUUID],
+		}
+
+		switch action.Action {
+		case models.ActionPostSmallBlind, models.ActionPostBigBlind, models.ActionFold, models.ActionCheck:
+		default:
+			switch {
+			case a.To <= currentBet:
+				a.Kind = models.ActionCall
+			case currentBet == 0:
+				a.Kind = models.ActionBet
+			default:
+				a.Kind = models.ActionRaise
+				a.By = a.To - currentBet
+			}
+		}
+		currentBet = max(currentBet, a.To)
+		classified = append(classified, a)
+	}
+	return classified
+}
+
+// isBlind проверяет, что действие — обязательная ставка
+func isBlind(action models.PlayerAction) bool {
+	return action == models.ActionPostSmallBlind || action == models.ActionPostBigBlind
+}
+
+// foldStreet улица, на которой игрок сбросил карты, или пустая строка
+func (h *hand) foldStreet(userUUID string) models.GameState {
+	for _, action := range h.actions {
+		if action.UserUUID == userUUID && action.Action == models.ActionFold {
+			if action.Street == "" {
+				return models.GameStatePreFlop
+			}
+			return action.Street
+		}
+	}
+	return ""
+}
+
+// handNumber числовой номер раздачи для трекеров, получаемый из ID
+func handNumber(gameID string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(gameID))
+	return hash.Sum64() % 1_000_000_000_000
+}
+
+func playerName(player models.GamePlayer) string {
+	if player.User.Username != "" {
+		return player.User.Username
+	}
+	return player.UserUUID
+}
+
+// streets улицы по порядку
+var streets = []models.GameState{
+	models.GameStatePreFlop,
+	models.GameStateFlop,
+	models.GameStateTurn,
+	models.GameStateRiver,
+}
@@ -0,0 +1,293 @@
+package handhistory
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"poker/models"
+)
+
+// Open Hand History (https://hh-specs.handhistory.org) — открытый JSON-формат
+// истории раздач. Суммы указываются в фишках; для Raise amount — сумма,
+// "до" которой поднята ставка на улице, для остальных действий — вложенные
+// этим действием фишки.
+
+const (
+	OHHSpecVersion = "1.4.6"
+	OHHSiteName    = "Telegram Poker"
+)
+
+// Названия действий OHH
+const (
+	OHHDealtCards = "Dealt Cards"
+	OHHMucksCards = "Mucks Cards"
+	OHHShowsCards = "Shows Cards"
+	OHHPostAnte   = "Post Ante"
+	OHHPostSB     = "Post SB"
+	OHHPostBB     = "Post BB"
+	OHHFold       = "Fold"
+	OHHCheck      = "Check"
+	OHHBet        = "Bet"
+	OHHRaise      = "Raise"
+	OHHCall       = "Call"
+)
+
+// OHHFile обертка одной раздачи в файле OHH
+type OHHFile struct {
+	OHH OHH `json:"ohh"`
+}
+
+// OHH раздача в формате Open Hand History
+type OHH struct {
+	SpecVersion      string      `json:"spec_version"`
+	SiteName         string      `json:"site_name"`
+	NetworkName      string      `json:"network_name"`
+	InternalVersion  string      `json:"internal_version"`
+	Tournament       bool        `json:"tournament"`
+	GameNumber       string      `json:"game_number"`
+	StartDateUTC     string      `json:"start_date_utc"`
+	TableName        string      `json:"table_name"`
+	TableHandle      string      `json:"table_handle"`
+	TableSize        int         `json:"table_size"`
+	GameType         string      `json:"game_type"`
+	BetLimit         OHHBetLimit `json:"bet_limit"`
+	Currency         string      `json:"currency"`
+	DealerSeat       int         `json:"dealer_seat"`
+	SmallBlindAmount float64     `json:"small_blind_amount"`
+	BigBlindAmount   float64     `json:"big_blind_amount"`
+	AnteAmount       float64     `json:"ante_amount"`
+	HeroPlayerID     int         `json:"hero_player_id,omitempty"`
+	Flags            []string    `json:"flags"`
+	Players          []OHHPlayer `json:"players"`
+	Rounds           []OHHRound  `json:"rounds"`
+	Pots             []OHHPot    `json:"pots"`
+}
+
+type OHHBetLimit struct {
+	BetType string  `json:"bet_type"`
+	BetCap  float64 `json:"bet_cap"`
+}
+
+type OHHPlayer struct {
+	ID            int     `json:"id"`
+	Seat          int     `json:"seat"`
+	Name          string  `json:"name"`
+	Display       string  `json:"display,omitempty"`
+	StartingStack float64 `json:"starting_stack"`
+}
+
+type OHHRound struct {
+	ID      int         `json:"id"`
+	Street  string      `json:"street"` // Preflop, Flop, Turn, River, Showdown
+	Cards   []string    `json:"cards,omitempty"`
+	Actions []OHHAction `json:"actions"`
+}
+
+type OHHAction struct {
+	ActionNumber int      `json:"action_number"`
+	PlayerID     int      `json:"player_id"`
+	Action       string   `json:"action"`
+	Amount       float64  `json:"amount,omitempty"`
+	IsAllIn      bool     `json:"is_allin,omitempty"`
+	Cards        []string `json:"cards,omitempty"`
+}
+
+type OHHPot struct {
+	Number     int            `json:"number"`
+	Amount     float64        `json:"amount"`
+	Rake       float64        `json:"rake"`
+	Jackpot    float64        `json:"jackpot"`
+	PlayerWins []OHHPlayerWin `json:"player_wins"`
+}
+
+type OHHPlayerWin struct {
+	PlayerID        int     `json:"player_id"`
+	WinAmount       float64 `json:"win_amount"`
+	ContributedRake float64 `json:"contributed_rake"`
+}
+
+var ohhStreets = map[models.GameState]string{
+	models.GameStatePreFlop: "Preflop",
+	models.GameStateFlop:    "Flop",
+	models.GameStateTurn:    "Turn",
+	models.GameStateRiver:   "River",
+}
+
+var ohhActions = map[models.PlayerAction]string{
+	models.ActionPostSmallBlind: OHHPostSB,
+	models.ActionPostBigBlind:   OHHPostBB,
+	models.ActionFold:           OHHFold,
+	models.ActionCheck:          OHHCheck,
+	models.ActionCall:           OHHCall,
+	models.ActionBet:            OHHBet,
+	models.ActionRaise:          OHHRaise,
+}
+
+// ToOHH переводит завершенную раздачу в формат OHH. Как и в текстовой
+// выгрузке, карты соперников видны только после вскрытия.
+func ToOHH(g *models.Game, actions []models.GameAction, viewer string) (*OHHFile, error) {
+	h, err := newHand(g, actions, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	tableSize := g.Table.MaxSeats
+	if tableSize == 0 {
+		tableSize = len(h.seats)
+	}
+	ohh := OHH{
+		SpecVersion:      OHHSpecVersion,
+		SiteName:         OHHSiteName,
+		NetworkName:      OHHSiteName,
+		InternalVersion:  "1",
+		GameNumber:       strconv.FormatUint(handNumber(g.ID), 10),
+		StartDateUTC:     g.CreatedAt.UTC().Format(time.RFC3339),
+		TableName:        "Table " + strconv.Itoa(g.TableID),
+		TableHandle:      strconv.Itoa(g.TableID),
+		TableSize:        tableSize,
+		GameType:         "Holdem",
+		BetLimit:         OHHBetLimit{BetType: "NL"},
+		Currency:         "Chips",
+		DealerSeat:       g.DealerPosition,
+		SmallBlindAmount: float64(g.SmallBlind),
+		BigBlindAmount:   float64(g.BigBlind),
+		Flags:            []string{},
+	}
+
+	playerIDs := make(map[string]int)
+	for i, player := range h.seats {
+		playerIDs[player.UserUUID] = i + 1
+		if player.UserUUID == viewer {
+			ohh.HeroPlayerID = i + 1
+		}
+		ohh.Players = append(ohh.Players, OHHPlayer{
+			ID:            i + 1,
+			Seat:          player.Position,
+			Name:          h.names[player.UserUUID],
+			Display:       h.names[player.UserUUID],
+			StartingStack: float64(h.stacks[player.UserUUID]),
+		})
+	}
+
+	actionNumber := 0
+	nextAction := func(action OHHAction) OHHAction {
+		actionNumber++
+		action.ActionNumber = actionNumber
+		return action
+	}
+
+	committed := make(map[string]int)
+	for _, street := range streets {
+		round := OHHRound{ID: len(ohh.Rounds), Street: ohhStreets[street], Actions: []OHHAction{}}
+		if street != models.GameStatePreFlop {
+			board := h.boardFor(street)
+			if board == nil {
+				break
+			}
+			round.Cards = cardStrings(board[len(board)-streetCards(street):])
+		}
+
+		dealt := street != models.GameStatePreFlop
+		for _, action := range h.classifyStreet(street, committed) {
+			if !isBlind(action.Action) && !dealt {
+				round.Actions = append(round.Actions, h.dealtCards(playerIDs, nextAction)...)
+				dealt = true
+			}
+
+			ohhAction := OHHAction{
+				PlayerID: playerIDs[action.UserUUID],
+				Action:   ohhActions[action.Kind],
+				Amount:   float64(action.Amount),
+				IsAllIn:  action.AllIn,
+			}
+			if action.Kind == models.ActionRaise {
+				ohhAction.Amount = float64(action.To)
+			}
+			round.Actions = append(round.Actions, nextAction(ohhAction))
+		}
+		if !dealt {
+			round.Actions = append(round.Actions, h.dealtCards(playerIDs, nextAction)...)
+		}
+		ohh.Rounds = append(ohh.Rounds, round)
+	}
+
+	if len(h.shown) > 0 {
+		round := OHHRound{ID: len(ohh.Rounds), Street: "Showdown"}
+		for _, player := range h.seats {
+			if _, ok := h.shown[player.UserUUID]; ok {
+				round.Actions = append(round.Actions, nextAction(OHHAction{
+					PlayerID: playerIDs[player.UserUUID],
+					Action:   OHHShowsCards,
+					Cards:    cardStrings(h.holeCards(player)),
+				}))
+			}
+		}
+		ohh.Rounds = append(ohh.Rounds, round)
+	}
+
+	for i, pot := range h.pots() {
+		ohhPot := OHHPot{Number: i, Amount: float64(pot.Amount), PlayerWins: []OHHPlayerWin{}}
+		for _, player := range h.seats {
+			if amount := pot.Payouts[player.UserUUID]; amount > 0 {
+				ohhPot.PlayerWins = append(ohhPot.PlayerWins, OHHPlayerWin{
+					PlayerID:  playerIDs[player.UserUUID],
+					WinAmount: float64(amount),
+				})
+			}
+		}
+		ohh.Pots = append(ohh.Pots, ohhPot)
+	}
+
+	return &OHHFile{OHH: ohh}, nil
+}
+
+// dealtCards раздача карт: в истории есть только карты запросившего игрока
+func (h *hand) dealtCards(playerIDs map[string]int, nextAction func(OHHAction) OHHAction) []OHHAction {
+	var actions []OHHAction
+	for _, player := range h.seats {
+		if player.UserUUID == h.viewer && len(player.Cards) > 0 {
+			actions = append(actions, nextAction(OHHAction{
+				PlayerID: playerIDs[player.UserUUID],
+				Action:   OHHDealtCards,
+				Cards:    cardStrings(player.Cards),
+			}))
+		}
+	}
+	return actions
+}
+
+// OHHBulk выгружает несколько раздач; по стандарту раздачи в файле
+// разделяются пустой строкой
+func OHHBulk(games []models.Game, actions map[string][]models.GameAction, viewer string) (string, error) {
+	var parts []string
+	for i := range games {
+		file, err := ToOHH(&games[i], actions[games[i].ID], viewer)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(file)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, string(data))
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// streetCards сколько карт доски открывается на улице
+func streetCards(street models.GameState) int {
+	if street == models.GameStateFlop {
+		return 3
+	}
+	return 1
+}
+
+func cardStrings(cards []models.Card) []string {
+	notation := make([]string, len(cards))
+	for i, card := range cards {
+		notation[i] = card.String()
+	}
+	return notation
+}
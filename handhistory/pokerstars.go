@@ -0,0 +1,293 @@
+package handhistory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"poker/game"
+	"poker/models"
+)
+
+var (
+	streetNames = map[models.GameState]string{
+		models.GameStateFlop:  "FLOP",
+		models.GameStateTurn:  "TURN",
+		models.GameStateRiver: "RIVER",
+	}
+	streetTitles = map[models.GameState]string{
+		models.GameStateFlop:  "Flop",
+		models.GameStateTurn:  "Turn",
+		models.GameStateRiver: "River",
+	}
+)
+
+// PokerStars выгружает раздачу в текстовом формате PokerStars.
+// Карты игроков видны только запросившему их игроку и тем, кто
+// вскрылся на шоудауне.
+func PokerStars(g *models.Game, actions []models.GameAction, viewer string) (string, error) {
+	h, err := newHand(g, actions, viewer)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	h.writeHeader(&b)
+
+	// Сколько фишек игрок вложил с начала раздачи, чтобы отметить олл-ины
+	committed := make(map[string]int)
+
+	for _, street := range streets {
+		if street != models.GameStatePreFlop {
+			board := h.boardFor(street)
+			if board == nil {
+				break
+			}
+			if len(board) == 3 {
+				fmt.Fprintf(&b, "*** FLOP *** %s\n", formatCards(board))
+			} else {
+				fmt.Fprintf(&b, "*** %s *** %s %s\n", streetNames[street], formatCards(board[:len(board)-1]), formatCards(board[len(board)-1:]))
+			}
+		}
+		h.writeStreet(&b, street, committed)
+	}
+
+	h.writeResults(&b)
+	h.writeSummary(&b)
+	return b.String(), nil
+}
+
+// PokerStarsBulk выгружает несколько раздач подряд, разделяя их пустыми строками
+func PokerStarsBulk(games []models.Game, actions map[string][]models.GameAction, viewer string) (string, error) {
+	var parts []string
+	for i := range games {
+		text, err := PokerStars(&games[i], actions[games[i].ID], viewer)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n\n\n"), nil
+}
+
+func (h *hand) writeHeader(b *strings.Builder) {
+	g := h.game
+	fmt.Fprintf(b, "PokerStars Hand #%d:  Hold'em No Limit (%d/%d) - %s\n", handNumber(g.ID), g.SmallBlind, g.BigBlind, formatTime(g.CreatedAt))
+
+	maxSeats := g.Table.MaxSeats
+	if maxSeats == 0 {
+		maxSeats = len(h.seats)
+	}
+	fmt.Fprintf(b, "Table 'Table %d' %d-max (Play Money) Seat #%d is the button\n", g.TableID, maxSeats, g.DealerPosition)
+
+	for _, player := range h.seats {
+		fmt.Fprintf(b, "Seat %d: %s (%d in chips)\n", player.Position, h.names[player.UserUUID], h.stacks[player.UserUUID])
+	}
+}
+
+// writeStreet выводит действия улицы; на префлопе перед ними блайнды и карты игрока
+func (h *hand) writeStreet(b *strings.Builder, street models.GameState, committed map[string]int) {
+	holeCardsShown := street != models.GameStatePreFlop
+
+	for _, action := range h.classifyStreet(street, committed) {
+		if !isBlind(action.Action) && !holeCardsShown {
+			h.writeHoleCards(b)
+			holeCardsShown = true
+		}
+
+		name := h.names[action.UserUUID]
+		allIn := ""
+		if action.AllIn {
+			allIn = " and is all-in"
+		}
+
+		switch action.Kind {
+		case models.ActionPostSmallBlind:
+			fmt.Fprintf(b, "%s: posts small blind %d%s\n", name, action.Amount, allIn)
+		case models.ActionPostBigBlind:
+			fmt.Fprintf(b, "%s: posts big blind %d%s\n", name, action.Amount, allIn)
+		case models.ActionFold:
+			fmt.Fprintf(b, "%s: folds\n", name)
+		case models.ActionCheck:
+			fmt.Fprintf(b, "%s: checks\n", name)
+		case models.ActionCall:
+			fmt.Fprintf(b, "%s: calls %d%s\n", name, action.Amount, allIn)
+		case models.ActionBet:
+			fmt.Fprintf(b, "%s: bets %d%s\n", name, action.Amount, allIn)
+		case models.ActionRaise:
+			fmt.Fprintf(b, "%s: raises %d to %d%s\n", name, action.By, action.To, allIn)
+		}
+	}
+
+	if !holeCardsShown {
+		h.writeHoleCards(b)
+	}
+}
+
+func (h *hand) writeHoleCards(b *strings.Builder) {
+	b.WriteString("*** HOLE CARDS ***\n")
+	for _, player := range h.seats {
+		if player.UserUUID == h.viewer && len(player.Cards) > 0 {
+			fmt.Fprintf(b, "Dealt to %s %s\n", h.names[player.UserUUID], formatCards(player.Cards))
+		}
+	}
+}
+
+// writeResults выводит возврат ставки, вскрытие и выигрыши банков
+func (h *hand) writeResults(b *strings.Builder) {
+	if h.uncalled > 0 {
+		fmt.Fprintf(b, "Uncalled bet (%d) returned to %s\n", h.uncalled, h.names[h.uncalledTo])
+	}
+
+	if len(h.shown) > 0 {
+		b.WriteString("*** SHOW DOWN ***\n")
+		for _, player := range h.seats {
+			if result, ok := h.shown[player.UserUUID]; ok {
+				fmt.Fprintf(b, "%s: shows %s (%s)\n", h.names[player.UserUUID], formatCards(h.holeCards(player)), description(result))
+			}
+		}
+	}
+
+	pots := h.pots()
+	// Сначала побочные банки, основной — последним
+	for i := len(pots) - 1; i >= 0; i-- {
+		for _, player := range h.seats {
+			if amount := pots[i].Payouts[player.UserUUID]; amount > 0 {
+				fmt.Fprintf(b, "%s collected %d from %s\n", h.names[player.UserUUID], amount, potName(i, len(pots)))
+			}
+		}
+	}
+
+	if len(h.shown) == 0 {
+		for _, player := range h.seats {
+			if h.payouts[player.UserUUID] > 0 {
+				fmt.Fprintf(b, "%s: doesn't show hand\n", h.names[player.UserUUID])
+			}
+		}
+	}
+}
+
+func (h *hand) writeSummary(b *strings.Builder) {
+	b.WriteString("*** SUMMARY ***\n")
+
+	pots := h.pots()
+	fmt.Fprintf(b, "Total pot %d", h.totalPot())
+	if len(pots) > 1 {
+		fmt.Fprintf(b, " Main pot %d.", pots[0].Amount)
+		for i := 1; i < len(pots); i++ {
+			fmt.Fprintf(b, " Side pot-%d %d.", i, pots[i].Amount)
+		}
+	}
+	b.WriteString(" | Rake 0\n")
+
+	if len(h.game.CommunityCards) > 0 {
+		fmt.Fprintf(b, "Board %s\n", formatCards(h.game.CommunityCards))
+	}
+
+	for _, player := range h.seats {
+		fmt.Fprintf(b, "Seat %d: %s%s %s\n", player.Position, h.names[player.UserUUID], h.roles(player), h.outcome(player))
+	}
+}
+
+// roles отметки кнопки и блайндов в итогах
+func (h *hand) roles(player models.GamePlayer) string {
+	var roles string
+	if player.Position == h.game.DealerPosition {
+		roles += " (button)"
+	}
+	switch player.UserUUID {
+	case h.smallBlind:
+		roles += " (small blind)"
+	case h.bigBlind:
+		roles += " (big blind)"
+	}
+	return roles
+}
+
+// outcome итог раздачи для игрока
+func (h *hand) outcome(player models.GamePlayer) string {
+	won := h.collected(player.UserUUID)
+
+	if result, ok := h.shown[player.UserUUID]; ok {
+		if won > 0 {
+			return fmt.Sprintf("showed %s and won (%d) with %s", formatCards(h.holeCards(player)), won, description(result))
+		}
+		return fmt.Sprintf("showed %s and lost with %s", formatCards(h.holeCards(player)), description(result))
+	}
+
+	switch street := h.foldStreet(player.UserUUID); street {
+	case "":
+	case models.GameStatePreFlop:
+		if player.TotalBet == 0 {
+			return "folded before Flop (didn't bet)"
+		}
+		return "folded before Flop"
+	default:
+		return "folded on the " + streetTitles[street]
+	}
+
+	if won > 0 {
+		return fmt.Sprintf("collected (%d)", won)
+	}
+	return "mucked"
+}
+
+// pots банки без невостребованной ставки, которая вернулась игроку
+func (h *hand) pots() []models.PotResult {
+	var pots []models.PotResult
+	for i, pot := range h.game.Showdown.Pots {
+		payouts := make(map[string]int, len(pot.Payouts))
+		for userUUID, amount := range pot.Payouts {
+			payouts[userUUID] = amount
+		}
+		pot.Payouts = payouts
+
+		if i == len(h.game.Showdown.Pots)-1 && h.uncalled > 0 {
+			pot.Amount -= h.uncalled
+			pot.Payouts[h.uncalledTo] -= h.uncalled
+			if pot.Amount <= 0 {
+				continue
+			}
+		}
+		pots = append(pots, pot)
+	}
+	return pots
+}
+
+func potName(i, total int) string {
+	switch {
+	case total == 1:
+		return "pot"
+	case i == 0:
+		return "main pot"
+	}
+	return fmt.Sprintf("side pot-%d", i)
+}
+
+// description описание комбинации на английском из итога вскрытия. В
+// раздачах, сыгранных до появления описаний, оно считается по картам.
+func description(result models.HandResult) string {
+	if text := result.Description["en"]; text != "" {
+		return text
+	}
+	if text := game.GetBestHand(result.Cards).Describe("en"); text != "" {
+		return text
+	}
+	return string(result.Category)
+}
+
+func formatCards(cards []models.Card) string {
+	notation := make([]string, len(cards))
+	for i, card := range cards {
+		notation[i] = card.String()
+	}
+	return "[" + strings.Join(notation, " ") + "]"
+}
+
+// formatTime время начала раздачи по восточному времени США, как у PokerStars
+func formatTime(t time.Time) string {
+	if location, err := time.LoadLocation("America/New_York"); err == nil {
+		return t.In(location).Format("2006/01/02 15:04:05") + " ET"
+	}
+	return t.UTC().Format("2006/01/02 15:04:05") + " UTC"
+}
@@ -0,0 +1,202 @@
+package handhistory
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // Время в истории PokerStars — по Нью-Йорку
+
+	"poker/game"
+	"poker/models"
+)
+
+// testSeat игрок тестовой раздачи
+type testSeat struct {
+	user  string
+	seat  int
+	chips int
+	cards string
+}
+
+// testMove ход игрока; для рейза amount — ставка "до", как ее принимает движок
+type testMove struct {
+	user   string
+	action models.PlayerAction
+	amount int
+}
+
+func mustCards(t *testing.T, s string) models.Cards {
+	t.Helper()
+	cards, err := models.ParseCards(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cards
+}
+
+// playHand проигрывает раздачу через движок так же, как сервер: блайнды
+// 10/20, кнопка на месте dealer, ходы moves. Журнал действий записывается,
+// как в applyAction. Если после последнего хода раздача сыграна, она
+// завершается.
+func playHand(t *testing.T, dealer int, seats []testSeat, board string, moves []testMove) (*models.Game, []models.GameAction) {
+	t.Helper()
+	g := &models.Game{
+		ID:             "9c5a1f0e-test-hand",
+		TableID:        7,
+		State:          models.GameStateWaiting,
+		CommunityCards: models.Cards{},
+		DealerPosition: dealer,
+		SmallBlind:     10,
+		BigBlind:       20,
+		CreatedAt:      time.Date(2026, 3, 14, 20, 30, 0, 0, time.UTC),
+		Table:          models.Table{ID: 7, MaxSeats: 6},
+	}
+	positions := make([]int, len(seats))
+	holeCards := make(map[int]models.Cards)
+	for i, seat := range seats {
+		positions[i] = seat.seat
+		holeCards[seat.seat] = mustCards(t, seat.cards)
+		g.Players = append(g.Players, models.GamePlayer{
+			GameID:   g.ID,
+			UserUUID: seat.user,
+			Position: seat.seat,
+			Cards:    models.Cards{},
+			Chips:    seat.chips,
+			User:     models.User{UUID: seat.user, Username: seat.user},
+		})
+	}
+	var order [][]models.Card
+	for _, position := range dealOrder(positions, dealer) {
+		order = append(order, holeCards[position])
+	}
+	deck, err := stackDeck(order, mustCards(t, board))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Deck = deck
+
+	engine := game.NewPokerEngine(g)
+	actions, err := engine.StartHand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, move := range moves {
+		player := playerByUUID(g, move.user)
+		chipsBefore, street := player.Chips, g.State
+		if err := engine.ProcessAction(move.user, move.action, move.amount); err != nil {
+			t.Fatalf("%s %s %d: %v", move.user, move.action, move.amount, err)
+		}
+		actions = append(actions, models.GameAction{
+			GameID:   g.ID,
+			UserUUID: move.user,
+			Action:   player.LastAction,
+			Amount:   chipsBefore - player.Chips,
+			Street:   street,
+		})
+		if engine.IsRoundComplete() {
+			if err := engine.AdvanceGameState(); err != nil {
+				t.Fatal(err)
+			}
+			if engine.IsRunoutNeeded() {
+				engine.RunOutBoard()
+			}
+		}
+	}
+	for i := range actions {
+		actions[i].ID = i + 1
+		actions[i].GameID = g.ID
+	}
+	if g.State == models.GameStateShowdown {
+		g.State = models.GameStateFinished
+	}
+	return g, actions
+}
+
+// threeWayHand раздача на троих до вскрытия: Кэрол собирает две пары
+// на ривере и выигрывает у пары королей Алисы, Боб сбрасывает на префлопе
+func threeWayHand(t *testing.T, moves int) (*models.Game, []models.GameAction) {
+	seats := []testSeat{
+		{"alice", 1, 1000, "AsKs"},
+		{"bob", 3, 1000, "7c2d"},
+		{"carol", 5, 1000, "QhJh"},
+	}
+	script := []testMove{
+		{"alice", models.ActionRaise, 60},
+		{"bob", models.ActionFold, 0},
+		{"carol", models.ActionCall, 0},
+		{"carol", models.ActionCheck, 0},
+		{"alice", models.ActionBet, 80},
+		{"carol", models.ActionCall, 0},
+		{"carol", models.ActionCheck, 0},
+		{"alice", models.ActionCheck, 0},
+		{"carol", models.ActionBet, 200},
+		{"alice", models.ActionCall, 0},
+	}
+	return playHand(t, 1, seats, "KdQc5sJd3h", script[:moves])
+}
+
+const threeWayHandStars = `PokerStars Hand #HANDNO:  Hold'em No Limit (10/20) - 2026/03/14 16:30:00 ET
+Table 'Table 7' 6-max (Play Money) Seat #1 is the button
+Seat 1: alice (1000 in chips)
+Seat 3: bob (1000 in chips)
+Seat 5: carol (1000 in chips)
+bob: posts small blind 10
+carol: posts big blind 20
+*** HOLE CARDS ***
+Dealt to alice [As Ks]
+alice: raises 40 to 60
+bob: folds
+carol: calls 40
+*** FLOP *** [Kd Qc 5s]
+carol: checks
+alice: bets 80
+carol: calls 80
+*** TURN *** [Kd Qc 5s] [Jd]
+carol: checks
+alice: checks
+*** RIVER *** [Kd Qc 5s Jd] [3h]
+carol: bets 200
+alice: calls 200
+*** SHOW DOWN ***
+alice: shows [As Ks] (Pair of Kings)
+carol: shows [Qh Jh] (Two Pair, Queens and Jacks)
+carol collected 690 from pot
+*** SUMMARY ***
+Total pot 690 | Rake 0
+Board [Kd Qc 5s Jd 3h]
+Seat 1: alice (button) showed [As Ks] and lost with Pair of Kings
+Seat 3: bob (small blind) folded before Flop
+Seat 5: carol (big blind) showed [Qh Jh] and won (690) with Two Pair, Queens and Jacks
+`
+
+func TestPokerStarsGolden(t *testing.T) {
+	g, actions := threeWayHand(t, 10)
+	if g.State != models.GameStateFinished {
+		t.Fatalf("hand is on %s", g.State)
+	}
+
+	got, err := PokerStars(g, actions, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(threeWayHandStars, "HANDNO", strconv.FormatUint(handNumber(g.ID), 10), 1)
+	if got != want {
+		t.Errorf("PokerStars history differs.\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPokerStarsDescriptionFallback(t *testing.T) {
+	// Итоги, сохраненные до появления описаний, описываются по картам
+	result := models.HandResult{
+		Category: models.HandFullHouse,
+		Cards:    mustCards(t, "QhQdQsJhJd"),
+	}
+	if got := description(result); got != "Full House, Queens full of Jacks" {
+		t.Errorf("description %q", got)
+	}
+	result.Description = map[string]string{"en": "Pair of Kings"}
+	if got := description(result); got != "Pair of Kings" {
+		t.Errorf("stored description ignored: %q", got)
+	}
+}
@@ -0,0 +1,466 @@
+package handhistory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"poker/game"
+	"poker/models"
+)
+
+var (
+	ErrUnsupportedHand = errors.New("unsupported hand")
+	ErrInvalidOHH      = errors.New("invalid hand history")
+	ErrReplayMismatch  = errors.New("replay does not match hand history")
+)
+
+// maxOHHPlayers больше игроков за столом движок не рассаживает
+const maxOHHPlayers = 10
+
+var ohhGameStates = map[string]models.GameState{
+	"Preflop": models.GameStatePreFlop,
+	"Flop":    models.GameStateFlop,
+	"Turn":    models.GameStateTurn,
+	"River":   models.GameStateRiver,
+}
+
+// ParseOHH читает файл OHH: одну раздачу, массив раздач или несколько
+// объектов подряд, разделенных пустыми строками
+func ParseOHH(data []byte) ([]OHHFile, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var files []OHHFile
+		if err := json.Unmarshal(data, &files); err != nil {
+			return nil, err
+		}
+		return files, nil
+	}
+
+	var files []OHHFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var file OHHFile
+		err := decoder.Decode(&file)
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+}
+
+// ReplayOHH проигрывает раздачу из OHH через PokerEngine: колода
+// составляется так, чтобы выпали известные карты, действия проверяются
+// на допустимость, а вложенные фишки, улицы и выигрыши сверяются с историей.
+// Возвращает сыгранную раздачу.
+func ReplayOHH(file *OHHFile) (*models.Game, error) {
+	ohh := &file.OHH
+	if ohh.GameType != "" && ohh.GameType != "Holdem" {
+		return nil, fmt.Errorf("%w: game type %s", ErrUnsupportedHand, ohh.GameType)
+	}
+	if ohh.BetLimit.BetType != "" && ohh.BetLimit.BetType != "NL" {
+		return nil, fmt.Errorf("%w: bet type %s", ErrUnsupportedHand, ohh.BetLimit.BetType)
+	}
+	if ohh.AnteAmount > 0 {
+		return nil, fmt.Errorf("%w: antes", ErrUnsupportedHand)
+	}
+	if err := validateOHH(ohh); err != nil {
+		return nil, err
+	}
+
+	toChips := chipConverter(ohh)
+	g := &models.Game{
+		ID:             "ohh-" + ohh.GameNumber,
+		State:          models.GameStateWaiting,
+		CommunityCards: models.Cards{},
+		DealerPosition: ohh.DealerSeat,
+		SmallBlind:     toChips(ohh.SmallBlindAmount),
+		BigBlind:       toChips(ohh.BigBlindAmount),
+	}
+	if startedAt, err := time.Parse(time.RFC3339, ohh.StartDateUTC); err == nil {
+		g.CreatedAt = startedAt
+	}
+
+	uuids := make(map[int]string)
+	for _, player := range ohh.Players {
+		uuids[player.ID] = fmt.Sprintf("ohh-player-%d", player.ID)
+		g.Players = append(g.Players, models.GamePlayer{
+			GameID:   g.ID,
+			UserUUID: uuids[player.ID],
+			Position: player.Seat,
+			Cards:    models.Cards{},
+			Chips:    toChips(player.StartingStack),
+			User:     models.User{UUID: uuids[player.ID], Username: player.Name},
+		})
+	}
+
+	rounds := append([]OHHRound(nil), ohh.Rounds...)
+	sort.SliceStable(rounds, func(i, j int) bool { return rounds[i].ID < rounds[j].ID })
+
+	deck, err := ohhDeck(ohh, rounds)
+	if err != nil {
+		return nil, err
+	}
+	g.Deck = deck
+
+	engine := game.NewPokerEngine(g)
+	blinds, err := engine.StartHand()
+	if err != nil {
+		return nil, err
+	}
+	postedBlinds := 0
+
+	for _, round := range rounds {
+		street, isBettingStreet := ohhGameStates[round.Street]
+		for _, action := range round.Actions {
+			mismatch := func(format string, args ...interface{}) error {
+				return fmt.Errorf("%w: action %d: %s", ErrReplayMismatch, action.ActionNumber, fmt.Sprintf(format, args...))
+			}
+
+			switch action.Action {
+			case OHHDealtCards, OHHShowsCards, OHHMucksCards:
+				continue
+			case OHHPostSB, OHHPostBB:
+				if postedBlinds >= len(blinds) {
+					return nil, mismatch("unexpected blind")
+				}
+				blind := blinds[postedBlinds]
+				postedBlinds++
+				if blind.UserUUID != uuids[action.PlayerID] || blind.Amount != toChips(action.Amount) {
+					return nil, mismatch("blind posted by %s for %d, engine expects %s for %d", uuids[action.PlayerID], toChips(action.Amount), blind.UserUUID, blind.Amount)
+				}
+				continue
+			case OHHFold, OHHCheck, OHHCall, OHHBet, OHHRaise:
+			default:
+				return nil, fmt.Errorf("%w: action %s", ErrUnsupportedHand, action.Action)
+			}
+
+			if !isBettingStreet || street != g.State {
+				return nil, mismatch("%s action on %s, engine is on %s", action.Action, round.Street, g.State)
+			}
+			userUUID := uuids[action.PlayerID]
+			if !engine.CanPlayerAct(userUUID) {
+				return nil, mismatch("player %d acts out of turn", action.PlayerID)
+			}
+
+			player := playerByUUID(g, userUUID)
+			chipsBefore := player.Chips
+			engineAction, amount := engineActionFor(action, toChips)
+			if err := engine.ProcessAction(userUUID, engineAction, amount); err != nil {
+				return nil, mismatch("%v", err)
+			}
+
+			committed := chipsBefore - player.Chips
+			switch action.Action {
+			case OHHCall, OHHBet:
+				if committed != toChips(action.Amount) {
+					return nil, mismatch("%s of %d, engine put in %d", action.Action, toChips(action.Amount), committed)
+				}
+			case OHHRaise:
+				if player.Bet != toChips(action.Amount) {
+					return nil, mismatch("raise to %d, engine raised to %d", toChips(action.Amount), player.Bet)
+				}
+			}
+
+			if engine.IsRoundComplete() {
+				if err := engine.AdvanceGameState(); err != nil {
+					return nil, err
+				}
+				if engine.IsRunoutNeeded() {
+					engine.RunOutBoard()
+				}
+			}
+		}
+	}
+
+	if g.State != models.GameStateShowdown {
+		return nil, fmt.Errorf("%w: hand is not complete after the last action (engine is on %s)", ErrReplayMismatch, g.State)
+	}
+	g.State = models.GameStateFinished
+
+	if err := checkWinnings(g, ohh, uuids, toChips); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// validateOHH проверяет раздачу до проигрывания: 2-10 игроков с разными
+// местами и ID, каждая известная карта принадлежит одному игроку или доске,
+// и известных карт не больше, чем можно раздать
+func validateOHH(ohh *OHH) error {
+	if n := len(ohh.Players); n < 2 || n > maxOHHPlayers {
+		return fmt.Errorf("%w: %d players, want 2-%d", ErrInvalidOHH, n, maxOHHPlayers)
+	}
+	ids := make(map[int]bool, len(ohh.Players))
+	seats := make(map[int]bool, len(ohh.Players))
+	for _, player := range ohh.Players {
+		if ids[player.ID] {
+			return fmt.Errorf("%w: duplicate player id %d", ErrInvalidOHH, player.ID)
+		}
+		if seats[player.Seat] {
+			return fmt.Errorf("%w: duplicate seat %d", ErrInvalidOHH, player.Seat)
+		}
+		ids[player.ID], seats[player.Seat] = true, true
+	}
+
+	// Владелец каждой карты: ID игрока или -1 для доски. Игрок может
+	// показать те же карты несколько раз (раздача, вскрытие).
+	const boardOwner = -1
+	owners := make(map[models.Card]int)
+	claim := func(notation string, owner int) error {
+		card, err := models.ParseCard(notation)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOHH, err)
+		}
+		if known, ok := owners[card]; ok && (known != owner || owner == boardOwner) {
+			return fmt.Errorf("%w: duplicate card %s", ErrInvalidOHH, card)
+		}
+		owners[card] = owner
+		return nil
+	}
+	for _, round := range ohh.Rounds {
+		for _, notation := range round.Cards {
+			if err := claim(notation, boardOwner); err != nil {
+				return err
+			}
+		}
+		for _, action := range round.Actions {
+			if len(action.Cards) > 0 && !ids[action.PlayerID] {
+				return fmt.Errorf("%w: cards of unknown player %d", ErrInvalidOHH, action.PlayerID)
+			}
+			for _, notation := range action.Cards {
+				if err := claim(notation, action.PlayerID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	held := make(map[int]int)
+	for _, owner := range owners {
+		held[owner]++
+	}
+	for owner, count := range held {
+		if owner == boardOwner && count > 5 {
+			return fmt.Errorf("%w: board has %d cards", ErrInvalidOHH, count)
+		}
+		if owner != boardOwner && count > 2 {
+			return fmt.Errorf("%w: player %d has %d hole cards", ErrInvalidOHH, owner, count)
+		}
+	}
+	return nil
+}
+
+// engineActionFor переводит действие OHH в действие движка
+func engineActionFor(action OHHAction, toChips func(float64) int) (models.PlayerAction, int) {
+	if action.IsAllIn && action.Action != OHHFold && action.Action != OHHCheck {
+		return models.ActionAllIn, 0
+	}
+	switch action.Action {
+	case OHHFold:
+		return models.ActionFold, 0
+	case OHHCheck:
+		return models.ActionCheck, 0
+	case OHHCall:
+		return models.ActionCall, 0
+	case OHHBet:
+		return models.ActionBet, toChips(action.Amount)
+	}
+	return models.ActionRaise, toChips(action.Amount)
+}
+
+// checkWinnings сверяет выигрыши движка с банками из истории. Если в
+// истории есть рейк, сравниваются только победители.
+func checkWinnings(g *models.Game, ohh *OHH, uuids map[int]string, toChips func(float64) int) error {
+	h, err := newHand(g, nil, "")
+	if err != nil {
+		return err
+	}
+
+	expected := make(map[string]int)
+	rake := 0.0
+	for _, pot := range ohh.Pots {
+		rake += pot.Rake + pot.Jackpot
+		for _, win := range pot.PlayerWins {
+			expected[uuids[win.PlayerID]] += toChips(win.WinAmount)
+		}
+	}
+
+	for _, player := range g.Players {
+		want, got := expected[player.UserUUID], h.collected(player.UserUUID)
+		if (rake == 0 && want != got) || (rake > 0 && (want > 0) != (got > 0)) {
+			return fmt.Errorf("%w: player %s won %d, engine paid %d", ErrReplayMismatch, player.UserUUID, want, got)
+		}
+	}
+	return nil
+}
+
+// ohhDeck колода для раздачи OHH по известным картам игроков и доски
+func ohhDeck(ohh *OHH, rounds []OHHRound) (models.Cards, error) {
+	holeCards := make(map[int][]models.Card)
+	var board []models.Card
+
+	parse := func(notation []string) ([]models.Card, error) {
+		cards := make([]models.Card, 0, len(notation))
+		for _, s := range notation {
+			card, err := models.ParseCard(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrUnsupportedHand, err)
+			}
+			cards = append(cards, card)
+		}
+		return cards, nil
+	}
+
+	for _, round := range rounds {
+		cards, err := parse(round.Cards)
+		if err != nil {
+			return nil, err
+		}
+		board = append(board, cards...)
+		for _, action := range round.Actions {
+			if len(action.Cards) == 0 || len(holeCards[action.PlayerID]) > 0 {
+				continue
+			}
+			cards, err := parse(action.Cards)
+			if err != nil {
+				return nil, err
+			}
+			if len(cards) != 2 {
+				return nil, fmt.Errorf("%w: player %d has %d hole cards", ErrUnsupportedHand, action.PlayerID, len(cards))
+			}
+			holeCards[action.PlayerID] = cards
+		}
+	}
+	seats := make([]int, len(ohh.Players))
+	bySeat := make(map[int]int)
+	for i, player := range ohh.Players {
+		seats[i] = player.Seat
+		bySeat[player.Seat] = player.ID
+	}
+	order := make([][]models.Card, 0, len(seats))
+	for _, seat := range dealOrder(seats, ohh.DealerSeat) {
+		order = append(order, holeCards[bySeat[seat]])
+	}
+	return stackDeck(order, board)
+}
+
+// dealOrder места в порядке раздачи карт, как в DealCards: начиная слева от кнопки
+func dealOrder(seats []int, dealer int) []int {
+	sorted := append([]int(nil), seats...)
+	sort.Ints(sorted)
+	first := 0
+	for i, seat := range sorted {
+		if seat > dealer {
+			first = i
+			break
+		}
+	}
+	return append(sorted[first:], sorted[:first]...)
+}
+
+// stackDeck составляет колоду, из которой движок раздаст известные карты
+// игроков (в порядке раздачи) и доски; неизвестные места заполняются
+// оставшимися картами
+func stackDeck(holeCards [][]models.Card, board []models.Card) (models.Cards, error) {
+	if len(board) > 5 {
+		return nil, fmt.Errorf("%w: board has %d cards", ErrUnsupportedHand, len(board))
+	}
+
+	n := len(holeCards)
+	if 2*n+8 > 52 {
+		return nil, fmt.Errorf("%w: %d players do not fit in one deck", ErrInvalidOHH, n)
+	}
+	slots := make([]*models.Card, 2*n+8) // Карманные карты, затем сжигание и доска по улицам
+	used := make(map[string]bool)
+	place := func(i int, card models.Card) error {
+		if used[card.String()] {
+			return fmt.Errorf("%w: duplicate card %s", ErrReplayMismatch, card)
+		}
+		used[card.String()] = true
+		slots[i] = &card
+		return nil
+	}
+	for i, cards := range holeCards {
+		for round, card := range cards {
+			if round > 1 {
+				break
+			}
+			if err := place(round*n+i, card); err != nil {
+				return nil, err
+			}
+		}
+	}
+	boardSlots := []int{1, 2, 3, 5, 7} // После каждой сжигаемой карты
+	for i, card := range board {
+		if err := place(2*n+boardSlots[i], card); err != nil {
+			return nil, err
+		}
+	}
+
+	rest := game.NewOrderedDeck()
+	deck := make(models.Cards, 0, 52)
+	for _, slot := range slots {
+		if slot == nil {
+			for used[rest[0].String()] {
+				rest = rest[1:]
+			}
+			slot = &rest[0]
+			used[rest[0].String()] = true
+		}
+		deck = append(deck, *slot)
+	}
+	for _, card := range rest {
+		if !used[card.String()] {
+			deck = append(deck, card)
+		}
+	}
+	return deck, nil
+}
+
+// chipConverter переводит суммы OHH в целые фишки: если суммы дробные
+// (реальные деньги), единицей становится цент
+func chipConverter(ohh *OHH) func(float64) int {
+	scale := 1.0
+	check := func(amount float64) {
+		if math.Abs(amount-math.Round(amount)) > 1e-9 {
+			scale = 100
+		}
+	}
+	check(ohh.SmallBlindAmount)
+	check(ohh.BigBlindAmount)
+	for _, player := range ohh.Players {
+		check(player.StartingStack)
+	}
+	for _, round := range ohh.Rounds {
+		for _, action := range round.Actions {
+			check(action.Amount)
+		}
+	}
+	for _, pot := range ohh.Pots {
+		for _, win := range pot.PlayerWins {
+			check(win.WinAmount)
+		}
+	}
+
+	return func(amount float64) int {
+		return int(math.Round(amount * scale))
+	}
+}
+
+func playerByUUID(g *models.Game, userUUID string) *models.GamePlayer {
+	for i := range g.Players {
+		if g.Players[i].UserUUID == userUUID {
+			return &g.Players[i]
+		}
+	}
+	return nil
+}
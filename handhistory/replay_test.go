@@ -0,0 +1,76 @@
+package handhistory
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReplayOHHRejectsInvalidHands(t *testing.T) {
+	players := func(n int) []OHHPlayer {
+		list := make([]OHHPlayer, n)
+		for i := range list {
+			list[i] = OHHPlayer{ID: i + 1, Seat: i + 1, StartingStack: 100}
+		}
+		return list
+	}
+	dealt := func(playerID int, cards ...string) OHHAction {
+		return OHHAction{PlayerID: playerID, Action: OHHDealtCards, Cards: cards}
+	}
+
+	tests := []struct {
+		name string
+		ohh  OHH
+	}{
+		{"one player", OHH{Players: players(1)}},
+		// Раньше на 23 игроках раздача выходила за колоду и сервер падал
+		{"more players than a deck can deal", OHH{Players: players(23)}},
+		{"eleven players", OHH{Players: players(11)}},
+		{"duplicate seat", OHH{Players: []OHHPlayer{{ID: 1, Seat: 1}, {ID: 2, Seat: 1}}}},
+		{"duplicate player id", OHH{Players: []OHHPlayer{{ID: 1, Seat: 1}, {ID: 1, Seat: 2}}}},
+		{"card in two hands", OHH{Players: players(2), Rounds: []OHHRound{
+			{ID: 0, Street: "Preflop", Actions: []OHHAction{dealt(1, "Ah", "Kd"), dealt(2, "Ah", "Qc")}},
+		}}},
+		{"hole card on the board", OHH{Players: players(2), Rounds: []OHHRound{
+			{ID: 0, Street: "Preflop", Actions: []OHHAction{dealt(1, "Ah", "Kd")}},
+			{ID: 1, Street: "Flop", Cards: []string{"Kd", "7c", "2s"}},
+		}}},
+		{"board card repeated", OHH{Players: players(2), Rounds: []OHHRound{
+			{ID: 1, Street: "Flop", Cards: []string{"Kd", "7c", "2s"}},
+			{ID: 2, Street: "Turn", Cards: []string{"7c"}},
+		}}},
+		{"six board cards", OHH{Players: players(2), Rounds: []OHHRound{
+			{ID: 1, Street: "Flop", Cards: []string{"Kd", "7c", "2s", "3s", "4s", "5s"}},
+		}}},
+		{"three hole cards", OHH{Players: players(2), Rounds: []OHHRound{
+			{ID: 0, Street: "Preflop", Actions: []OHHAction{dealt(1, "Ah", "Kd"), {PlayerID: 1, Action: OHHShowsCards, Cards: []string{"Ah", "Qd"}}}},
+		}}},
+		{"invalid card", OHH{Players: players(2), Rounds: []OHHRound{
+			{ID: 0, Street: "Preflop", Actions: []OHHAction{dealt(1, "Ah", "Xx")}},
+		}}},
+		{"cards of an unknown player", OHH{Players: players(2), Rounds: []OHHRound{
+			{ID: 0, Street: "Preflop", Actions: []OHHAction{dealt(7, "Ah", "Kd")}},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReplayOHH(&OHHFile{OHH: tt.ohh}); !errors.Is(err, ErrInvalidOHH) {
+				t.Errorf("error %v, want %v", err, ErrInvalidOHH)
+			}
+		})
+	}
+}
+
+func TestValidateOHHAllowsShownHoleCards(t *testing.T) {
+	ohh := OHH{
+		Players: []OHHPlayer{{ID: 1, Seat: 1}, {ID: 2, Seat: 3}},
+		Rounds: []OHHRound{
+			{ID: 0, Street: "Preflop", Actions: []OHHAction{{PlayerID: 1, Action: OHHDealtCards, Cards: []string{"Ah", "Kd"}}}},
+			{ID: 1, Street: "Flop", Cards: []string{"Qs", "7c", "2s"}},
+			{ID: 2, Street: "Showdown", Actions: []OHHAction{{PlayerID: 1, Action: OHHShowsCards, Cards: []string{"Kd", "Ah"}}}},
+		},
+	}
+	if err := validateOHH(&ohh); err != nil {
+		t.Errorf("valid hand rejected: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package handhistory
+
+import (
+	"errors"
+	"fmt"
+
+	"poker/game"
+	"poker/models"
+)
+
+var ErrHandNotStarted = errors.New("hand has not started yet")
+
+// Виды шагов воспроизведения
+const (
+	StepDeal     = "deal"     // Блайнды поставлены, карты розданы
+	StepAction   = "action"   // Ход игрока
+	StepStreet   = "street"   // Открыта новая улица
+	StepShowdown = "showdown" // Банки разыграны
+)
+
+// ReplayStep состояние раздачи после очередного шага
+type ReplayStep struct {
+	Index          int                    `json:"index"`
+	Type           string                 `json:"type"`
+	Actions        []models.GameAction    `json:"actions,omitempty"` // Блайнды для deal, ход игрока для action
+	State          models.GameState       `json:"state"`
+	CommunityCards models.Cards           `json:"community_cards"`
+	Pot            int                    `json:"pot"`
+	CurrentBet     int                    `json:"current_bet"`
+	CurrentPlayer  int                    `json:"current_player"` // Место игрока, который ходит, или -1
+	Players        []ReplayPlayer         `json:"players"`
+	Showdown       *models.ShowdownResult `json:"showdown,omitempty"`
+}
+
+// ReplayPlayer игрок на шаге воспроизведения
+type ReplayPlayer struct {
+	UserUUID   string              `json:"user_uuid"`
+	Username   string              `json:"username"`
+	Position   int                 `json:"position"`
+	Chips      int                 `json:"chips"`
+	Bet        int                 `json:"bet"`
+	TotalBet   int                 `json:"total_bet"`
+	IsFolded   bool                `json:"is_folded"`
+	IsAllIn    bool                `json:"is_all_in"`
+	LastAction models.PlayerAction `json:"last_action,omitempty"`
+	Cards      models.Cards        `json:"cards,omitempty"` // Только свои карты и вскрытые на шоудауне
+}
+
+// replay воспроизведение раздачи через движок
+type replay struct {
+	engine  *game.PokerEngine
+	game    *models.Game
+	visible map[string]bool // Чьи карты видны запросившему
+	names   map[string]string
+	steps   []ReplayStep
+}
+
+// Replay заново проигрывает раздачу через PokerEngine по журналу действий и
+// возвращает состояние после каждого шага: раздачи, хода, новой улицы и
+// вскрытия. Колода собирается из карт игроков и доски, поэтому раздачу можно
+// воспроизвести и до ее окончания. Если журнал расходится с движком или
+// с сохраненным состоянием, возвращается ErrReplayMismatch.
+func Replay(g *models.Game, actions []models.GameAction, viewer string) ([]ReplayStep, error) {
+	if g.State == models.GameStateWaiting || len(g.Players) == 0 {
+		return nil, ErrHandNotStarted
+	}
+	finished := g.State == models.GameStateFinished && g.Showdown != nil
+
+	r := &replay{
+		game: &models.Game{
+			ID:             g.ID,
+			TableID:        g.TableID,
+			State:          models.GameStateWaiting,
+			CommunityCards: models.Cards{},
+			DealerPosition: g.DealerPosition,
+			SmallBlind:     g.SmallBlind,
+			BigBlind:       g.BigBlind,
+		},
+		visible: map[string]bool{viewer: true},
+		names:   make(map[string]string),
+	}
+
+	payouts := make(map[string]int)
+	if finished {
+		for _, pot := range g.Showdown.Pots {
+			for userUUID, amount := range pot.Payouts {
+				payouts[userUUID] += amount
+			}
+		}
+		for _, result := range g.Showdown.Hands {
+			r.visible[result.UserUUID] = true
+		}
+	}
+
+	seats := make([]int, len(g.Players))
+	holeCards := make(map[int][]models.Card)
+	for i, player := range g.Players {
+		seats[i] = player.Position
+		holeCards[player.Position] = player.Cards
+		r.names[player.UserUUID] = playerName(player)
+		r.game.Players = append(r.game.Players, models.GamePlayer{
+			GameID:   g.ID,
+			UserUUID: player.UserUUID,
+			Position: player.Position,
+			Cards:    models.Cards{},
+			Chips:    player.Chips + player.TotalBet - payouts[player.UserUUID],
+		})
+	}
+	order := make([][]models.Card, 0, len(seats))
+	for _, seat := range dealOrder(seats, g.DealerPosition) {
+		order = append(order, holeCards[seat])
+	}
+	deck, err := stackDeck(order, g.CommunityCards)
+	if err != nil {
+		return nil, err
+	}
+	r.game.Deck = deck
+
+	r.engine = game.NewPokerEngine(r.game)
+	blinds, err := r.engine.StartHand()
+	if err != nil {
+		return nil, err
+	}
+
+	var posted []models.GameAction
+	for _, action := range actions {
+		if !isBlind(action.Action) {
+			continue
+		}
+		if len(posted) >= len(blinds) || blinds[len(posted)].UserUUID != action.UserUUID || blinds[len(posted)].Amount != action.Amount {
+			return nil, fmt.Errorf("%w: action %d: unexpected blind", ErrReplayMismatch, action.ID)
+		}
+		posted = append(posted, action)
+	}
+	if len(posted) == 0 {
+		posted = blinds
+	}
+	r.snapshot(StepDeal, posted)
+
+	for _, action := range actions {
+		if isBlind(action.Action) {
+			continue
+		}
+		if err := r.apply(action); err != nil {
+			return nil, fmt.Errorf("%w: action %d: %v", ErrReplayMismatch, action.ID, err)
+		}
+	}
+
+	if err := r.check(g, finished); err != nil {
+		return nil, err
+	}
+	return r.steps, nil
+}
+
+// apply выполняет записанное действие и открывает следующие улицы
+func (r *replay) apply(action models.GameAction) error {
+	if !r.engine.CanPlayerAct(action.UserUUID) {
+		return fmt.Errorf("%s acts out of turn", action.UserUUID)
+	}
+
+	player := playerByUUID(r.game, action.UserUUID)
+	chipsBefore := player.Chips
+
+	// В журнале сумма — вложенные действием фишки, а рейз движку задается "до"
+	amount := action.Amount
+	if action.Action == models.ActionRaise {
+		amount += player.Bet
+	}
+	if err := r.engine.ProcessAction(action.UserUUID, action.Action, amount); err != nil {
+		return err
+	}
+	if committed := chipsBefore - player.Chips; committed != action.Amount {
+		return fmt.Errorf("%s put in %d, engine put in %d", action.Action, action.Amount, committed)
+	}
+	r.snapshot(StepAction, []models.GameAction{action})
+
+	if !r.engine.IsRoundComplete() {
+		return nil
+	}
+	if err := r.advance(); err != nil {
+		return err
+	}
+	// Ставить больше некому: оставшиеся улицы открываются без торговли
+	if r.engine.IsRunoutNeeded() {
+		for r.engine.IsBettingRound() {
+			if err := r.advance(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *replay) advance() error {
+	if err := r.engine.AdvanceGameState(); err != nil {
+		return err
+	}
+	if r.game.State == models.GameStateShowdown {
+		r.snapshot(StepShowdown, nil)
+	} else {
+		r.snapshot(StepStreet, nil)
+	}
+	return nil
+}
+
+// check сверяет итог воспроизведения с сохраненным состоянием раздачи
+func (r *replay) check(g *models.Game, finished bool) error {
+	if finished {
+		if r.game.State != models.GameStateShowdown {
+			return fmt.Errorf("%w: hand is not complete after the last action (engine is on %s)", ErrReplayMismatch, r.game.State)
+		}
+		for _, player := range g.Players {
+			if got := playerByUUID(r.game, player.UserUUID).Chips; got != player.Chips {
+				return fmt.Errorf("%w: %s finished with %d, engine paid out to %d", ErrReplayMismatch, player.UserUUID, player.Chips, got)
+			}
+		}
+		return nil
+	}
+
+	if r.game.State != g.State || r.game.CurrentPlayer != g.CurrentPlayer || r.game.Pot != g.Pot {
+		return fmt.Errorf("%w: engine is on %s (seat %d to act, pot %d), game is on %s (seat %d to act, pot %d)", ErrReplayMismatch,
+			r.game.State, r.game.CurrentPlayer, r.game.Pot, g.State, g.CurrentPlayer, g.Pot)
+	}
+	return nil
+}
+
+// snapshot добавляет шаг с текущим состоянием движка
+func (r *replay) snapshot(stepType string, actions []models.GameAction) {
+	g := r.game
+	step := ReplayStep{
+		Index:          len(r.steps),
+		Type:           stepType,
+		Actions:        actions,
+		State:          g.State,
+		CommunityCards: append(models.Cards{}, g.CommunityCards...),
+		Pot:            g.Pot,
+		CurrentBet:     g.CurrentBet,
+		CurrentPlayer:  g.CurrentPlayer,
+	}
+	if !r.engine.IsBettingRound() {
+		step.CurrentPlayer = -1
+	}
+	if g.State == models.GameStateShowdown {
+		step.Showdown = g.Showdown
+	}
+
+	for _, player := range g.Players {
+		replayPlayer := ReplayPlayer{
+			UserUUID:   player.UserUUID,
+			Username:   r.names[player.UserUUID],
+			Position:   player.Position,
+			Chips:      player.Chips,
+			Bet:        player.Bet,
+			TotalBet:   player.TotalBet,
+			IsFolded:   player.IsFolded,
+			IsAllIn:    player.IsAllIn,
+			LastAction: player.LastAction,
+		}
+		if r.visible[player.UserUUID] {
+			replayPlayer.Cards = append(models.Cards{}, player.Cards...)
+		}
+		step.Players = append(step.Players, replayPlayer)
+	}
+	r.steps = append(r.steps, step)
+}
@@ -0,0 +1,157 @@
+package handhistory
+
+import (
+	"errors"
+	"testing"
+
+	"poker/models"
+)
+
+// boardPrefix открыта ли на шаге только часть настоящей доски
+func boardPrefix(step, board models.Cards) bool {
+	if len(step) > len(board) {
+		return false
+	}
+	for i := range step {
+		if step[i] != board[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReplayInProgressHidesUndealtBoard(t *testing.T) {
+	tests := []struct {
+		name      string
+		moves     int
+		wantState models.GameState
+		wantBoard int
+	}{
+		{"preflop", 1, models.GameStatePreFlop, 0},
+		{"flop", 5, models.GameStateFlop, 3},
+		{"turn", 7, models.GameStateTurn, 4},
+		{"river", 8, models.GameStateRiver, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, actions := threeWayHand(t, tt.moves)
+			if g.State != tt.wantState || len(g.CommunityCards) != tt.wantBoard {
+				t.Fatalf("hand is on %s with %d board cards", g.State, len(g.CommunityCards))
+			}
+			undealt := make(map[models.Card]bool)
+			for _, card := range mustCards(t, "KdQc5sJd3h")[tt.wantBoard:] {
+				undealt[card] = true
+			}
+
+			steps, err := Replay(g, actions, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, step := range steps {
+				if !boardPrefix(step.CommunityCards, g.CommunityCards) {
+					t.Errorf("step %d shows board %v, hand has %v", step.Index, step.CommunityCards, g.CommunityCards)
+				}
+				if step.Showdown != nil {
+					t.Errorf("step %d has a showdown in a hand that is not finished", step.Index)
+				}
+				for _, player := range step.Players {
+					if player.UserUUID != "alice" && len(player.Cards) > 0 {
+						t.Errorf("step %d shows %s cards to alice", step.Index, player.UserUUID)
+					}
+					for _, card := range player.Cards {
+						if undealt[card] {
+							t.Errorf("step %d leaks undealt card %s", step.Index, card)
+						}
+					}
+				}
+			}
+
+			last := steps[len(steps)-1]
+			if last.State != g.State || last.Pot != g.Pot || last.CurrentPlayer != g.CurrentPlayer {
+				t.Errorf("last step on %s (pot %d, seat %d to act), hand on %s (pot %d, seat %d to act)",
+					last.State, last.Pot, last.CurrentPlayer, g.State, g.Pot, g.CurrentPlayer)
+			}
+		})
+	}
+}
+
+func TestReplayFinishedHandMatchesStacks(t *testing.T) {
+	g, actions := threeWayHand(t, 10)
+	steps, err := Replay(g, actions, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Блайнды, десять ходов, флоп, терн, ривер и вскрытие
+	if len(steps) != 15 {
+		t.Errorf("%d steps, want 15", len(steps))
+	}
+	last := steps[len(steps)-1]
+	if last.Type != StepShowdown || last.Showdown == nil {
+		t.Fatalf("last step is %s", last.Type)
+	}
+	if !boardPrefix(g.CommunityCards, last.CommunityCards) || len(last.CommunityCards) != 5 {
+		t.Errorf("final board %v, want %v", last.CommunityCards, g.CommunityCards)
+	}
+
+	for _, player := range g.Players {
+		var got *ReplayPlayer
+		for i := range last.Players {
+			if last.Players[i].UserUUID == player.UserUUID {
+				got = &last.Players[i]
+			}
+		}
+		if got == nil {
+			t.Fatalf("%s missing from the last step", player.UserUUID)
+		}
+		if got.Chips != player.Chips {
+			t.Errorf("%s ends the replay with %d, stored stack is %d", player.UserUUID, got.Chips, player.Chips)
+		}
+		// Боб видит свои карты, Алиса и Кэрол вскрылись
+		if len(got.Cards) != 2 {
+			t.Errorf("%s cards hidden from bob after the showdown", player.UserUUID)
+		}
+	}
+
+	want := map[string]int{"alice": 660, "bob": 990, "carol": 1350}
+	for _, player := range last.Players {
+		if player.Chips != want[player.UserUUID] {
+			t.Errorf("%s finishes with %d, want %d", player.UserUUID, player.Chips, want[player.UserUUID])
+		}
+	}
+}
+
+func TestReplayRejectsMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(g *models.Game, actions []models.GameAction)
+	}{
+		// Стартовый стек считается по итогу, поэтому неверная выплата
+		// расходится с тем, что выплатит движок
+		{"wrong stored payout", func(g *models.Game, actions []models.GameAction) {
+			g.Showdown.Pots[0].Payouts["carol"] -= 10
+		}},
+		{"wrong call amount", func(g *models.Game, actions []models.GameAction) {
+			actions[4].Amount = 30 // Кэрол колирует 40
+		}},
+		{"out of turn", func(g *models.Game, actions []models.GameAction) {
+			actions[3].UserUUID = "carol" // Ходит Боб
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, actions := threeWayHand(t, 10)
+			tt.tamper(g, actions)
+			if _, err := Replay(g, actions, ""); !errors.Is(err, ErrReplayMismatch) {
+				t.Errorf("error %v, want %v", err, ErrReplayMismatch)
+			}
+		})
+	}
+}
+
+func TestReplayNotStarted(t *testing.T) {
+	g := &models.Game{State: models.GameStateWaiting, Players: []models.GamePlayer{{UserUUID: "alice"}}}
+	if _, err := Replay(g, nil, "alice"); !errors.Is(err, ErrHandNotStarted) {
+		t.Errorf("error %v, want %v", err, ErrHandNotStarted)
+	}
+}
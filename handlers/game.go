@@ -1,8 +1,8 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
-	"time"
 
 	"poker/database"
 	"poker/game"
@@ -10,7 +10,6 @@ import (
 	"poker/services"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/google/uuid"
 )
 
 // StartGame начинает новую игру за столом
@@ -21,10 +20,12 @@ import (
 // @Produce json
 // @Security TelegramAuth
 // @Param id path int true "ID стола"
+// @Param request body map[string]string false "Зерно игрока для тасовки (client_seed) и хеш зерна сервера, под который оно выбрано (seed_hash)"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tables/{id}/start-game [post]
 func StartGame(c fiber.Ctx) error {
@@ -46,114 +47,87 @@ func StartGame(c fiber.Ctx) error {
 		})
 	}
 
-	// Проверяем, нет ли уже активной игры
-	var existingGame models.Game
-	if err := database.DB.Where("table_id = ? AND state NOT IN (?)", tableID, []string{"finished"}).First(&existingGame).Error; err == nil {
+	// Раздачи за столами турниров начинает менеджер турниров
+	var table models.Table
+	if err := database.DB.First(&table, tableID).Error; err == nil && table.TournamentID != nil {
 		return c.Status(400).JSON(fiber.Map{
-			"error": "Game already in progress",
+			"error": "Tournament tables are dealt automatically",
 		})
 	}
 
-	// Получаем всех игроков за столом
-	var players []models.TablePlayer
-	if err := database.DB.Preload("User").Where("table_id = ?", tableID).Find(&players).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to get players",
-		})
+	// Зерно клиента для честной тасовки необязательно
+	var startData struct {
+		ClientSeed string `json:"client_seed"`
+		SeedHash   string `json:"seed_hash"`
 	}
-
-	if len(players) < 2 {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Need at least 2 players to start game",
-		})
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&startData); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
 	}
 
-	// Получаем информацию о столе
-	var table models.Table
-	if err := database.DB.First(&table, tableID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Table not found",
-		})
+	// Зерно принимается как зерно игрока для раздачи, которая сейчас начнется:
+	// хеш зерна сервера для нее уже опубликован
+	if startData.ClientSeed != "" {
+		if err := services.SetClientSeed(tableID, user.UUID, startData.ClientSeed, startData.SeedHash); err != nil {
+			return clientSeedError(c, tableID, err)
+		}
 	}
 
-	// Создаем новую игру
-	newGame := models.Game{
-		ID:             uuid.New().String(),
-		TableID:        tableID,
-		State:          models.GameStateWaiting,
-		Deck:           game.CreateDeck(),
-		CommunityCards: []models.Card{},
-		Pot:            0,
-		CurrentBet:     0,
-		DealerPosition: 0,
-		CurrentPlayer:  0,
-		SmallBlind:     table.BuyIn / 100, // 1% от buy-in
-		BigBlind:       table.BuyIn / 50,  // 2% от buy-in
-	}
-
-	// Создаем игроков в игре
-	var gamePlayers []models.GamePlayer
-	for i, player := range players {
-		gamePlayer := models.GamePlayer{
-			GameID:     newGame.ID,
-			UserUUID:   player.UserUUID,
-			Position:   i,
-			Cards:      []models.Card{},
-			Chips:      player.Chips,
-			Bet:        0,
-			IsFolded:   false,
-			IsAllIn:    false,
-			LastAction: "",
-		}
-		gamePlayers = append(gamePlayers, gamePlayer)
+	// Создаем раздачу и запускаем сессию стола: следующие раздачи
+	// начнутся автоматически после окончания текущей
+	if services.Sessions != nil {
+		services.Sessions.Start(tableID)
 	}
 
-	newGame.Players = gamePlayers
+	newGame, _, err := services.StartHand(tableID)
+	if err != nil && services.Sessions != nil && !errors.Is(err, services.ErrGameInProgress) {
+		services.Sessions.Stop(tableID)
+	}
 
-	// Сохраняем игру в базу данных
-	if err := database.DB.Create(&newGame).Error; err != nil {
+	switch {
+	case errors.Is(err, services.ErrGameInProgress):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Game already in progress",
+		})
+	case errors.Is(err, services.ErrNotEnoughPlayers):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Need at least 2 players to start game",
+		})
+	case errors.Is(err, services.ErrTableNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Table not found",
+		})
+	case err != nil:
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to create game",
 		})
 	}
 
-	// Сохраняем состояние игры в Redis
-	if services.Redis != nil {
-		services.Redis.SetGameState(newGame.ID, &newGame)
-	}
-
-	// Отправляем событие в Kafka
-	if services.Kafka != nil {
-		event := models.GameEvent{
-			Type:      "game_started",
-			GameID:    newGame.ID,
-			TableID:   tableID,
-			Data:      newGame,
-			Timestamp: time.Now(),
-		}
-		services.Kafka.PublishGameEvent(event)
-	}
-
-	return c.JSON(fiber.Map{
+	return respondJSON(c, fiber.Map{
 		"message": "Game started successfully",
-		"game":    newGame,
+		"game":    newGame.ViewFor(user.UUID),
 	})
 }
 
 // GetGameState возвращает текущее состояние игры
 // @Summary Получить состояние игры
-// @Description Возвращает текущее состояние игры по ID
+// @Description Возвращает текущее состояние игры по ID. Видны только свои карты; карты соперников — после вскрытия, колода не раскрывается. Пока раздача идет, она доступна только ее игрокам: остальные смотрят трансляцию для зрителей с задержкой
 // @Tags game
 // @Accept json
 // @Produce json
 // @Security TelegramAuth
 // @Param gameId path string true "ID игры"
-// @Success 200 {object} models.Game
+// @Success 200 {object} models.GameView
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /games/{gameId} [get]
 func GetGameState(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
 	gameID := c.Params("gameId")
 	if gameID == "" {
 		return c.Status(400).JSON(fiber.Map{
@@ -161,27 +135,35 @@ func GetGameState(c fiber.Ctx) error {
 		})
 	}
 
-	// Сначала пробуем получить из Redis
-	if services.Redis != nil {
-		if gameState, err := services.Redis.GetGameState(gameID); err == nil {
-			return c.JSON(gameState)
-		}
+	allowed, err := services.CanViewLive(gameID, user.UUID)
+	switch {
+	case errors.Is(err, services.ErrGameNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Game not found",
+		})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get game",
+		})
+	case !allowed:
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Hand is in progress: watch it through the spectator stream",
+		})
 	}
 
-	// Если не найдено в Redis, получаем из базы данных
-	var game models.Game
-	if err := database.DB.Preload("Players.User").Preload("Table").First(&game, "id = ?", gameID).Error; err != nil {
+	view, err := loadGameView(gameID, user.UUID)
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Game not found",
 		})
 	}
 
-	return c.JSON(game)
+	return respondJSON(c, view)
 }
 
 // PlayerAction обрабатывает действие игрока
 // @Summary Сделать ход в игре
-// @Description Обрабатывает игровое действие игрока (fold, call, raise, check, bet)
+// @Description Обрабатывает игровое действие игрока (fold, call, raise, check, bet, all_in)
 // @Tags game
 // @Accept json
 // @Produce json
@@ -209,120 +191,286 @@ func PlayerAction(c fiber.Ctx) error {
 		})
 	}
 
-	// Получаем состояние игры
-	var gameState models.Game
-	if services.Redis != nil {
-		if gs, err := services.Redis.GetGameState(gameID); err == nil {
-			gameState = *gs
+	gameState, legalActions, err := applyAction(user, gameID, actionData.Action, actionData.Amount)
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{
+				"error": fiberErr.Message,
+			})
 		}
+		return err
 	}
 
-	if gameState.ID == "" {
-		if err := database.DB.Preload("Players.User").First(&gameState, "id = ?", gameID).Error; err != nil {
-			return c.Status(404).JSON(fiber.Map{
-				"error": "Game not found",
+	return respondJSON(c, fiber.Map{
+		"message":       "Action processed successfully",
+		"game":          gameState.ViewFor(user.UUID),
+		"legal_actions": legalActions,
+	})
+}
+
+// applyAction проводит действие игрока через движок, сохраняет раздачу
+// и рассылает события. Ошибки возвращаются как *fiber.Error с HTTP-кодом.
+func applyAction(user *models.User, gameID, actionName string, actionAmount int) (*models.Game, *models.LegalActions, error) {
+	gameState, legalActions, err := services.ApplyAction(user.UUID, gameID, models.PlayerAction(actionName), actionAmount)
+	if err != nil {
+		var actionErr *services.ActionError
+		switch {
+		case errors.Is(err, services.ErrGameNotFound):
+			return nil, nil, fiber.NewError(404, "Game not found")
+		case errors.Is(err, services.ErrNotYourTurn):
+			return nil, nil, fiber.NewError(400, "It's not your turn or you cannot act")
+		case errors.As(err, &actionErr):
+			return nil, nil, fiber.NewError(400, actionErr.Error())
+		}
+		return nil, nil, fiber.NewError(500, "Failed to process action")
+	}
+	return gameState, legalActions, nil
+}
+
+// GetLegalActions возвращает допустимые действия игрока, который сейчас ходит
+// @Summary Допустимые действия
+// @Description Возвращает действия, доступные текущему игроку: fold, check, call с суммой, bet или raise с минимальной и максимальной суммой
+// @Tags game
+// @Accept json
+// @Produce json
+// @Security TelegramAuth
+// @Param gameId path string true "ID игры"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /games/{gameId}/legal-actions [get]
+func GetLegalActions(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	gameID := c.Params("gameId")
+	if gameID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid game ID",
+		})
+	}
+
+	gameState, err := services.LoadGame(gameID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Game not found",
+		})
+	}
+
+	legalActions := game.NewPokerEngine(gameState).LegalActions()
+
+	return c.JSON(fiber.Map{
+		"legal_actions": legalActions,
+		"is_your_turn":  legalActions != nil && legalActions.UserUUID == user.UUID,
+	})
+}
+
+// GetGameFairness возвращает данные для проверки честности тасовки
+// @Summary Проверка честности тасовки
+// @Description Возвращает хеш зерна сервера и зерно клиента; после раздачи также раскрытое зерно сервера и исходную колоду
+// @Tags game
+// @Accept json
+// @Produce json
+// @Security TelegramAuth
+// @Param gameId path string true "ID игры"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /games/{gameId}/fairness [get]
+func GetGameFairness(c fiber.Ctx) error {
+	gameID := c.Params("gameId")
+	if gameID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid game ID",
+		})
+	}
+
+	var gameState models.Game
+	if err := database.DB.First(&gameState, "id = ?", gameID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Game not found",
+		})
+	}
+
+	response := fiber.Map{
+		"seed_hash":   gameState.SeedHash,
+		"client_seed": gameState.ClientSeed,
+		"revealed":    false,
+	}
+
+	// Зерно сервера раскрывается только после окончания раздачи
+	if gameState.State == models.GameStateFinished && gameState.ServerSeed != "" {
+		deck, err := game.ShuffleDeck(gameState.ServerSeed, gameState.ClientSeed)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to rebuild deck",
 			})
 		}
+		response["revealed"] = true
+		response["server_seed"] = gameState.ServerSeed
+		response["deck"] = deck
 	}
 
-	// Создаем движок игры
-	engine := game.NewPokerEngine(&gameState)
+	return respondJSON(c, response)
+}
 
-	// Проверяем, может ли игрок действовать
-	if !engine.CanPlayerAct(user.UUID) {
+// GetTableSeed возвращает хеш зерна сервера для следующей раздачи стола
+// @Summary Зерно следующей раздачи
+// @Description Возвращает SHA-256 зерна сервера, которым будет перемешана следующая раздача за столом, и уже принятые зерна игроков. Зерно игрока выбирается после получения хеша
+// @Tags game
+// @Produce json
+// @Security TelegramAuth
+// @Param id path int true "ID стола"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tables/{id}/seed [get]
+func GetTableSeed(c fiber.Ctx) error {
+	tableID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
-			"error": "It's not your turn or you cannot act",
+			"error": "Invalid table ID",
 		})
 	}
 
-	// Обрабатываем действие
-	action := models.PlayerAction(actionData.Action)
-	if err := engine.ProcessAction(user.UUID, action, actionData.Amount); err != nil {
+	seedHash, err := services.NextSeedHash(tableID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get server seed",
+		})
+	}
+	seeds, err := services.ClientSeeds(tableID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get client seeds",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"seed_hash":    seedHash,
+		"client_seeds": seeds,
+	})
+}
+
+// SetTableClientSeed принимает зерно игрока для следующей раздачи стола
+// @Summary Зерно игрока
+// @Description Принимает зерно игрока для следующей раздачи. seed_hash — хеш зерна сервера из GET /tables/{id}/seed, под который выбрано зерно; если хеш уже сменился, возвращается 409 с текущим хешем
+// @Tags game
+// @Accept json
+// @Produce json
+// @Security TelegramAuth
+// @Param id path int true "ID стола"
+// @Param request body map[string]string true "client_seed и seed_hash"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tables/{id}/seed [post]
+func SetTableClientSeed(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	tableID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
-			"error": err.Error(),
+			"error": "Invalid table ID",
 		})
 	}
 
-	// Сохраняем действие в базу данных
-	gameAction := models.GameAction{
-		GameID:   gameID,
-		UserUUID: user.UUID,
-		Action:   action,
-		Amount:   actionData.Amount,
+	var seedData struct {
+		ClientSeed string `json:"client_seed"`
+		SeedHash   string `json:"seed_hash"`
+	}
+	if err := c.Bind().JSON(&seedData); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
 	}
-	database.DB.Create(&gameAction)
 
-	// Проверяем, завершен ли раунд
-	if engine.IsRoundComplete() {
-		engine.AdvanceGameState()
+	if err := services.SetClientSeed(tableID, user.UUID, seedData.ClientSeed, seedData.SeedHash); err != nil {
+		return clientSeedError(c, tableID, err)
 	}
 
-	// Обновляем состояние игры
-	if err := database.DB.Save(&gameState).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to save game state",
+	return c.JSON(fiber.Map{
+		"message":     "Client seed accepted",
+		"seed_hash":   seedData.SeedHash,
+		"client_seed": seedData.ClientSeed,
+	})
+}
+
+// clientSeedError ответ на отклоненное зерно игрока; при смене хеша зерна
+// сервера клиент получает текущий хеш, чтобы выбрать зерно заново
+func clientSeedError(c fiber.Ctx, tableID int, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidClientSeed):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Client seed must be 1-64 letters, digits, '-' or '_'",
+		})
+	case errors.Is(err, services.ErrNotSeated):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "You are not sitting at this table",
+		})
+	case errors.Is(err, services.ErrSeedHashMismatch):
+		seedHash, _ := services.NextSeedHash(tableID)
+		return c.Status(409).JSON(fiber.Map{
+			"error":     "Server seed hash has changed, choose the client seed again",
+			"seed_hash": seedHash,
 		})
 	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": "Failed to save client seed",
+	})
+}
 
-	// Обновляем Redis
-	if services.Redis != nil {
-		services.Redis.SetGameState(gameID, &gameState)
+// respondJSON отправляет ответ; с параметром ?cards=compact карты
+// записываются строками вида "Ah" вместо объектов
+func respondJSON(c fiber.Ctx, data interface{}) error {
+	if c.Query("cards") != "compact" {
+		return c.JSON(data)
 	}
 
-	// Отправляем событие в Kafka
-	if services.Kafka != nil {
-		playerActionEvent := models.PlayerActionEvent{
-			Action: action,
-			Amount: actionData.Amount,
-		}
+	body, err := models.MarshalCompact(data)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to encode response",
+		})
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(body)
+}
 
-		// Находим игрока
-		for _, player := range gameState.Players {
-			if player.UserUUID == user.UUID {
-				playerActionEvent.Player = player
-				break
+// loadGameView представление игры для игрока viewer, для зрителя viewer
+// пуст. Публичная часть берется из Redis, карты игрока — из базы; без кэша
+// игра целиком читается из базы.
+func loadGameView(gameID, viewer string) (*models.GameView, error) {
+	if services.Redis != nil {
+		if view, err := services.Redis.GetGameState(gameID); err == nil {
+			if viewer == "" {
+				return view, nil
 			}
-		}
-
-		event := models.GameEvent{
-			Type:      "player_action",
-			GameID:    gameID,
-			TableID:   gameState.TableID,
-			UserUUID:  user.UUID,
-			Data:      playerActionEvent,
-			Timestamp: time.Now(),
-		}
-		services.Kafka.PublishGameEvent(event)
-
-		// Если состояние игры изменилось
-		if engine.IsRoundComplete() {
-			stateEvent := models.GameStateEvent{
-				State:          gameState.State,
-				CommunityCards: gameState.CommunityCards,
-				Pot:            gameState.Pot,
-				CurrentBet:     gameState.CurrentBet,
-				CurrentPlayer:  gameState.CurrentPlayer,
+			cards, err := services.PlayerCards(gameID, viewer)
+			if err != nil {
+				return nil, err
 			}
-
-			stateEventMsg := models.GameEvent{
-				Type:      "game_state_changed",
-				GameID:    gameID,
-				TableID:   gameState.TableID,
-				Data:      stateEvent,
-				Timestamp: time.Now(),
+			if len(cards) > 0 {
+				view = view.WithCards(viewer, cards)
 			}
-			services.Kafka.PublishGameEvent(stateEventMsg)
+			return view, nil
 		}
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Action processed successfully",
-		"game":    gameState,
-	})
+	var gameState models.Game
+	if err := database.DB.Preload("Players.User").Preload("Table").First(&gameState, "id = ?", gameID).Error; err != nil {
+		return nil, err
+	}
+	return gameState.ViewFor(viewer), nil
 }
 
-// GetGameHistory возвращает историю действий игры
+// GetGameHistory возвращает историю действий игры. Пока раздача идет,
+// история доступна только ее игрокам, как и состояние игры.
 func GetGameHistory(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
 	gameID := c.Params("gameId")
 	if gameID == "" {
 		return c.Status(400).JSON(fiber.Map{
@@ -330,6 +478,22 @@ func GetGameHistory(c fiber.Ctx) error {
 		})
 	}
 
+	allowed, err := services.CanViewLive(gameID, user.UUID)
+	switch {
+	case errors.Is(err, services.ErrGameNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Game not found",
+		})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get game",
+		})
+	case !allowed:
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Hand is in progress: watch it through the spectator stream",
+		})
+	}
+
 	var actions []models.GameAction
 	if err := database.DB.Preload("User").Where("game_id = ?", gameID).Order("created_at ASC").Find(&actions).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
@@ -358,7 +522,12 @@ func GetActiveGames(c fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"games": games,
+	views := make([]*models.GameView, len(games))
+	for i := range games {
+		views[i] = games[i].ViewFor(user.UUID)
+	}
+
+	return respondJSON(c, fiber.Map{
+		"games": views,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"poker/database"
+	"poker/handhistory"
+	"poker/models"
+	"poker/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 500
+	maxImportSize       = 5 << 20
+)
+
+// bulkExporters форматы выгрузки нескольких раздач
+var bulkExporters = map[string]func([]models.Game, map[string][]models.GameAction, string) (string, error){
+	"pokerstars": handhistory.PokerStarsBulk,
+	"ohh":        handhistory.OHHBulk,
+}
+
+// ReplayedHand результат проверки одной импортированной раздачи
+type ReplayedHand struct {
+	GameNumber string       `json:"game_number"`
+	Valid      bool         `json:"valid"`
+	Error      string       `json:"error,omitempty"`
+	Game       *models.Game `json:"game,omitempty"`
+}
+
+// GetHandHistory выгружает завершенную раздачу для трекеров
+// @Summary История раздачи
+// @Description Возвращает завершенную раздачу текстом в формате PokerStars или в JSON Open Hand History (format=ohh). Доступно только участникам раздачи. Карты других игроков видны только если они вскрылись на шоудауне
+// @Tags game
+// @Produce plain,json
+// @Security TelegramAuth
+// @Param gameId path string true "ID игры"
+// @Param format query string false "Формат выгрузки (pokerstars, ohh)"
+// @Success 200 {string} string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /games/{gameId}/hand-history [get]
+func GetHandHistory(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	gameID := c.Params("gameId")
+
+	format := c.Query("format", "pokerstars")
+	if format != "pokerstars" && format != "ohh" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unsupported format",
+		})
+	}
+
+	var gameState models.Game
+	if err := database.DB.Preload("Players.User").Preload("Table").First(&gameState, "id = ?", gameID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Game not found",
+		})
+	}
+	if !playedIn(&gameState, user.UUID) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "You did not play in this hand",
+		})
+	}
+
+	var actions []models.GameAction
+	if err := database.DB.Where("game_id = ?", gameID).Order("id ASC").Find(&actions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get game history",
+		})
+	}
+
+	if format == "ohh" {
+		file, err := handhistory.ToOHH(&gameState, actions, user.UUID)
+		if errors.Is(err, handhistory.ErrHandNotFinished) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Hand is not finished yet",
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to export hand history",
+			})
+		}
+		return c.JSON(file)
+	}
+
+	text, err := handhistory.PokerStars(&gameState, actions, user.UUID)
+	if errors.Is(err, handhistory.ErrHandNotFinished) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Hand is not finished yet",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to export hand history",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(text)
+}
+
+// GetMyHandHistory выгружает последние завершенные раздачи пользователя
+// @Summary Выгрузка истории раздач пользователя
+// @Description Возвращает последние завершенные раздачи пользователя одним текстом в формате PokerStars или Open Hand History (format=ohh), от старых к новым
+// @Tags game
+// @Produce plain
+// @Security TelegramAuth
+// @Param format query string false "Формат выгрузки (pokerstars, ohh)"
+// @Param limit query int false "Количество раздач (по умолчанию 100, максимум 500)"
+// @Success 200 {string} string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /my-hand-history [get]
+func GetMyHandHistory(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	export, ok := bulkExporters[c.Query("format", "pokerstars")]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unsupported format",
+		})
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultHistoryLimit)))
+	if err != nil || limit <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid limit",
+		})
+	}
+	limit = min(limit, maxHistoryLimit)
+
+	var games []models.Game
+	if err := database.DB.
+		Joins("JOIN game_players ON games.id = game_players.game_id").
+		Where("game_players.user_uuid = ? AND games.state = ?", user.UUID, models.GameStateFinished).
+		Preload("Players.User").
+		Preload("Table").
+		Order("games.created_at DESC").
+		Limit(limit).
+		Find(&games).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get hand history",
+		})
+	}
+
+	// Трекеры ждут раздачи в хронологическом порядке
+	for i, j := 0, len(games)-1; i < j; i, j = i+1, j-1 {
+		games[i], games[j] = games[j], games[i]
+	}
+
+	gameIDs := make([]string, len(games))
+	for i, g := range games {
+		gameIDs[i] = g.ID
+	}
+	var actions []models.GameAction
+	if err := database.DB.Where("game_id IN ?", gameIDs).Order("id ASC").Find(&actions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get hand history",
+		})
+	}
+	actionsByGame := make(map[string][]models.GameAction)
+	for _, action := range actions {
+		actionsByGame[action.GameID] = append(actionsByGame[action.GameID], action)
+	}
+
+	text, err := export(games, actionsByGame, user.UUID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to export hand history",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(text)
+}
+
+// GetHandReplay воспроизводит раздачу по шагам
+// @Summary Пошаговое воспроизведение раздачи
+// @Description Заново проигрывает раздачу через игровой движок и возвращает состояние после каждого шага: раздачи, хода, новой улицы и вскрытия — банк, стеки, доску и того, кто ходит. Доступно только участникам раздачи. Карты других игроков видны только если они вскрылись на шоудауне
+// @Tags game
+// @Produce json
+// @Security TelegramAuth
+// @Param gameId path string true "ID игры"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /games/{gameId}/replay [get]
+func GetHandReplay(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	gameID := c.Params("gameId")
+
+	gameState, err := services.LoadGame(gameID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Game not found",
+		})
+	}
+	if !playedIn(gameState, user.UUID) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "You did not play in this hand",
+		})
+	}
+
+	var actions []models.GameAction
+	if err := database.DB.Where("game_id = ?", gameID).Order("id ASC").Find(&actions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get game history",
+		})
+	}
+
+	steps, err := handhistory.Replay(gameState, actions, user.UUID)
+	switch {
+	case errors.Is(err, handhistory.ErrHandNotStarted):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Hand has not started yet",
+		})
+	case errors.Is(err, handhistory.ErrReplayMismatch):
+		// Журнал не сходится с состоянием игры: это повод для разбирательства
+		return c.Status(409).JSON(fiber.Map{
+			"error":   "Hand history does not match game state",
+			"details": err.Error(),
+		})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to replay hand",
+		})
+	}
+
+	return respondJSON(c, fiber.Map{
+		"game_id": gameID,
+		"steps":   steps,
+	})
+}
+
+// playedIn проверяет, что пользователь участвовал в раздаче: историю
+// и воспроизведение видят только ее игроки
+func playedIn(gameState *models.Game, userUUID string) bool {
+	for _, player := range gameState.Players {
+		if player.UserUUID == userUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportHandHistory проигрывает раздачи из файла OHH через движок
+// @Summary Импорт истории раздач в формате OHH
+// @Description Принимает одну или несколько раздач Open Hand History (объект, массив или объекты через пустую строку), проигрывает их через игровой движок и сообщает, совпал ли результат с историей. Поддерживается безлимитный холдем без анте
+// @Tags tools
+// @Accept json
+// @Produce json
+// @Param hands body string true "Раздачи в формате OHH"
+// @Success 200 {array} ReplayedHand
+// @Failure 400 {object} map[string]string
+// @Router /tools/hand-history/replay [post]
+func ImportHandHistory(c fiber.Ctx) error {
+	body := c.Body()
+	if len(body) > maxImportSize {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Hand history is too large",
+		})
+	}
+
+	files, err := handhistory.ParseOHH(body)
+	if err != nil || len(files) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid OHH file",
+		})
+	}
+
+	results := make([]ReplayedHand, len(files))
+	for i := range files {
+		results[i].GameNumber = files[i].OHH.GameNumber
+		g, err := handhistory.ReplayOHH(&files[i])
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Valid = true
+		results[i].Game = g
+	}
+
+	return respondJSON(c, results)
+}
@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"poker/database"
+	"poker/models"
+	"poker/services"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	socketWriteWait  = 10 * time.Second
+	socketPongWait   = 60 * time.Second
+	socketPingPeriod = socketPongWait * 9 / 10
+	socketMaxMessage = 4096
+)
+
+// Авторизация уже проверена по init_data, поэтому источник не ограничиваем
+var socketUpgrader = websocket.FastHTTPUpgrader{
+	CheckOrigin: func(ctx *fasthttp.RequestCtx) bool { return true },
+}
+
+// socketRequest сообщение клиента
+type socketRequest struct {
+	Type   string `json:"type"` // action
+	GameID string `json:"game_id"`
+	Action string `json:"action"`
+	Amount int    `json:"amount"`
+}
+
+// TableSocket открывает WebSocket с событиями стола
+// @Summary События стола через WebSocket
+// @Description Подписывает игрока, сидящего за столом, на события стола: посадку и уход игроков, действия, смену улиц, вскрытие. Состояние раздачи в событиях показывает только свои карты. Через тот же сокет можно делать ходы. Браузер может передать init_data параметром init_data. События стола пронумерованы; после переподключения с last_seq приходят пропущенные события или снимок, если их уже нет в буфере
+// @Tags game
+// @Security TelegramAuth
+// @Param id path int true "ID стола"
+// @Param cards query string false "Запись карт (compact)"
+// @Param last_seq query int false "Номер последнего полученного события: сервер пришлет пропущенные события или снимок"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 426 {object} map[string]string
+// @Router /tables/{id}/ws [get]
+func TableSocket(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	tableID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid table ID",
+		})
+	}
+
+	if !websocket.FastHTTPIsWebSocketUpgrade(c.RequestCtx()) {
+		return c.Status(426).JSON(fiber.Map{
+			"error": "WebSocket upgrade required",
+		})
+	}
+
+	var tablePlayer models.TablePlayer
+	if err := database.DB.Where("table_id = ? AND user_uuid = ?", tableID, user.UUID).First(&tablePlayer).Error; err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "You are not sitting at this table",
+		})
+	}
+
+	if services.Hub == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Live updates are not available",
+		})
+	}
+
+	lastSeq, err := parseLastSeq(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid last_seq",
+		})
+	}
+
+	options := socketOptions{
+		user:    user,
+		tableID: tableID,
+		compact: c.Query("cards") == "compact",
+		lastSeq: lastSeq,
+	}
+	return socketUpgrader.Upgrade(c.RequestCtx(), func(conn *websocket.Conn) {
+		serveTableSocket(conn, options)
+	})
+}
+
+// SpectateTable открывает WebSocket для зрителя стола
+// @Summary Наблюдение за столом
+// @Description Трансляция событий стола для зрителя без авторизации. Видна только открытая информация: доска, ставки и карты, вскрытые на шоудауне. События приходят с задержкой spectator_delay стола (от 30 до 900 секунд); в событии game_finished открываются карманные карты игроков, вскрывшихся на шоудауне
+// @Tags tables
+// @Param id path int true "ID стола"
+// @Param cards query string false "Запись карт (compact)"
+// @Param last_seq query int false "Номер последнего полученного события"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 426 {object} map[string]string
+// @Router /public/tables/{id}/spectate [get]
+func SpectateTable(c fiber.Ctx) error {
+	tableID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid table ID",
+		})
+	}
+
+	if !websocket.FastHTTPIsWebSocketUpgrade(c.RequestCtx()) {
+		return c.Status(426).JSON(fiber.Map{
+			"error": "WebSocket upgrade required",
+		})
+	}
+
+	var table models.Table
+	if err := database.DB.First(&table, tableID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Table not found",
+		})
+	}
+
+	if services.Hub == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Live updates are not available",
+		})
+	}
+
+	lastSeq, err := parseLastSeq(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid last_seq",
+		})
+	}
+
+	options := socketOptions{
+		tableID: tableID,
+		compact: c.Query("cards") == "compact",
+		lastSeq: lastSeq,
+		delay:   services.SpectatorDelay(&table),
+	}
+	return socketUpgrader.Upgrade(c.RequestCtx(), func(conn *websocket.Conn) {
+		serveTableSocket(conn, options)
+	})
+}
+
+// parseLastSeq номер последнего полученного события из параметра last_seq,
+// -1 если клиент подключается впервые
+func parseLastSeq(c fiber.Ctx) (int64, error) {
+	value := c.Query("last_seq")
+	if value == "" {
+		return -1, nil
+	}
+	lastSeq, err := strconv.ParseInt(value, 10, 64)
+	if err == nil && lastSeq < 0 {
+		err = errors.New("negative last_seq")
+	}
+	return lastSeq, err
+}
+
+// socketOptions параметры подключения к событиям стола
+type socketOptions struct {
+	user    *models.User // nil для зрителя
+	tableID int
+	compact bool
+	lastSeq int64         // Последнее событие, которое клиент видел до переподключения, -1 для нового клиента
+	delay   time.Duration // Задержка трансляции для зрителя
+}
+
+// delayedMessage сообщение, которое зритель с задержкой получит в момент at
+type delayedMessage struct {
+	message interface{}
+	at      time.Time
+}
+
+// serveTableSocket пересылает клиенту события стола и принимает его ходы.
+// Писать в соединение может только эта горутина, чтение идет в отдельной.
+func serveTableSocket(conn *websocket.Conn, options socketOptions) {
+	defer conn.Close()
+
+	tableID, lastSeq := options.tableID, options.lastSeq
+	viewer := ""
+	var sub *services.Subscriber
+	if options.user != nil {
+		viewer = options.user.UUID
+		sub = services.Hub.Subscribe(tableID, viewer)
+	} else {
+		sub = services.Hub.Watch(tableID)
+	}
+	defer services.Hub.Unsubscribe(sub)
+
+	replies := make(chan interface{}, 1)
+	closed := make(chan struct{})
+	defer close(closed)
+	readerDone := make(chan struct{})
+	go readTableSocket(conn, options.user, replies, closed, readerDone)
+
+	write := func(message interface{}) bool {
+		var data []byte
+		var err error
+		if options.compact {
+			data, err = models.MarshalCompact(message)
+		} else {
+			data, err = json.Marshal(message)
+		}
+		if err != nil {
+			log.Printf("Не удалось закодировать сообщение WebSocket: %v", err)
+			return true
+		}
+		conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+		return conn.WriteMessage(websocket.TextMessage, data) == nil
+	}
+
+	// Зритель с задержкой получает все сообщения в том же порядке, но позже
+	var queue []delayedMessage
+	delayTimer := time.NewTimer(time.Hour)
+	delayTimer.Stop()
+	emit := func(message interface{}) bool {
+		if options.delay == 0 {
+			return write(message)
+		}
+		queue = append(queue, delayedMessage{message: message, at: time.Now().Add(options.delay)})
+		if len(queue) == 1 {
+			delayTimer.Reset(options.delay)
+		}
+		return true
+	}
+
+	// Подписка уже идет, поэтому между пропущенными событиями и новыми
+	// ничего не потеряется; повторы отбрасываются по номеру
+	sendSnapshot := func() bool {
+		snapshot := tableSnapshot(tableID, viewer)
+		lastSeq = snapshot.Seq
+		return emit(snapshot)
+	}
+
+	// Зрителю с задержкой после раздачи открываются карты, вскрытые на
+	// шоудауне; к этому моменту раздача уже сыграна
+	view := func(event models.TableEvent) models.TableEvent {
+		if options.delay > 0 && event.Type == "game_finished" {
+			return revealCards(event)
+		}
+		return sub.View(event)
+	}
+
+	// Переподключившийся клиент получает пропущенные события, если они
+	// еще в буфере, иначе — как и новый клиент — текущую раздачу
+	missed, ok := []models.TableEvent(nil), false
+	if lastSeq >= 0 {
+		missed, ok = services.Hub.Replay(tableID, lastSeq)
+	}
+	if !ok && !sendSnapshot() {
+		return
+	}
+	for _, event := range missed {
+		if !emit(view(event)) {
+			return
+		}
+		lastSeq = event.Seq
+	}
+
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case event := <-sub.Events():
+			switch eventOrder(lastSeq, event.Seq) {
+			case eventSeen:
+				continue
+			case eventGap:
+				// Событие потерялось по дороге: состояние стола присылаем заново
+				if !sendSnapshot() {
+					return
+				}
+				if eventOrder(lastSeq, event.Seq) == eventSeen {
+					continue
+				}
+			}
+			if event.Seq != 0 {
+				lastSeq = event.Seq
+			}
+			if !emit(view(event)) {
+				return
+			}
+		case <-delayTimer.C:
+			now := time.Now()
+			for len(queue) > 0 && !queue[0].at.After(now) {
+				if !write(queue[0].message) {
+					return
+				}
+				queue = queue[1:]
+			}
+			if len(queue) > 0 {
+				delayTimer.Reset(queue[0].at.Sub(now))
+			}
+		case reply := <-replies:
+			if !write(reply) {
+				return
+			}
+		case <-ping.C:
+			services.Hub.Touch(sub)
+			conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-sub.Done():
+			conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+			return
+		case <-readerDone:
+			return
+		}
+	}
+}
+
+// Как поступить с событием стола, пришедшим после события lastSeq
+const (
+	eventNext = iota // Следующее по порядку или без номера: отправляется
+	eventSeen        // Уже отправлено до переподключения или вошло в снимок
+	eventGap         // Перед ним потерялись события: нужен новый снимок
+)
+
+// eventOrder сравнивает номер события с последним отправленным клиенту.
+// События без номера отправлены, когда Redis был недоступен, и идут как есть.
+func eventOrder(lastSeq, seq int64) int {
+	switch {
+	case seq == 0 || seq == lastSeq+1:
+		return eventNext
+	case seq <= lastSeq:
+		return eventSeen
+	}
+	return eventGap
+}
+
+// revealCards открывает в событии конца раздачи карманные карты игроков,
+// вскрывшихся на шоудауне (Showdown.Hands). Сброшенные карты и карты
+// победителя без вскрытия остаются закрытыми. Используется только в
+// трансляции с задержкой.
+func revealCards(event models.TableEvent) models.TableEvent {
+	if event.Game == nil || event.Game.Showdown == nil || len(event.Game.Showdown.Hands) == 0 {
+		return event
+	}
+	shown := make([]string, len(event.Game.Showdown.Hands))
+	for i, hand := range event.Game.Showdown.Hands {
+		shown[i] = hand.UserUUID
+	}
+
+	var players []models.GamePlayer
+	if err := database.DB.Select("user_uuid", "cards").
+		Where("game_id = ? AND user_uuid IN ?", event.GameID, shown).
+		Find(&players).Error; err != nil {
+		log.Printf("Не удалось открыть карты раздачи %s: %v", event.GameID, err)
+		return event
+	}
+	for _, player := range players {
+		event.Game = event.Game.WithCards(player.UserUUID, player.Cards)
+	}
+	return event
+}
+
+// readTableSocket читает сообщения клиента и выполняет его ходы. Зритель
+// (user == nil) ходить не может.
+func readTableSocket(conn *websocket.Conn, user *models.User, replies chan<- interface{}, closed <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(socketMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req socketRequest
+		var reply interface{}
+		switch {
+		case json.Unmarshal(data, &req) != nil:
+			reply = fiber.Map{
+				"type":  "error",
+				"error": "Invalid message",
+			}
+		case req.Type == "action" && user == nil:
+			reply = fiber.Map{
+				"type":  "error",
+				"error": "Spectators cannot act",
+			}
+		case req.Type == "action":
+			reply = socketAction(user, req)
+		default:
+			reply = fiber.Map{
+				"type":  "error",
+				"error": "Unknown message type",
+			}
+		}
+
+		select {
+		case replies <- reply:
+		case <-closed:
+			return
+		}
+	}
+}
+
+// socketAction выполняет ход, присланный через WebSocket
+func socketAction(user *models.User, req socketRequest) fiber.Map {
+	gameState, legalActions, err := applyAction(user, req.GameID, req.Action, req.Amount)
+	if err != nil {
+		message := "Failed to process action"
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			message = fiberErr.Message
+		}
+		return fiber.Map{
+			"type":    "error",
+			"game_id": req.GameID,
+			"error":   message,
+		}
+	}
+
+	return fiber.Map{
+		"type":          "action_result",
+		"game_id":       req.GameID,
+		"game":          gameState.ViewFor(user.UUID),
+		"legal_actions": legalActions,
+	}
+}
+
+// tableSnapshot текущая раздача за столом глазами игрока; game пусто,
+// если раздача не идет. Seq — номер последнего события до снимка: события
+// после него клиент получит следом.
+func tableSnapshot(tableID int, viewer string) models.TableEvent {
+	snapshot := models.TableEvent{
+		Type:      "snapshot",
+		TableID:   tableID,
+		Timestamp: time.Now(),
+	}
+
+	seq, err := services.Hub.Seq(tableID)
+	if err != nil {
+		log.Printf("Не удалось получить номер события стола %d: %v", tableID, err)
+	}
+	snapshot.Seq = seq
+
+	var gameID string
+	database.DB.Model(&models.Game{}).
+		Where("table_id = ? AND state <> ?", tableID, models.GameStateFinished).
+		Order("created_at DESC").Limit(1).Pluck("id", &gameID)
+	if gameID == "" {
+		return snapshot
+	}
+
+	if view, err := loadGameView(gameID, viewer); err == nil {
+		snapshot.GameID = gameID
+		snapshot.Game = view
+	}
+	return snapshot
+}
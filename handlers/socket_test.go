@@ -0,0 +1,59 @@
+package handlers
+
+import "testing"
+
+func TestEventOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		lastSeq int64
+		seq     int64
+		want    int
+	}{
+		{"next event", 41, 42, eventNext},
+		{"first event of a new table", 0, 1, eventNext},
+		{"event without seq while Redis is down", 41, 0, eventNext},
+		{"already in the snapshot", 42, 42, eventSeen},
+		{"replayed before the live copy arrived", 42, 40, eventSeen},
+		{"events lost in between", 41, 44, eventGap},
+		{"new client before the snapshot", -1, 5, eventGap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventOrder(tt.lastSeq, tt.seq); got != tt.want {
+				t.Errorf("eventOrder(%d, %d) = %d, want %d", tt.lastSeq, tt.seq, got, tt.want)
+			}
+		})
+	}
+}
+
+// После переподключения клиент получает пропущенные события из буфера, а
+// живая подписка, начатая раньше, присылает часть из них еще раз: клиент
+// должен увидеть каждое событие ровно один раз и по порядку
+func TestEventOrderAfterReplay(t *testing.T) {
+	lastSeq := int64(10) // Последнее событие, которое клиент видел
+	var sent []int64
+	for _, seq := range []int64{11, 12, 13} { // Из буфера Replay
+		sent = append(sent, seq)
+		lastSeq = seq
+	}
+	for _, seq := range []int64{12, 13, 14, 15} { // Из подписки
+		switch eventOrder(lastSeq, seq) {
+		case eventSeen:
+			continue
+		case eventGap:
+			t.Fatalf("gap at %d after %d", seq, lastSeq)
+		}
+		sent = append(sent, seq)
+		lastSeq = seq
+	}
+
+	want := []int64{11, 12, 13, 14, 15}
+	if len(sent) != len(want) {
+		t.Fatalf("sent %v, want %v", sent, want)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Fatalf("sent %v, want %v", sent, want)
+		}
+	}
+}
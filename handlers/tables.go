@@ -3,6 +3,7 @@ package handlers
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 
 	"poker/database"
@@ -46,6 +47,8 @@ func GetTables(c fiber.Ctx) error {
 		}
 	}
 
+	countSpectators(tables)
+
 	response := models.TableResponse{
 		Tables: tables,
 	}
@@ -88,6 +91,78 @@ func GetTableByID(c fiber.Ctx) error {
 		})
 	}
 
+	tables := []models.Table{table}
+	countSpectators(tables)
+
+	return c.JSON(tables[0])
+}
+
+// countSpectators заполняет число зрителей столов
+func countSpectators(tables []models.Table) {
+	if services.Hub == nil || len(tables) == 0 {
+		return
+	}
+	tableIDs := make([]int, len(tables))
+	for i := range tables {
+		tableIDs[i] = tables[i].ID
+	}
+	counts := services.Hub.Spectators(tableIDs)
+	for i := range tables {
+		tables[i].Spectators = counts[tables[i].ID]
+	}
+}
+
+// UpdateTable меняет настройки стола
+// @Summary Настройки стола
+// @Description Меняет задержку трансляции стола для зрителей (spectator_delay, от 30 до 900 секунд). Новая задержка действует для зрителей, подключившихся после изменения. Доступно только операторам
+// @Tags tables
+// @Accept json
+// @Produce json
+// @Security TelegramAuth
+// @Param id path int true "ID стола"
+// @Param request body map[string]int true "Задержка трансляции в секундах (spectator_delay)"
+// @Success 200 {object} models.Table
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tables/{id} [patch]
+func UpdateTable(c fiber.Ctx) error {
+	tableID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid table ID",
+		})
+	}
+
+	var requestData struct {
+		SpectatorDelay *int `json:"spectator_delay"`
+	}
+	if err := c.Bind().JSON(&requestData); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if requestData.SpectatorDelay == nil || !services.ValidSpectatorDelay(*requestData.SpectatorDelay) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("Spectator delay must be from %d to %d seconds",
+				int(services.MinSpectatorDelay.Seconds()), int(services.MaxSpectatorDelay.Seconds())),
+		})
+	}
+
+	var table models.Table
+	if err := database.DB.First(&table, tableID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Table not found",
+		})
+	}
+	table.SpectatorDelay = *requestData.SpectatorDelay
+	if err := database.DB.Model(&table).Update("spectator_delay", table.SpectatorDelay).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update table",
+		})
+	}
+
 	return c.JSON(table)
 }
 
@@ -140,6 +215,14 @@ func JoinTable(c fiber.Ctx) error {
 		})
 	}
 
+	// За стол турнира рассаживает менеджер турниров
+	if table.TournamentID != nil {
+		tx.Rollback()
+		return c.Status(400).JSON(fiber.Map{
+			"error": "This is a tournament table, register for the tournament instead",
+		})
+	}
+
 	// Проверяем, достаточно ли средств для buy-in
 	if user.Balance < table.BuyIn {
 		tx.Rollback()
@@ -262,6 +345,7 @@ func JoinTable(c fiber.Ctx) error {
 			services.Kafka.PublishTableEvent(newTable.ID, "table_created", newTable)
 		}
 	}
+	publishPlayerJoined(tableID, user, seatNumber, table.BuyIn)
 
 	response := fiber.Map{
 		"message": "Successfully joined table",
@@ -280,6 +364,23 @@ func JoinTable(c fiber.Ctx) error {
 	return c.JSON(response)
 }
 
+// publishPlayerJoined сообщает подписчикам стола о новом игроке
+func publishPlayerJoined(tableID int, user *models.User, seatNumber, chips int) {
+	if services.Hub == nil {
+		return
+	}
+	services.Hub.Publish(models.TableEvent{
+		Type:     "player_joined",
+		TableID:  tableID,
+		UserUUID: user.UUID,
+		Data: fiber.Map{
+			"username":    user.Username,
+			"seat_number": seatNumber,
+			"chips":       chips,
+		},
+	})
+}
+
 // LeaveTable позволяет игроку покинуть стол
 func LeaveTable(c fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
@@ -325,6 +426,33 @@ func LeaveTable(c fiber.Ctx) error {
 		})
 	}
 
+	// Турнирные фишки на баланс не выводятся
+	if table.TournamentID != nil {
+		tx.Rollback()
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Cannot leave a tournament table",
+		})
+	}
+
+	// Пока идет раздача, часть фишек игрока лежит в банке, а стек на столе
+	// еще не пересчитан: выйти можно только после ее окончания
+	var inHand int64
+	if err := tx.Model(&models.GamePlayer{}).
+		Joins("JOIN games ON games.id = game_players.game_id").
+		Where("games.table_id = ? AND games.state <> ? AND game_players.user_uuid = ?", tableID, models.GameStateFinished, user.UUID).
+		Count(&inHand).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to check current hand",
+		})
+	}
+	if inHand > 0 {
+		tx.Rollback()
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Wait for the current hand to finish",
+		})
+	}
+
 	// Возвращаем фишки на баланс пользователя
 	user.Balance += tablePlayer.Chips
 	if err := tx.Save(user).Error; err != nil {
@@ -358,6 +486,14 @@ func LeaveTable(c fiber.Ctx) error {
 		})
 	}
 
+	if services.Hub != nil {
+		services.Hub.Publish(models.TableEvent{
+			Type:     "player_left",
+			TableID:  tableID,
+			UserUUID: user.UUID,
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Successfully left table",
 		"table":   table,
@@ -571,6 +707,7 @@ func JoinAvailableTable(c fiber.Ctx) error {
 			services.Kafka.PublishTableEvent(newTable.ID, "table_created", newTable)
 		}
 	}
+	publishPlayerJoined(availableTable.ID, user, seatNumber, availableTable.BuyIn)
 
 	response := fiber.Map{
 		"message": "Successfully joined table",
@@ -612,11 +749,12 @@ func createNewTable(tx *gorm.DB, category string) (*models.Table, error) {
 	}
 
 	newTable := &models.Table{
-		Category: category,
-		Blinds:   blinds,
-		BuyIn:    buyIn,
-		Players:  0,
-		MaxSeats: maxSeats,
+		Category:       category,
+		Blinds:         blinds,
+		BuyIn:          buyIn,
+		Players:        0,
+		MaxSeats:       maxSeats,
+		SpectatorDelay: services.DefaultSpectatorDelay,
 	}
 
 	if err := tx.Create(newTable).Error; err != nil {
@@ -632,7 +770,7 @@ func GetAvailableTables(c fiber.Ctx) error {
 	category = strings.ToUpper(category)
 
 	var tables []models.Table
-	query := database.DB.Where("players < max_seats")
+	query := database.DB.Where("players < max_seats AND tournament_id IS NULL")
 
 	if category != "ALL" {
 		query = query.Where("category = ?", category)
@@ -644,6 +782,8 @@ func GetAvailableTables(c fiber.Ctx) error {
 		})
 	}
 
+	countSpectators(tables)
+
 	// Группируем столы по категориям
 	tablesByCategory := make(map[string][]models.Table)
 	for _, table := range tables {
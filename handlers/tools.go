@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"poker/equity"
+	"poker/game"
+	"poker/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// CalculateEquity считает эквити рук против доски
+// @Summary Калькулятор эквити
+// @Description Считает долю побед, дележей и эквити для 2-10 рук против пустой или частичной доски (0, 3, 4 или 5 карт). Если вариантов доски немного, перебираются все, иначе используется Монте-Карло со случайным зерном или с зерном seed, если оно передано
+// @Tags tools
+// @Accept json
+// @Produce json
+// @Security TelegramAuth
+// @Param request body map[string]interface{} true "Руки (hands), доска (board) и необязательное зерно Монте-Карло (seed)"
+// @Success 200 {object} equity.Result
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /tools/equity [post]
+func CalculateEquity(c fiber.Ctx) error {
+	var equityData struct {
+		Hands [][]models.Card `json:"hands"`
+		Board []models.Card   `json:"board"`
+		Seed  *uint64         `json:"seed"`
+	}
+	if err := c.Bind().JSON(&equityData); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	result, err := equity.Calculate(equityData.Hands, equityData.Board, equity.Options{Seed: equityData.Seed})
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return respondJSON(c, result)
+}
+
+// CalculateRangeEquity считает эквити диапазонов рук друг против друга
+// @Summary Эквити диапазонов
+// @Description Разбирает диапазоны в стандартной нотации ("TT+, AKs, KQo, A5s-A2s"), убирает комбо с картами доски и мертвыми картами и считает эквити с разбивкой по комбинациям
+// @Tags tools
+// @Accept json
+// @Produce json
+// @Security TelegramAuth
+// @Param request body map[string]interface{} true "Диапазоны (ranges), доска (board), мертвые карты (dead) и необязательное зерно Монте-Карло (seed)"
+// @Success 200 {object} equity.RangeResult
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /tools/range-equity [post]
+func CalculateRangeEquity(c fiber.Ctx) error {
+	var rangeData struct {
+		Ranges []string      `json:"ranges"`
+		Board  []models.Card `json:"board"`
+		Dead   []models.Card `json:"dead"`
+		Seed   *uint64       `json:"seed"`
+	}
+	if err := c.Bind().JSON(&rangeData); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	ranges := make([][]game.Combo, 0, len(rangeData.Ranges))
+	for _, notation := range rangeData.Ranges {
+		combos, err := game.ParseRange(notation)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		ranges = append(ranges, combos)
+	}
+
+	result, err := equity.CalculateRanges(ranges, rangeData.Board, rangeData.Dead, equity.Options{Seed: rangeData.Seed})
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return respondJSON(c, result)
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"poker/database"
+	"poker/models"
+	"poker/services"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// CreateTournament создает турнир
+// @Summary Создать турнир
+// @Description Создает турнир и открывает регистрацию; доступно только операторам площадки. Взнос (fee) не больше 20% от buy-in и зачисляется на счет площадки. Sit & Go (type=sng) стартует, как только набрано max_players игроков; турнир со многими столами (type=mtt) — в starts_at. Если расписание блайндов или структура выплат не заданы, используются значения по умолчанию
+// @Tags tournaments
+// @Accept json
+// @Produce json
+// @Security TelegramAuth
+// @Param tournament body models.Tournament true "Параметры турнира: name, type, buy_in, fee, bounty, starting_stack, max_players, table_size, starts_at, late_reg_levels, rebuy_levels, max_rebuys, add_on_cost, add_on_chips, level_minutes, action_timeout, levels, payouts"
+// @Success 201 {object} models.Tournament
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tournaments [post]
+func CreateTournament(c fiber.Ctx) error {
+	var tournament models.Tournament
+	if err := c.Bind().JSON(&tournament); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	user := c.Locals("user").(*models.User)
+	tournament.ID = 0
+	tournament.Entries = nil
+	tournament.CreatedBy = user.UUID
+
+	if err := services.Tournaments.Create(&tournament); err != nil {
+		if errors.Is(err, services.ErrInvalidTournament) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create tournament",
+		})
+	}
+
+	return c.Status(201).JSON(tournament)
+}
+
+// GetTournaments возвращает список турниров
+// @Summary Список турниров
+// @Description Возвращает турниры, по умолчанию открытые для регистрации и идущие
+// @Tags tournaments
+// @Produce json
+// @Param state query string false "Состояние турнира" Enums(registering,running,finished,cancelled)
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /tournaments [get]
+// @Router /public/tournaments [get]
+func GetTournaments(c fiber.Ctx) error {
+	query := database.DB.Order("created_at DESC")
+	if state := c.Query("state"); state != "" {
+		query = query.Where("state = ?", state)
+	} else {
+		query = query.Where("state IN ?", []models.TournamentState{models.TournamentRegistering, models.TournamentRunning})
+	}
+
+	var tournaments []models.Tournament
+	if err := query.Find(&tournaments).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get tournaments",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"tournaments": tournaments,
+	})
+}
+
+// GetTournament возвращает турнир с участниками, местами и столами
+// @Summary Получить турнир
+// @Description Возвращает турнир, участников с занятыми местами, призами, ребаями, аддонами и наградами за головы и столы турнира
+// @Tags tournaments
+// @Produce json
+// @Param id path int true "ID турнира"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tournaments/{id} [get]
+// @Router /public/tournaments/{id} [get]
+func GetTournament(c fiber.Ctx) error {
+	tournamentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid tournament ID",
+		})
+	}
+
+	var tournament models.Tournament
+	// Сначала идут игроки, которые еще в турнире, затем места по порядку
+	entriesOrder := func(db *gorm.DB) *gorm.DB {
+		return db.Order("CASE WHEN place = 0 THEN 0 ELSE 1 END, place, id")
+	}
+	if err := database.DB.Preload("Entries", entriesOrder).Preload("Entries.User").First(&tournament, tournamentID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Tournament not found",
+		})
+	}
+
+	var tables []models.Table
+	if err := database.DB.Where("tournament_id = ?", tournamentID).Find(&tables).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get tournament tables",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"tournament": tournament,
+		"tables":     tables,
+	})
+}
+
+// RegisterTournament записывает игрока в турнир
+// @Summary Регистрация в турнире
+// @Description Списывает buy-in и взнос с баланса. Sit & Go стартует, как только зарегистрировано max_players игроков. В идущий турнир со многими столами можно зарегистрироваться до конца уровня late_reg_levels
+// @Tags tournaments
+// @Produce json
+// @Security TelegramAuth
+// @Param id path int true "ID турнира"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tournaments/{id}/register [post]
+func RegisterTournament(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	tournamentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid tournament ID",
+		})
+	}
+
+	tournament, err := services.Tournaments.Register(tournamentID, user)
+	if err != nil {
+		return tournamentError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "Successfully registered",
+		"tournament": tournament,
+		"balance":    user.Balance,
+	})
+}
+
+// UnregisterTournament снимает игрока с турнира
+// @Summary Отмена регистрации в турнире
+// @Description Снимает игрока с турнира до старта и возвращает buy-in и взнос на баланс
+// @Tags tournaments
+// @Produce json
+// @Security TelegramAuth
+// @Param id path int true "ID турнира"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tournaments/{id}/unregister [post]
+func UnregisterTournament(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	tournamentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid tournament ID",
+		})
+	}
+
+	if err := services.Tournaments.Unregister(tournamentID, user); err != nil {
+		return tournamentError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Registration cancelled",
+		"balance": user.Balance,
+	})
+}
+
+// RebuyTournament докупает стартовый стек
+// @Summary Ребай в турнире
+// @Description Списывает buy-in и взнос и добавляет стартовый стек. Доступно до конца уровня rebuy_levels, когда стек не больше стартового, и только между раздачами. Игрок, проигравший все фишки в период ребаев, остается за столом и может вернуться в игру
+// @Tags tournaments
+// @Produce json
+// @Security TelegramAuth
+// @Param id path int true "ID турнира"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tournaments/{id}/rebuy [post]
+func RebuyTournament(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	tournamentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid tournament ID",
+		})
+	}
+
+	entry, err := services.Tournaments.Rebuy(tournamentID, user)
+	if err != nil {
+		return tournamentError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Rebuy successful",
+		"entry":   entry,
+		"balance": user.Balance,
+	})
+}
+
+// AddOnTournament разово докупает фишки в перерыв
+// @Summary Аддон в турнире
+// @Description Списывает add_on_cost и добавляет add_on_chips фишек. Доступно один раз в перерыв и только между раздачами
+// @Tags tournaments
+// @Produce json
+// @Security TelegramAuth
+// @Param id path int true "ID турнира"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tournaments/{id}/add-on [post]
+func AddOnTournament(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	tournamentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid tournament ID",
+		})
+	}
+
+	entry, err := services.Tournaments.AddOn(tournamentID, user)
+	if err != nil {
+		return tournamentError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Add-on successful",
+		"entry":   entry,
+		"balance": user.Balance,
+	})
+}
+
+// tournamentError переводит ошибку менеджера турниров в ответ
+func tournamentError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTournamentNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Tournament not found",
+		})
+	case errors.Is(err, services.ErrRegistrationClosed):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Registration is closed",
+		})
+	case errors.Is(err, services.ErrAlreadyRegistered):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Already registered",
+		})
+	case errors.Is(err, services.ErrNotRegistered):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "You are not registered",
+		})
+	case errors.Is(err, services.ErrTournamentFull):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Tournament is full",
+		})
+	case errors.Is(err, services.ErrInsufficientBalance):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Insufficient balance for buy-in",
+		})
+	case errors.Is(err, services.ErrNotInTournament):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "You are no longer in the tournament",
+		})
+	case errors.Is(err, services.ErrRebuyClosed):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Rebuy period is over",
+		})
+	case errors.Is(err, services.ErrRebuyNotAllowed):
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrAddOnClosed):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Add-on is only available at the break",
+		})
+	case errors.Is(err, services.ErrAddOnTaken):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Add-on already taken",
+		})
+	case errors.Is(err, services.ErrHandInProgress):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Wait for the current hand to finish",
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": "Failed to update tournament entry",
+	})
+}
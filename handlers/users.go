@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"strconv"
+
 	"poker/database"
 	"poker/models"
 
@@ -66,4 +68,52 @@ func UpdateProfile(c fiber.Ctx) error {
 		"message": "Profile updated successfully",
 		"user":    user,
 	})
-}
\ No newline at end of file
+}
+
+// GetBalanceHistory возвращает движения по балансу пользователя
+// @Summary История баланса
+// @Description Возвращает журнал движений по балансу: турнирные buy-in, возвраты, ребаи, аддоны, призы и награды за головы. Новые записи идут первыми
+// @Tags users
+// @Produce json
+// @Security TelegramAuth
+// @Param tournament_id query int false "Только движения по турниру"
+// @Param limit query int false "Количество записей (по умолчанию 100, максимум 500)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /profile/transactions [get]
+func GetBalanceHistory(c fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultHistoryLimit)))
+	if err != nil || limit <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid limit",
+		})
+	}
+	limit = min(limit, maxHistoryLimit)
+
+	query := database.DB.Where("user_uuid = ?", user.UUID).Order("id DESC").Limit(limit)
+	if tournamentID := c.Query("tournament_id"); tournamentID != "" {
+		id, err := strconv.Atoi(tournamentID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid tournament ID",
+			})
+		}
+		query = query.Where("tournament_id = ?", id)
+	}
+
+	var transactions []models.BalanceTransaction
+	if err := query.Find(&transactions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get balance history",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"balance":      user.Balance,
+		"transactions": transactions,
+	})
+}
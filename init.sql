@@ -8,14 +8,88 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Создание таблицы турниров
+CREATE TABLE IF NOT EXISTS tournaments (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(10) NOT NULL,
+    state VARCHAR(20) DEFAULT 'registering',
+    buy_in INTEGER DEFAULT 0,
+    fee INTEGER DEFAULT 0,
+    bounty INTEGER DEFAULT 0,
+    rebuy_levels INTEGER DEFAULT 0,
+    max_rebuys INTEGER DEFAULT 0,
+    add_on_cost INTEGER DEFAULT 0,
+    add_on_chips INTEGER DEFAULT 0,
+    starting_stack INTEGER NOT NULL,
+    max_players INTEGER NOT NULL,
+    table_size INTEGER NOT NULL,
+    starts_at TIMESTAMP,
+    late_reg_levels INTEGER DEFAULT 0,
+    level_minutes INTEGER NOT NULL,
+    action_timeout INTEGER DEFAULT 0,
+    levels JSONB NOT NULL,
+    payouts JSONB NOT NULL,
+    prize_pool INTEGER DEFAULT 0,
+    level INTEGER DEFAULT 0,
+    level_started_at TIMESTAMP,
+    hand_for_hand BOOLEAN DEFAULT FALSE,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_by VARCHAR(36),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Создание таблицы участников турниров
+CREATE TABLE IF NOT EXISTS tournament_entries (
+    id SERIAL PRIMARY KEY,
+    tournament_id INTEGER REFERENCES tournaments(id) ON DELETE CASCADE,
+    user_uuid VARCHAR(36) REFERENCES users(uuid) ON DELETE CASCADE,
+    place INTEGER DEFAULT 0,
+    prize INTEGER DEFAULT 0,
+    eliminated_at TIMESTAMP,
+    eliminated_stack INTEGER DEFAULT 0,
+    eliminated_by JSONB,
+    rebuys INTEGER DEFAULT 0,
+    add_on BOOLEAN DEFAULT FALSE,
+    bounty INTEGER DEFAULT 0,
+    bounties_won INTEGER DEFAULT 0,
+    knockouts INTEGER DEFAULT 0,
+    registered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(tournament_id, user_uuid)
+);
+
+-- Создание журнала движений по балансу
+CREATE TABLE IF NOT EXISTS balance_transactions (
+    id SERIAL PRIMARY KEY,
+    user_uuid VARCHAR(36) REFERENCES users(uuid) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL,
+    amount INTEGER NOT NULL,
+    tournament_id INTEGER REFERENCES tournaments(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Счет площадки: взносы турниров и их возвраты
+CREATE TABLE IF NOT EXISTS house_transactions (
+    id SERIAL PRIMARY KEY,
+    type VARCHAR(30) NOT NULL,
+    amount INTEGER NOT NULL,
+    user_uuid VARCHAR(36) REFERENCES users(uuid) ON DELETE SET NULL,
+    tournament_id INTEGER REFERENCES tournaments(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Создание таблицы столов
 CREATE TABLE IF NOT EXISTS tables (
     id SERIAL PRIMARY KEY,
-    category VARCHAR(10) NOT NULL CHECK (category IN ('LOW', 'MID', 'VIP')),
+    category VARCHAR(10) NOT NULL CHECK (category IN ('LOW', 'MID', 'VIP', 'TOURNAMENT')),
     blinds VARCHAR(20) NOT NULL,
     buy_in INTEGER NOT NULL,
     players INTEGER DEFAULT 0,
     max_seats INTEGER NOT NULL,
+    tournament_id INTEGER REFERENCES tournaments(id) ON DELETE CASCADE,
+    spectator_delay INTEGER DEFAULT 30,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -41,10 +115,15 @@ CREATE TABLE IF NOT EXISTS games (
     community_cards JSONB DEFAULT '[]',
     pot INTEGER DEFAULT 0,
     current_bet INTEGER DEFAULT 0,
+    min_raise INTEGER DEFAULT 0,
     dealer_position INTEGER DEFAULT 0,
     current_player INTEGER DEFAULT 0,
     small_blind INTEGER,
     big_blind INTEGER,
+    showdown JSONB,
+    seed_hash VARCHAR(64),
+    client_seed TEXT,
+    server_seed VARCHAR(64),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -58,8 +137,10 @@ CREATE TABLE IF NOT EXISTS game_players (
     cards JSONB DEFAULT '[]',
     chips INTEGER DEFAULT 0,
     bet INTEGER DEFAULT 0,
+    total_bet INTEGER DEFAULT 0,
     is_folded BOOLEAN DEFAULT FALSE,
     is_all_in BOOLEAN DEFAULT FALSE,
+    has_acted BOOLEAN DEFAULT FALSE,
     last_action VARCHAR(10),
     UNIQUE(game_id, user_uuid),
     UNIQUE(game_id, position)
@@ -72,9 +153,28 @@ CREATE TABLE IF NOT EXISTS game_actions (
     user_uuid VARCHAR(36) REFERENCES users(uuid) ON DELETE CASCADE,
     action VARCHAR(10) NOT NULL,
     amount INTEGER DEFAULT 0,
+    street VARCHAR(20),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Зерно сервера для следующей раздачи стола: хеш публикуется до приема зерен игроков
+CREATE TABLE IF NOT EXISTS table_seeds (
+    table_id INTEGER PRIMARY KEY REFERENCES tables(id) ON DELETE CASCADE,
+    seed_hash VARCHAR(64) NOT NULL,
+    server_seed VARCHAR(64) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Зерна игроков для следующей раздачи стола
+CREATE TABLE IF NOT EXISTS client_seeds (
+    id SERIAL PRIMARY KEY,
+    table_id INTEGER REFERENCES tables(id) ON DELETE CASCADE,
+    user_uuid VARCHAR(36) REFERENCES users(uuid) ON DELETE CASCADE,
+    seed VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(table_id, user_uuid)
+);
+
 -- Вставка тестовых столов
 INSERT INTO tables (category, blinds, buy_in, players, max_seats) VALUES
 ('LOW', '1/2', 50, 0, 6),
@@ -96,6 +196,10 @@ CREATE INDEX IF NOT EXISTS idx_games_state ON games(state);
 CREATE INDEX IF NOT EXISTS idx_game_players_game_id ON game_players(game_id);
 CREATE INDEX IF NOT EXISTS idx_game_players_user_uuid ON game_players(user_uuid);
 CREATE INDEX IF NOT EXISTS idx_game_actions_game_id ON game_actions(game_id);
+CREATE INDEX IF NOT EXISTS idx_tables_tournament_id ON tables(tournament_id);
+CREATE INDEX IF NOT EXISTS idx_tournaments_state ON tournaments(state);
+CREATE INDEX IF NOT EXISTS idx_tournament_entries_tournament_id ON tournament_entries(tournament_id);
+CREATE INDEX IF NOT EXISTS idx_balance_transactions_user_uuid ON balance_transactions(user_uuid);
 
 -- Функция для обновления updated_at
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -114,4 +218,7 @@ CREATE TRIGGER update_tables_updated_at BEFORE UPDATE ON tables
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
 CREATE TRIGGER update_games_updated_at BEFORE UPDATE ON games
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_tournaments_updated_at BEFORE UPDATE ON tournaments
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
\ No newline at end of file
@@ -42,6 +42,11 @@ type TelegramUser struct {
 func AuthMiddleware() fiber.Handler {
 	return func(c fiber.Ctx) error {
 		initData := c.Get("x-init-data")
+		// Браузер не может передать заголовок при открытии WebSocket,
+		// поэтому для него init_data принимается и параметром запроса
+		if initData == "" && strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket") {
+			initData = c.Query("init_data")
+		}
 		if initData == "" {
 			return c.Status(401).JSON(fiber.Map{
 				"error": "Missing x-init-data header",
@@ -236,6 +241,11 @@ func getOrCreateUser(telegramUser TelegramUser) (*models.User, error) {
 func OptionalAuthMiddleware() fiber.Handler {
 	return func(c fiber.Ctx) error {
 		initData := c.Get("x-init-data")
+		// Браузер не может передать заголовок при открытии WebSocket,
+		// поэтому для него init_data принимается и параметром запроса
+		if initData == "" && strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket") {
+			initData = c.Query("init_data")
+		}
 		if initData == "" {
 			return c.Next()
 		}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"poker/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// OperatorMiddleware пропускает только операторов площадки: пользователей,
+// чьи Telegram ID перечислены через запятую в OPERATOR_TELEGRAM_IDS.
+// Ставится после AuthMiddleware.
+func OperatorMiddleware() fiber.Handler {
+	operators := parseOperatorIDs(os.Getenv("OPERATOR_TELEGRAM_IDS"))
+	return func(c fiber.Ctx) error {
+		user, ok := c.Locals("user").(*models.User)
+		if !ok || !operators[user.TelegramID] {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Operator access required",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// parseOperatorIDs разбирает список Telegram ID; некорректные значения пропускаются
+func parseOperatorIDs(value string) map[int64]bool {
+	operators := make(map[int64]bool)
+	for _, field := range strings.Split(value, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64); err == nil {
+			operators[id] = true
+		}
+	}
+	return operators
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Каноническая запись карты — два символа: значение (2-9, T, J, Q, K, A)
+// и масть (h, d, c, s), например "Ah", "Td". В API по умолчанию карты
+// остаются объектами {suit, rank, value}; в базе, Redis и событиях Kafka
+// хранится компактная запись. При чтении принимаются оба формата.
+
+const cardRanks = "23456789TJQKA"
+
+var (
+	suitLetters = map[string]byte{"hearts": 'h', "diamonds": 'd', "clubs": 'c', "spades": 's'}
+	letterSuits = map[byte]string{'h': "hearts", 'd': "diamonds", 'c': "clubs", 's': "spades"}
+	rankNames   = [...]string{"2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K", "A"}
+)
+
+// NewCard создает карту по значению (2-14) и масти
+func NewCard(value int, suit string) Card {
+	card := Card{Suit: suit, Value: value}
+	if value >= 2 && value <= 14 {
+		card.Rank = rankNames[value-2]
+	}
+	return card
+}
+
+// ParseCard разбирает карту из записи вида "Ah", "Td" или "10d"
+func ParseCard(s string) (Card, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return Card{}, fmt.Errorf("invalid card %q", s)
+	}
+
+	rank, suitLetter := strings.ToUpper(s[:len(s)-1]), s[len(s)-1]
+	if rank == "10" {
+		rank = "T"
+	}
+	suit, ok := letterSuits[suitLetter|0x20] // масть в любом регистре
+	if !ok || len(rank) != 1 || !strings.Contains(cardRanks, rank) {
+		return Card{}, fmt.Errorf("invalid card %q", s)
+	}
+	return NewCard(strings.Index(cardRanks, rank)+2, suit), nil
+}
+
+// ParseCards разбирает карты, записанные подряд ("AhKd") или через пробел или запятую
+func ParseCards(s string) ([]Card, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })
+	cards := []Card{}
+	for _, field := range fields {
+		for len(field) > 0 {
+			n := 2
+			if strings.HasPrefix(field, "10") {
+				n = 3
+			}
+			if len(field) < n {
+				return nil, fmt.Errorf("invalid card %q", field)
+			}
+			card, err := ParseCard(field[:n])
+			if err != nil {
+				return nil, err
+			}
+			cards = append(cards, card)
+			field = field[n:]
+		}
+	}
+	return cards, nil
+}
+
+// String возвращает запись карты из двух символов
+func (c Card) String() string {
+	letter, ok := suitLetters[c.Suit]
+	if !ok || c.Value < 2 || c.Value > 14 {
+		return "??"
+	}
+	return string([]byte{cardRanks[c.Value-2], letter})
+}
+
+// Valid проверяет, что карта существует в колоде
+func (c Card) Valid() bool {
+	_, ok := suitLetters[c.Suit]
+	return ok && c.Value >= 2 && c.Value <= 14
+}
+
+// MarshalText записывает карту в компактном виде
+func (c Card) MarshalText() ([]byte, error) {
+	if !c.Valid() {
+		return nil, fmt.Errorf("invalid card %s %d", c.Suit, c.Value)
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText читает карту в компактном виде
+func (c *Card) UnmarshalText(text []byte) error {
+	card, err := ParseCard(string(text))
+	if err != nil {
+		return err
+	}
+	*c = card
+	return nil
+}
+
+// cardObject формат карты объектом, как в API
+type cardObject struct {
+	Suit  string `json:"suit"`
+	Rank  string `json:"rank"`
+	Value int    `json:"value"`
+}
+
+// MarshalJSON оставляет в API формат объекта {suit, rank, value};
+// компактный формат включается через MarshalCompact
+func (c Card) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cardObject(c))
+}
+
+// UnmarshalJSON принимает и строку "Ah", и объект {suit, rank, value}
+func (c *Card) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return c.UnmarshalText([]byte(s))
+	}
+
+	var object cardObject
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*c = Card(object)
+	if c.Rank == "" {
+		*c = NewCard(c.Value, c.Suit)
+	}
+	return nil
+}
+
+// Cards набор карт для колонок jsonb: пишется компактно (["Ah","Td"]),
+// читаются и старые строки с объектами
+type Cards []Card
+
+// Value записывает карты в базу в компактном виде
+func (cs Cards) Value() (driver.Value, error) {
+	if cs == nil {
+		cs = Cards{}
+	}
+	notation := make([]string, len(cs))
+	for i, card := range cs {
+		text, err := card.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		notation[i] = string(text)
+	}
+	data, err := json.Marshal(notation)
+	return string(data), err
+}
+
+// Scan читает карты из базы в любом из форматов
+func (cs *Cards) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*cs = Cards{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported cards column type %T", src)
+	}
+	var cards []Card
+	if err := json.Unmarshal(data, &cards); err != nil {
+		return err
+	}
+	*cs = cards
+	return nil
+}
+
+// MarshalCompact сериализует значение в JSON, записывая все карты
+// в компактном виде. Используется для Redis, Kafka и по запросу в API.
+func MarshalCompact(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(compactCards(tree))
+}
+
+// compactCards заменяет объекты карт строками
+func compactCards(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if card, ok := cardFromObject(v); ok {
+			return card.String()
+		}
+		for key, child := range v {
+			v[key] = compactCards(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = compactCards(child)
+		}
+	}
+	return node
+}
+
+// cardFromObject распознает объект {suit, rank, value}
+func cardFromObject(object map[string]interface{}) (Card, bool) {
+	if len(object) != 3 {
+		return Card{}, false
+	}
+	suit, okSuit := object["suit"].(string)
+	_, okRank := object["rank"].(string)
+	number, okValue := object["value"].(json.Number)
+	if !okSuit || !okRank || !okValue {
+		return Card{}, false
+	}
+	value, err := number.Int64()
+	if err != nil {
+		return Card{}, false
+	}
+	card := Card{Suit: suit, Value: int(value)}
+	return card, card.Valid()
+}
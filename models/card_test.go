@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseCardRoundTrip(t *testing.T) {
+	for _, suit := range []string{"hearts", "diamonds", "clubs", "spades"} {
+		for value := 2; value <= 14; value++ {
+			card := NewCard(value, suit)
+			parsed, err := ParseCard(card.String())
+			if err != nil {
+				t.Fatalf("%s: %v", card, err)
+			}
+			if parsed != card {
+				t.Errorf("%s parsed back as %+v, want %+v", card, parsed, card)
+			}
+		}
+	}
+}
+
+func TestParseCard(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Card
+	}{
+		{"Ah", Card{Suit: "hearts", Rank: "A", Value: 14}},
+		{"Td", Card{Suit: "diamonds", Rank: "10", Value: 10}},
+		{"10d", Card{Suit: "diamonds", Rank: "10", Value: 10}},
+		{"kS", Card{Suit: "spades", Rank: "K", Value: 13}},
+		{" 2c ", Card{Suit: "clubs", Rank: "2", Value: 2}},
+	}
+	for _, tt := range tests {
+		got, err := ParseCard(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("%q parsed as %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "A", "Ax", "1h", "11h", "Ahh"} {
+		if card, err := ParseCard(in); err == nil {
+			t.Errorf("%q parsed as %+v, want an error", in, card)
+		}
+	}
+}
+
+func TestParseCards(t *testing.T) {
+	for _, in := range []string{"AhKd10c", "Ah Kd Tc", "Ah,Kd, 10c"} {
+		cards, err := ParseCards(in)
+		if err != nil {
+			t.Fatalf("%q: %v", in, err)
+		}
+		if got := Cards(cards).notation(); got != "AhKdTc" {
+			t.Errorf("%q parsed as %s, want AhKdTc", in, got)
+		}
+	}
+	if _, err := ParseCards("AhK"); err == nil {
+		t.Error("AhK parsed, want an error")
+	}
+}
+
+func TestCardJSON(t *testing.T) {
+	card := NewCard(12, "spades")
+	data, err := json.Marshal(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"suit":"spades","rank":"Q","value":12}` {
+		t.Errorf("marshaled as %s", data)
+	}
+
+	for _, in := range []string{`"Qs"`, `{"suit":"spades","rank":"Q","value":12}`, `{"suit":"spades","value":12}`} {
+		var got Card
+		if err := json.Unmarshal([]byte(in), &got); err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got != card {
+			t.Errorf("%s unmarshaled as %+v, want %+v", in, got, card)
+		}
+	}
+}
+
+func TestMarshalCompact(t *testing.T) {
+	type hand struct {
+		Board   []Card         `json:"board"`
+		Players map[string]any `json:"players"`
+		Pot     int            `json:"pot"`
+		Meta    map[string]any `json:"meta"`
+	}
+	value := hand{
+		Board:   []Card{NewCard(14, "hearts"), NewCard(10, "diamonds"), NewCard(2, "clubs")},
+		Players: map[string]any{"u1": []Card{NewCard(13, "spades"), NewCard(13, "hearts")}},
+		Pot:     150,
+		// Похожий на карту объект с лишним полем или без масти не трогается
+		Meta: map[string]any{
+			"extra":   map[string]any{"suit": "hearts", "rank": "A", "value": 14, "id": 1},
+			"unknown": map[string]any{"suit": "stars", "rank": "A", "value": 14},
+		},
+	}
+
+	data, err := MarshalCompact(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"board":["Ah","Td","2c"],"meta":{"extra":{"id":1,"rank":"A","suit":"hearts","value":14},` +
+		`"unknown":{"rank":"A","suit":"stars","value":14}},"players":{"u1":["Ks","Kh"]},"pot":150}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	// Компактная запись читается обратно в те же карты
+	var decoded struct {
+		Board   []Card            `json:"board"`
+		Players map[string][]Card `json:"players"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Board, value.Board) || !reflect.DeepEqual(decoded.Players["u1"], value.Players["u1"]) {
+		t.Errorf("decoded %+v", decoded)
+	}
+}
+
+func TestCardsValueScan(t *testing.T) {
+	cards := Cards{NewCard(14, "hearts"), NewCard(10, "diamonds")}
+	stored, err := cards.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != `["Ah","Td"]` {
+		t.Errorf("stored as %v", stored)
+	}
+
+	tests := []struct {
+		name string
+		src  interface{}
+		want Cards
+	}{
+		{"compact string", stored, cards},
+		{"compact bytes", []byte(`["Ah","Td"]`), cards},
+		{"legacy objects", `[{"suit":"hearts","rank":"A","value":14},{"suit":"diamonds","rank":"10","value":10}]`, cards},
+		{"null", nil, Cards{}},
+		{"empty", `[]`, Cards{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Cards
+			if err := got.Scan(tt.src); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scanned %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if stored, err := Cards(nil).Value(); err != nil || stored != "[]" {
+		t.Errorf("nil cards stored as %v, %v; want []", stored, err)
+	}
+	if _, err := (Cards{{Suit: "stars", Value: 3}}).Value(); err == nil {
+		t.Error("invalid card stored, want an error")
+	}
+	var got Cards
+	if err := got.Scan(42); err == nil {
+		t.Error("scanned an int, want an error")
+	}
+}
+
+// notation запись карт подряд для сравнения в тестах
+func (cs Cards) notation() string {
+	s := ""
+	for _, card := range cs {
+		s += card.String()
+	}
+	return s
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,11 +21,14 @@ type User struct {
 
 type Table struct {
 	ID        int       `json:"id" gorm:"primaryKey;autoIncrement"`
-	Category  string    `json:"category" gorm:"type:varchar(10);check:category IN ('LOW','MID','VIP')"`
+	Category  string    `json:"category" gorm:"type:varchar(10);check:category IN ('LOW','MID','VIP','TOURNAMENT')"`
 	Blinds    string    `json:"blinds" gorm:"type:varchar(20);not null"`
 	BuyIn     int       `json:"buy_in" gorm:"not null"`
 	Players   int       `json:"players" gorm:"default:0"`
 	MaxSeats  int       `json:"max_seats" gorm:"not null"`
+	TournamentID *int   `json:"tournament_id,omitempty"` // Стол турнира: фишки турнирные, сесть и выйти самому нельзя
+	Spectators int      `json:"spectators" gorm:"-"` // Зрители считаются в Redis, в базе не хранятся
+	SpectatorDelay int  `json:"spectator_delay" gorm:"default:30"` // Задержка трансляции для зрителей в секундах, от 30 до 900
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -94,6 +100,27 @@ const (
 	ActionRaise PlayerAction = "raise"
 	ActionCheck PlayerAction = "check"
 	ActionBet   PlayerAction = "bet"
+	ActionAllIn PlayerAction = "all_in"
+
+	// Обязательные ставки, которые движок ставит сам в начале раздачи
+	ActionPostSmallBlind PlayerAction = "post_sb"
+	ActionPostBigBlind   PlayerAction = "post_bb"
+)
+
+// HandCategory категория покерной комбинации
+type HandCategory string
+
+const (
+	HandHighCard      HandCategory = "high_card"
+	HandPair          HandCategory = "pair"
+	HandTwoPair       HandCategory = "two_pair"
+	HandThreeOfAKind  HandCategory = "three_of_a_kind"
+	HandStraight      HandCategory = "straight"
+	HandFlush         HandCategory = "flush"
+	HandFullHouse     HandCategory = "full_house"
+	HandFourOfAKind   HandCategory = "four_of_a_kind"
+	HandStraightFlush HandCategory = "straight_flush"
+	HandRoyalFlush    HandCategory = "royal_flush"
 )
 
 type Card struct {
@@ -106,14 +133,19 @@ type Game struct {
 	ID            string      `json:"id" gorm:"primaryKey;type:varchar(36)"`
 	TableID       int         `json:"table_id" gorm:"not null"`
 	State         GameState   `json:"state" gorm:"type:varchar(20);default:'waiting'"`
-	Deck          []Card      `json:"deck" gorm:"type:jsonb"`
-	CommunityCards []Card     `json:"community_cards" gorm:"type:jsonb"`
+	Deck          Cards       `json:"-" gorm:"type:jsonb"` // Не покидает сервер
+	CommunityCards Cards      `json:"community_cards" gorm:"type:jsonb"`
 	Pot           int         `json:"pot" gorm:"default:0"`
 	CurrentBet    int         `json:"current_bet" gorm:"default:0"`
+	MinRaise      int         `json:"min_raise" gorm:"default:0"` // Размер последнего полного рейза
 	DealerPosition int        `json:"dealer_position" gorm:"default:0"`
 	CurrentPlayer int         `json:"current_player" gorm:"default:0"`
 	SmallBlind    int         `json:"small_blind"`
 	BigBlind      int         `json:"big_blind"`
+	Showdown      *ShowdownResult `json:"showdown,omitempty" gorm:"type:jsonb"`
+	SeedHash      string      `json:"seed_hash" gorm:"type:varchar(64)"` // SHA-256 зерна сервера, публикуется до раздачи
+	ClientSeed    string      `json:"client_seed" gorm:"type:text"` // Зерна игроков раздачи: "место:зерно" через запятую
+	ServerSeed    string      `json:"-" gorm:"type:varchar(64)"` // Раскрывается только в Showdown после раздачи
 	CreatedAt     time.Time   `json:"created_at"`
 	UpdatedAt     time.Time   `json:"updated_at"`
 	
@@ -127,11 +159,13 @@ type GamePlayer struct {
 	GameID     string       `json:"game_id" gorm:"type:varchar(36);not null"`
 	UserUUID   string       `json:"user_uuid" gorm:"type:varchar(36);not null"`
 	Position   int          `json:"position" gorm:"not null"`
-	Cards      []Card       `json:"cards" gorm:"type:jsonb"`
+	Cards      Cards        `json:"cards" gorm:"type:jsonb"`
 	Chips      int          `json:"chips" gorm:"default:0"`
 	Bet        int          `json:"bet" gorm:"default:0"`
+	TotalBet   int          `json:"total_bet" gorm:"default:0"` // Вклад в банк за всю раздачу
 	IsFolded   bool         `json:"is_folded" gorm:"default:false"`
 	IsAllIn    bool         `json:"is_all_in" gorm:"default:false"`
+	HasActed   bool         `json:"has_acted" gorm:"default:false"` // Действовал после последнего полного рейза
 	LastAction PlayerAction `json:"last_action" gorm:"type:varchar(10)"`
 	
 	// Связи
@@ -145,6 +179,7 @@ type GameAction struct {
 	UserUUID  string       `json:"user_uuid" gorm:"type:varchar(36);not null"`
 	Action    PlayerAction `json:"action" gorm:"type:varchar(10);not null"`
 	Amount    int          `json:"amount" gorm:"default:0"`
+	Street    GameState    `json:"street" gorm:"type:varchar(20)"` // Улица, на которой сделано действие
 	CreatedAt time.Time    `json:"created_at"`
 	
 	// Связи
@@ -154,26 +189,86 @@ type GameAction struct {
 
 // Kafka сообщения
 type GameEvent struct {
-	Type      string      `json:"type"`
-	GameID    string      `json:"game_id"`
-	TableID   int         `json:"table_id"`
-	UserUUID  string      `json:"user_uuid,omitempty"`
-	Data      interface{} `json:"data"`
-	Timestamp time.Time   `json:"timestamp"`
+	Type         string      `json:"type"`
+	GameID       string      `json:"game_id"`
+	TableID      int         `json:"table_id"`
+	TournamentID int         `json:"tournament_id,omitempty"`
+	UserUUID     string      `json:"user_uuid,omitempty"`
+	Data         interface{} `json:"data"`
+	Timestamp    time.Time   `json:"timestamp"`
 }
 
 type PlayerActionEvent struct {
 	Action PlayerAction `json:"action"`
 	Amount int          `json:"amount"`
-	Player GamePlayer   `json:"player"`
+	Player PlayerView   `json:"player"`
+}
+
+// PotResult описывает основной или побочный банк и его распределение
+type PotResult struct {
+	Amount   int            `json:"amount"`
+	Eligible []string       `json:"eligible"` // Игроки, претендующие на банк
+	Winners  []string       `json:"winners"`
+	Payouts  map[string]int `json:"payouts"` // Выигрыш каждого победителя
+}
+
+// HandResult комбинация игрока на вскрытии
+type HandResult struct {
+	UserUUID    string            `json:"user_uuid"`
+	Category    HandCategory      `json:"category"`
+	Rank        int               `json:"rank"`        // 1-10, как в game.HandRank
+	Cards       Cards             `json:"cards"`       // Пять карт, составивших комбинацию
+	Description map[string]string `json:"description"` // Описание по языкам: ru, en
+}
+
+// ShowdownResult итог раздачи: основной банк и все побочные банки
+type ShowdownResult struct {
+	Pots       []PotResult  `json:"pots"`
+	Hands      []HandResult `json:"hands,omitempty"` // Пусто, если банк забран без вскрытия
+	ServerSeed string      `json:"server_seed,omitempty"` // Раскрытое зерно сервера для проверки колоды
+}
+
+// Value записывает итог раздачи в jsonb с компактными картами
+func (sr ShowdownResult) Value() (driver.Value, error) {
+	data, err := MarshalCompact(sr)
+	return string(data), err
+}
+
+// Scan читает итог раздачи из jsonb
+func (sr *ShowdownResult) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, sr)
+	case string:
+		return json.Unmarshal([]byte(v), sr)
+	}
+	return fmt.Errorf("unsupported showdown column type %T", src)
+}
+
+// LegalActions допустимые действия игрока, который сейчас ходит.
+// Суммы ставки и рейза указываются "до", как в запросе действия.
+type LegalActions struct {
+	UserUUID    string `json:"user_uuid"`
+	Position    int    `json:"position"`
+	CanFold     bool   `json:"can_fold"`
+	CanCheck    bool   `json:"can_check"`
+	CanCall     bool   `json:"can_call"`
+	CallAmount  int    `json:"call_amount"`
+	CanBet      bool   `json:"can_bet"`
+	CanRaise    bool   `json:"can_raise"`
+	MinAmount   int    `json:"min_amount"`
+	MaxAmount   int    `json:"max_amount"`
+	CanAllIn    bool   `json:"can_all_in"`
+	AllInAmount int    `json:"all_in_amount"` // Ставка игрока после олл-ина
 }
 
 type GameStateEvent struct {
-	State          GameState `json:"state"`
-	CommunityCards []Card    `json:"community_cards"`
-	Pot            int       `json:"pot"`
-	CurrentBet     int       `json:"current_bet"`
-	CurrentPlayer  int       `json:"current_player"`
+	State          GameState     `json:"state"`
+	CommunityCards []Card        `json:"community_cards"`
+	Pot            int           `json:"pot"`
+	CurrentBet     int           `json:"current_bet"`
+	CurrentPlayer  int           `json:"current_player"`
+	LegalActions   *LegalActions `json:"legal_actions,omitempty"`
 }
 
 // Хуки для Game
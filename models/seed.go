@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// TableSeed зерно сервера для следующей раздачи стола. Хеш публикуется
+// заранее, зерна игроков принимаются только после этого, поэтому сервер
+// не может подобрать зерно под известные зерна игроков.
+type TableSeed struct {
+	TableID    int       `json:"table_id" gorm:"primaryKey;autoIncrement:false"`
+	SeedHash   string    `json:"seed_hash" gorm:"type:varchar(64);not null"`
+	ServerSeed string    `json:"-" gorm:"type:varchar(64);not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ClientSeed зерно игрока для следующей раздачи за столом
+type ClientSeed struct {
+	ID        int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	TableID   int       `json:"table_id" gorm:"not null"`
+	UserUUID  string    `json:"user_uuid" gorm:"type:varchar(36);not null"`
+	Seed      string    `json:"seed" gorm:"type:varchar(64);not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TournamentType string
+
+const (
+	TournamentSitAndGo   TournamentType = "sng" // Стартует, как только набрано MaxPlayers игроков
+	TournamentMultiTable TournamentType = "mtt" // Стартует по расписанию в StartsAt
+)
+
+type TournamentState string
+
+const (
+	TournamentRegistering TournamentState = "registering"
+	TournamentRunning     TournamentState = "running"
+	TournamentFinished    TournamentState = "finished"
+	TournamentCancelled   TournamentState = "cancelled" // К старту не набралось игроков, взносы возвращены
+)
+
+// TournamentCategory категория столов турниров; такие столы не участвуют
+// в подборе столов для кэш-игры
+const TournamentCategory = "TOURNAMENT"
+
+// BlindLevel уровень блайндов турнира. Во время перерыва раздачи не играются.
+type BlindLevel struct {
+	SmallBlind int  `json:"small_blind"`
+	BigBlind   int  `json:"big_blind"`
+	Break      bool `json:"break,omitempty"`
+}
+
+// BlindLevels расписание блайндов, хранится в jsonb
+type BlindLevels []BlindLevel
+
+// Value записывает расписание в jsonb
+func (bl BlindLevels) Value() (driver.Value, error) {
+	data, err := json.Marshal(bl)
+	return string(data), err
+}
+
+// Scan читает расписание из jsonb
+func (bl *BlindLevels) Scan(src interface{}) error {
+	return scanJSON(src, bl)
+}
+
+// PayoutStructure доли призового фонда по местам в процентах, начиная с первого
+type PayoutStructure []int
+
+// Value записывает структуру выплат в jsonb
+func (ps PayoutStructure) Value() (driver.Value, error) {
+	data, err := json.Marshal(ps)
+	return string(data), err
+}
+
+// Scan читает структуру выплат из jsonb
+func (ps *PayoutStructure) Scan(src interface{}) error {
+	return scanJSON(src, ps)
+}
+
+type Tournament struct {
+	ID             int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name           string          `json:"name" gorm:"not null"`
+	Type           TournamentType  `json:"type" gorm:"type:varchar(10);not null"`
+	State          TournamentState `json:"state" gorm:"type:varchar(20);default:'registering'"`
+	BuyIn          int             `json:"buy_in"`       // Идет в призовой фонд
+	Fee            int             `json:"fee"`          // Взнос организатору
+	Bounty         int             `json:"bounty"`       // Часть buy-in, которая становится наградой за голову игрока
+	RebuyLevels    int             `json:"rebuy_levels"` // Ребаи открыты до конца этого уровня
+	MaxRebuys      int             `json:"max_rebuys"`   // 0 — без ограничения
+	AddOnCost      int             `json:"add_on_cost"`
+	AddOnChips     int             `json:"add_on_chips"` // 0 — аддона нет
+	StartingStack  int             `json:"starting_stack"`
+	MaxPlayers     int             `json:"max_players"`
+	TableSize      int             `json:"table_size"` // Мест за столом турнира
+	StartsAt       *time.Time      `json:"starts_at"`
+	LateRegLevels  int             `json:"late_reg_levels"` // Поздняя регистрация открыта до конца этого уровня
+	LevelMinutes   int             `json:"level_minutes"`
+	ActionTimeout  int             `json:"action_timeout"` // Секунд на ход, 0 — как за кэш-столами
+	Levels         BlindLevels     `json:"levels" gorm:"type:jsonb"`
+	Payouts        PayoutStructure `json:"payouts" gorm:"type:jsonb"`
+	PrizePool      int             `json:"prize_pool" gorm:"default:0"`
+	Level          int             `json:"level" gorm:"default:0"` // Номер текущего уровня с 1, 0 до старта
+	LevelStartedAt *time.Time      `json:"level_started_at"`
+	HandForHand    bool            `json:"hand_for_hand"` // Столы играют раздачи синхронно
+	StartedAt      *time.Time      `json:"started_at"`
+	FinishedAt     *time.Time      `json:"finished_at"`
+	CreatedBy      string          `json:"created_by" gorm:"type:varchar(36)"` // Оператор, создавший турнир
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+
+	// Связи
+	Entries []TournamentEntry `json:"entries,omitempty" gorm:"foreignKey:TournamentID"`
+}
+
+// CurrentBlinds блайнды текущего уровня; после последнего уровня блайнды
+// не растут, в перерыв действуют блайнды уровня перед ним
+func (t *Tournament) CurrentBlinds() BlindLevel {
+	if len(t.Levels) == 0 {
+		return BlindLevel{}
+	}
+	level := min(max(t.Level, 1), len(t.Levels))
+	for level > 1 && t.Levels[level-1].Break {
+		level--
+	}
+	return t.Levels[level-1]
+}
+
+// OnBreak идет ли перерыв
+func (t *Tournament) OnBreak() bool {
+	return t.State == TournamentRunning && t.Level >= 1 && t.Level <= len(t.Levels) && t.Levels[t.Level-1].Break
+}
+
+// RebuyOpen открыт ли период ребаев
+func (t *Tournament) RebuyOpen() bool {
+	return t.State == TournamentRunning && t.Level <= t.RebuyLevels
+}
+
+// AddOnOpen можно ли взять аддон: он доступен в перерыв
+func (t *Tournament) AddOnOpen() bool {
+	return t.AddOnChips > 0 && t.OnBreak()
+}
+
+// LateRegistrationOpen открыта ли поздняя регистрация в идущий турнир
+func (t *Tournament) LateRegistrationOpen() bool {
+	return t.State == TournamentRunning && t.Level <= t.LateRegLevels && !t.HandForHand
+}
+
+// TournamentEntry участник турнира
+type TournamentEntry struct {
+	ID              int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	TournamentID    int        `json:"tournament_id" gorm:"not null"`
+	UserUUID        string     `json:"user_uuid" gorm:"type:varchar(36);not null"`
+	Place           int        `json:"place" gorm:"default:0"` // 0, пока игрок в турнире или место еще не определено
+	Prize           int        `json:"prize" gorm:"default:0"`
+	EliminatedAt    *time.Time `json:"eliminated_at"`
+	EliminatedStack int        `json:"-" gorm:"default:0"`                        // Стек на начало раздачи, в которой игрок вылетел
+	EliminatedBy    Bounties   `json:"eliminated_by,omitempty" gorm:"type:jsonb"` // Кто выбил игрока в последний раз
+	Rebuys          int        `json:"rebuys" gorm:"default:0"`
+	AddOn           bool       `json:"add_on" gorm:"default:false"`
+	Bounty          int        `json:"bounty" gorm:"default:0"` // Текущая награда за голову игрока
+	BountiesWon     int        `json:"bounties_won" gorm:"default:0"`
+	Knockouts       int        `json:"knockouts" gorm:"default:0"`
+	RegisteredAt    time.Time  `json:"registered_at"`
+
+	// Связи
+	User User `json:"user" gorm:"foreignKey:UserUUID;references:UUID"`
+}
+
+// BountyPayment часть награды за голову, выплаченная выбившему игроку
+type BountyPayment struct {
+	UserUUID string `json:"user_uuid"`
+	Amount   int    `json:"amount"`
+}
+
+// Bounties выплаты награды за голову, хранятся в jsonb
+type Bounties []BountyPayment
+
+// Value записывает выплаты в jsonb
+func (b Bounties) Value() (driver.Value, error) {
+	data, err := json.Marshal(b)
+	return string(data), err
+}
+
+// Scan читает выплаты из jsonb
+func (b *Bounties) Scan(src interface{}) error {
+	return scanJSON(src, b)
+}
+
+func (t *Tournament) BeforeCreate(tx *gorm.DB) error {
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *Tournament) BeforeUpdate(tx *gorm.DB) error {
+	t.UpdatedAt = time.Now()
+	return nil
+}
+
+func (te *TournamentEntry) BeforeCreate(tx *gorm.DB) error {
+	te.RegisteredAt = time.Now()
+	return nil
+}
+
+// scanJSON читает значение jsonb
+func scanJSON(src interface{}, dst interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("unsupported jsonb column type %T", src)
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TransactionType string
+
+const (
+	TransactionTournamentBuyIn  TransactionType = "tournament_buy_in"
+	TransactionTournamentRefund TransactionType = "tournament_refund"
+	TransactionTournamentRebuy  TransactionType = "tournament_rebuy"
+	TransactionTournamentAddOn  TransactionType = "tournament_add_on"
+	TransactionTournamentPrize  TransactionType = "tournament_prize"
+	TransactionTournamentBounty TransactionType = "tournament_bounty"
+
+	// Движения по счету площадки
+	TransactionTournamentFee       TransactionType = "tournament_fee"
+	TransactionTournamentFeeRefund TransactionType = "tournament_fee_refund"
+)
+
+// BalanceTransaction движение по балансу пользователя
+type BalanceTransaction struct {
+	ID           int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserUUID     string          `json:"user_uuid" gorm:"type:varchar(36);not null"`
+	Type         TransactionType `json:"type" gorm:"type:varchar(30);not null"`
+	Amount       int             `json:"amount"` // Положительное — зачисление, отрицательное — списание
+	TournamentID *int            `json:"tournament_id,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+func (bt *BalanceTransaction) BeforeCreate(tx *gorm.DB) error {
+	bt.CreatedAt = time.Now()
+	return nil
+}
+
+// HouseTransaction движение по счету площадки: взносы турниров, которые
+// не идут в призовой фонд, и их возвраты. UserUUID — кто заплатил взнос.
+type HouseTransaction struct {
+	ID           int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type         TransactionType `json:"type" gorm:"type:varchar(30);not null"`
+	Amount       int             `json:"amount"` // Положительное — доход площадки, отрицательное — возврат
+	UserUUID     string          `json:"user_uuid" gorm:"type:varchar(36)"`
+	TournamentID *int            `json:"tournament_id,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+func (ht *HouseTransaction) BeforeCreate(tx *gorm.DB) error {
+	ht.CreatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,145 @@
+package models
+
+import "time"
+
+// GameView состояние раздачи глазами одного игрока. Колоды и зерна сервера
+// в нем нет, свои карты игрок видит всегда, а карты соперников — только
+// если те вскрылись на шоудауне.
+type GameView struct {
+	ID             string          `json:"id"`
+	TableID        int             `json:"table_id"`
+	State          GameState       `json:"state"`
+	CommunityCards Cards           `json:"community_cards"`
+	Pot            int             `json:"pot"`
+	CurrentBet     int             `json:"current_bet"`
+	MinRaise       int             `json:"min_raise"`
+	DealerPosition int             `json:"dealer_position"`
+	CurrentPlayer  int             `json:"current_player"`
+	SmallBlind     int             `json:"small_blind"`
+	BigBlind       int             `json:"big_blind"`
+	Showdown       *ShowdownResult `json:"showdown,omitempty"`
+	SeedHash       string          `json:"seed_hash"`
+	ClientSeed     string          `json:"client_seed"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+
+	Table   *Table       `json:"table,omitempty"`
+	Players []PlayerView `json:"players"`
+}
+
+// PlayerView игрок раздачи; Cards пусто, если карты скрыты от зрителя
+type PlayerView struct {
+	ID         int          `json:"id"`
+	UserUUID   string       `json:"user_uuid"`
+	Position   int          `json:"position"`
+	Cards      Cards        `json:"cards,omitempty"`
+	Chips      int          `json:"chips"`
+	Bet        int          `json:"bet"`
+	TotalBet   int          `json:"total_bet"`
+	IsFolded   bool         `json:"is_folded"`
+	IsAllIn    bool         `json:"is_all_in"`
+	HasActed   bool         `json:"has_acted"`
+	LastAction PlayerAction `json:"last_action"`
+
+	User *User `json:"user,omitempty"`
+}
+
+// ViewFor представление раздачи для игрока viewer. С пустым viewer получается
+// публичное представление без закрытых карт — для кэша и событий.
+func (g *Game) ViewFor(viewer string) *GameView {
+	view := &GameView{
+		ID:             g.ID,
+		TableID:        g.TableID,
+		State:          g.State,
+		CommunityCards: g.CommunityCards,
+		Pot:            g.Pot,
+		CurrentBet:     g.CurrentBet,
+		MinRaise:       g.MinRaise,
+		DealerPosition: g.DealerPosition,
+		CurrentPlayer:  g.CurrentPlayer,
+		SmallBlind:     g.SmallBlind,
+		BigBlind:       g.BigBlind,
+		Showdown:       g.Showdown,
+		SeedHash:       g.SeedHash,
+		ClientSeed:     g.ClientSeed,
+		CreatedAt:      g.CreatedAt,
+		UpdatedAt:      g.UpdatedAt,
+		Players:        make([]PlayerView, len(g.Players)),
+	}
+	if g.Table.ID != 0 {
+		table := g.Table
+		view.Table = &table
+	}
+	for i := range g.Players {
+		view.Players[i] = g.ViewPlayer(&g.Players[i], viewer)
+	}
+	return view
+}
+
+// PublicView публичное представление раздачи
+func (g *Game) PublicView() *GameView {
+	return g.ViewFor("")
+}
+
+// ViewPlayer представление игрока раздачи для зрителя viewer
+func (g *Game) ViewPlayer(p *GamePlayer, viewer string) PlayerView {
+	view := PlayerView{
+		ID:         p.ID,
+		UserUUID:   p.UserUUID,
+		Position:   p.Position,
+		Chips:      p.Chips,
+		Bet:        p.Bet,
+		TotalBet:   p.TotalBet,
+		IsFolded:   p.IsFolded,
+		IsAllIn:    p.IsAllIn,
+		HasActed:   p.HasActed,
+		LastAction: p.LastAction,
+	}
+	if (viewer != "" && p.UserUUID == viewer) || g.CardsShown(p.UserUUID) {
+		view.Cards = p.Cards
+	}
+	if p.User.UUID != "" {
+		user := p.User
+		view.User = &user
+	}
+	return view
+}
+
+// CardsShown вскрыл ли игрок карты на шоудауне
+func (g *Game) CardsShown(userUUID string) bool {
+	if g.Showdown == nil {
+		return false
+	}
+	for _, hand := range g.Showdown.Hands {
+		if hand.UserUUID == userUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// WithCards копия представления, в которой игроку userUUID открыты его карты
+func (v *GameView) WithCards(userUUID string, cards Cards) *GameView {
+	view := *v
+	view.Players = append([]PlayerView(nil), v.Players...)
+	for i := range view.Players {
+		if view.Players[i].UserUUID == userUUID {
+			view.Players[i].Cards = cards
+		}
+	}
+	return &view
+}
+
+// TableEvent событие стола для подписчиков WebSocket. Game — публичное
+// состояние раздачи после события; свои карты подписчик получает отдельно.
+// Seq растет на единицу с каждым событием стола, 0 — событие без номера.
+type TableEvent struct {
+	Seq       int64       `json:"seq,omitempty"`
+	Type      string      `json:"type"`
+	TableID   int         `json:"table_id"`
+	GameID    string      `json:"game_id,omitempty"`
+	UserUUID  string      `json:"user_uuid,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Game      *GameView   `json:"game,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
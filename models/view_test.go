@@ -0,0 +1,130 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+// newViewGame раздача трех игроков; колода и зерно сервера заполнены, как на сервере
+func newViewGame(t *testing.T, state GameState) *Game {
+	t.Helper()
+	cards := func(s string) Cards {
+		parsed, err := ParseCards(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	return &Game{
+		ID:             "game-1",
+		State:          state,
+		CommunityCards: cards("Ah7d2c"),
+		Deck:           cards("KsQsJsTs9s"),
+		ServerSeed:     "server-secret",
+		SeedHash:       "hash",
+		Players: []GamePlayer{
+			{UserUUID: "alice", Position: 1, Cards: cards("AsAd")},
+			{UserUUID: "bob", Position: 2, Cards: cards("KhKd")},
+			{UserUUID: "carol", Position: 3, Cards: cards("5h4h"), IsFolded: true},
+		},
+	}
+}
+
+// shownCards карты игроков в представлении; пустая строка — карты скрыты
+func shownCards(view *GameView) map[string]string {
+	shown := make(map[string]string)
+	for _, player := range view.Players {
+		shown[player.UserUUID] = player.Cards.notation()
+	}
+	return shown
+}
+
+func TestViewForHidesCardsBeforeShowdown(t *testing.T) {
+	g := newViewGame(t, GameStateFlop)
+	tests := []struct {
+		viewer string
+		want   map[string]string
+	}{
+		{"alice", map[string]string{"alice": "AsAd", "bob": "", "carol": ""}},
+		{"bob", map[string]string{"alice": "", "bob": "KhKd", "carol": ""}},
+		{"spectator", map[string]string{"alice": "", "bob": "", "carol": ""}},
+		{"", map[string]string{"alice": "", "bob": "", "carol": ""}},
+	}
+	for _, tt := range tests {
+		view := g.ViewFor(tt.viewer)
+		for user, want := range tt.want {
+			if got := shownCards(view)[user]; got != want {
+				t.Errorf("viewer %q sees %q for %s, want %q", tt.viewer, got, user, want)
+			}
+		}
+		if view.CommunityCards.notation() != "Ah7d2c" {
+			t.Errorf("viewer %q sees board %s", tt.viewer, view.CommunityCards.notation())
+		}
+
+		// В компактном формате карты — строки вида "Ks"; ни одна карта колоды
+		// не должна попасть в представление
+		data, err := MarshalCompact(view)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{"deck", "Ks", "Qs", "Js", "Ts", "9s", "server-secret", "server_seed"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("viewer %q: view leaks %q: %s", tt.viewer, secret, data)
+			}
+		}
+	}
+}
+
+func TestViewForAfterShowdown(t *testing.T) {
+	g := newViewGame(t, GameStateFinished)
+	// Кэрол сбросила карты и на вскрытии их не показывала
+	g.Showdown = &ShowdownResult{
+		Pots: []PotResult{{Amount: 300, Eligible: []string{"alice", "bob"}, Winners: []string{"alice"}}},
+		Hands: []HandResult{
+			{UserUUID: "alice", Category: HandThreeOfAKind},
+			{UserUUID: "bob", Category: HandPair},
+		},
+		ServerSeed: "server-secret",
+	}
+
+	for _, viewer := range []string{"", "spectator", "alice", "bob"} {
+		shown := shownCards(g.ViewFor(viewer))
+		if shown["alice"] != "AsAd" || shown["bob"] != "KhKd" {
+			t.Errorf("viewer %q: showdown hands %v not shown", viewer, shown)
+		}
+		if shown["carol"] != "" {
+			t.Errorf("viewer %q sees mucked cards %s", viewer, shown["carol"])
+		}
+	}
+	if shown := shownCards(g.ViewFor("carol")); shown["carol"] != "5h4h" {
+		t.Errorf("carol does not see her own mucked cards: %v", shown)
+	}
+}
+
+func TestViewForPotWonWithoutShowdown(t *testing.T) {
+	g := newViewGame(t, GameStateFinished)
+	g.Showdown = &ShowdownResult{
+		Pots: []PotResult{{Amount: 300, Eligible: []string{"alice", "bob"}, Winners: []string{"alice"}}},
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		if g.CardsShown(user) {
+			t.Errorf("%s cards shown although nobody showed down", user)
+		}
+	}
+	if shown := shownCards(g.PublicView()); shown["alice"] != "" || shown["bob"] != "" {
+		t.Errorf("public view shows cards without a showdown: %v", shown)
+	}
+}
+
+func TestWithCards(t *testing.T) {
+	g := newViewGame(t, GameStateTurn)
+	public := g.PublicView()
+	view := public.WithCards("bob", g.Players[1].Cards)
+
+	if shown := shownCards(view); shown["bob"] != "KhKd" || shown["alice"] != "" {
+		t.Errorf("WithCards view %v", shown)
+	}
+	if shown := shownCards(public); shown["bob"] != "" {
+		t.Errorf("WithCards changed the shared public view: %v", shown)
+	}
+}
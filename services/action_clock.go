@@ -0,0 +1,173 @@
+package services
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"poker/database"
+	"poker/game"
+	"poker/models"
+)
+
+// ActionClock отсчитывает время на ход. Если игрок не уложился, за него
+// делается чек, а когда чек невозможен — фолд, чтобы стол не простаивал.
+type ActionClock struct {
+	mu      sync.Mutex
+	turns   map[string]clockTurn // Текущий ход по ID раздачи
+	next    uint64               // Номер следующего хода
+	timeout time.Duration
+}
+
+// clockTurn часы одного хода. Номер отличает текущий ход от ходов, чьи
+// таймеры уже сработали, но не успели взять блокировку.
+type clockTurn struct {
+	timer      *time.Timer
+	generation uint64
+}
+
+var Clock *ActionClock
+
+// InitActionClock инициализирует часы ходов и заводит их для раздач,
+// которые шли до перезапуска сервера
+func InitActionClock() {
+	timeout, err := strconv.Atoi(getEnv("ACTION_TIMEOUT_SECONDS", "30"))
+	if err != nil || timeout <= 0 {
+		timeout = 30
+	}
+
+	Clock = &ActionClock{
+		turns:   make(map[string]clockTurn),
+		timeout: time.Duration(timeout) * time.Second,
+	}
+	Clock.resume()
+	log.Printf("Часы ходов запущены: %d с на ход", timeout)
+}
+
+// resume заводит часы для незаконченных раздач
+func (ac *ActionClock) resume() {
+	var games []models.Game
+	if err := database.DB.Preload("Players").
+		Where("state IN (?)", []models.GameState{models.GameStatePreFlop, models.GameStateFlop, models.GameStateTurn, models.GameStateRiver}).
+		Find(&games).Error; err != nil {
+		log.Printf("Не удалось загрузить незаконченные раздачи: %v", err)
+		return
+	}
+	for i := range games {
+		ac.Arm(&games[i])
+	}
+}
+
+// Arm заводит часы для игрока, который сейчас ходит в раздаче, и сбрасывает
+// часы предыдущего хода. Если ходить некому, часы раздачи останавливаются.
+// Вызывается под блокировкой раздачи или до того, как раздача стала видна.
+func (ac *ActionClock) Arm(g *models.Game) {
+	legal := game.NewPokerEngine(g).LegalActions()
+	var timeout time.Duration
+	if legal != nil {
+		timeout = ac.timeoutFor(g.TableID)
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if turn, ok := ac.turns[g.ID]; ok {
+		turn.timer.Stop()
+		delete(ac.turns, g.ID)
+	}
+	if legal == nil {
+		return
+	}
+
+	ac.next++
+	gameID, userUUID, generation := g.ID, legal.UserUUID, ac.next
+	ac.turns[gameID] = clockTurn{
+		timer: time.AfterFunc(timeout, func() {
+			ac.expire(gameID, userUUID, generation)
+		}),
+		generation: generation,
+	}
+}
+
+// timeoutFor время на ход за столом: у турнира оно может быть свое
+func (ac *ActionClock) timeoutFor(tableID int) time.Duration {
+	var seconds int
+	database.DB.Model(&models.Table{}).
+		Select("tournaments.action_timeout").
+		Joins("JOIN tournaments ON tournaments.id = tables.tournament_id").
+		Where("tables.id = ?", tableID).
+		Scan(&seconds)
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return ac.timeout
+}
+
+// Stop останавливает часы раздачи
+func (ac *ActionClock) Stop(gameID string) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if turn, ok := ac.turns[gameID]; ok {
+		turn.timer.Stop()
+		delete(ac.turns, gameID)
+	}
+}
+
+// expire делает за игрока чек или фолд. Ход проверяется под блокировкой
+// раздачи: если игрок успел сходить, часы уже переведены и ход generation
+// больше не текущий.
+func (ac *ActionClock) expire(gameID, userUUID string, generation uint64) {
+	unlock := lockGame(gameID)
+	gameState := ac.actFor(gameID, userUUID, generation)
+	unlock()
+
+	releaseGame(gameState)
+}
+
+// actFor ходит за игрока под блокировкой раздачи. Возвращает раздачу
+// после хода или nil, если ход сделать не пришлось.
+func (ac *ActionClock) actFor(gameID, userUUID string, generation uint64) *models.Game {
+	ac.mu.Lock()
+	turn, ok := ac.turns[gameID]
+	current := ok && turn.generation == generation
+	if current {
+		delete(ac.turns, gameID)
+	}
+	ac.mu.Unlock()
+	if !current {
+		return nil
+	}
+
+	gameState, err := LoadGame(gameID)
+	if err != nil {
+		log.Printf("Не удалось загрузить раздачу %s по истечении времени хода: %v", gameID, err)
+		return nil
+	}
+	legal := game.NewPokerEngine(gameState).LegalActions()
+	if legal == nil || legal.UserUUID != userUUID {
+		return nil
+	}
+
+	action := models.ActionFold
+	if legal.CanCheck {
+		action = models.ActionCheck
+	}
+
+	if Hub != nil {
+		Hub.Publish(models.TableEvent{
+			Type:     "action_timeout",
+			TableID:  gameState.TableID,
+			GameID:   gameID,
+			UserUUID: userUUID,
+			Data:     map[string]interface{}{"action": action},
+		})
+	}
+
+	gameState, _, err = applyAction(gameState, userUUID, action, 0)
+	if err != nil {
+		log.Printf("Не удалось сделать ход за игрока %s в раздаче %s: %v", userUUID, gameID, err)
+	}
+	return gameState
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"fmt"
+
+	"poker/models"
+
+	"gorm.io/gorm"
+)
+
+// changeBalance меняет баланс пользователя на amount и записывает движение
+// в журнал. Списание не проходит, если на балансе не хватает средств.
+func changeBalance(tx *gorm.DB, userUUID string, amount int, kind models.TransactionType, tournamentID *int) error {
+	if amount == 0 {
+		return nil
+	}
+
+	query := tx.Model(&models.User{}).Where("uuid = ?", userUUID)
+	if amount < 0 {
+		query = query.Where("balance >= ?", -amount)
+	}
+	result := query.UpdateColumn("balance", gorm.Expr("balance + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if amount < 0 {
+			return ErrInsufficientBalance
+		}
+		return fmt.Errorf("user %s not found", userUUID)
+	}
+
+	return tx.Create(&models.BalanceTransaction{
+		UserUUID:     userUUID,
+		Type:         kind,
+		Amount:       amount,
+		TournamentID: tournamentID,
+	}).Error
+}
+
+// postHouse записывает движение по счету площадки: взнос, списанный
+// с игрока вместе с buy-in (amount > 0), или его возврат (amount < 0)
+func postHouse(tx *gorm.DB, userUUID string, amount int, kind models.TransactionType, tournamentID *int) error {
+	if amount == 0 {
+		return nil
+	}
+	return tx.Create(&models.HouseTransaction{
+		Type:         kind,
+		Amount:       amount,
+		UserUUID:     userUUID,
+		TournamentID: tournamentID,
+	}).Error
+}
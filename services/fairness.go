@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"poker/database"
+	"poker/game"
+	"poker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidClientSeed = errors.New("client seed must be 1-64 letters, digits, '-' or '_'")
+	ErrNotSeated         = errors.New("not sitting at this table")
+	ErrSeedHashMismatch  = errors.New("server seed hash has changed")
+)
+
+// clientSeedPattern допустимое зерно игрока: без разделителей, чтобы
+// общее зерно раздачи однозначно разбиралось на зерна игроков
+var clientSeedPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// NextSeedHash хеш зерна сервера для следующей раздачи за столом. Если
+// зерна еще нет, оно создается и хеш публикуется подписчикам стола.
+func NextSeedHash(tableID int) (string, error) {
+	var commitment *models.TableSeed
+	var created bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		commitment, created, err = lockTableSeed(tx, tableID)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if created {
+		publishSeedCommitted(tableID, commitment.SeedHash)
+	}
+	return commitment.SeedHash, nil
+}
+
+// SetClientSeed принимает зерно игрока для следующей раздачи. seedHash —
+// хеш зерна сервера, который игрок видел, выбирая зерно: если он не совпадает
+// с текущим (см. NextSeedHash), зерно не принимается, иначе сервер мог бы
+// выбрать свое зерно, уже зная зерно игрока.
+func SetClientSeed(tableID int, userUUID, seed, seedHash string) error {
+	if !clientSeedPattern.MatchString(seed) {
+		return ErrInvalidClientSeed
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var seated int64
+		if err := tx.Model(&models.TablePlayer{}).
+			Where("table_id = ? AND user_uuid = ?", tableID, userUUID).
+			Count(&seated).Error; err != nil {
+			return err
+		}
+		if seated == 0 {
+			return ErrNotSeated
+		}
+
+		commitment, _, err := lockTableSeed(tx, tableID)
+		if err != nil {
+			return err
+		}
+		if commitment.SeedHash != seedHash {
+			return ErrSeedHashMismatch
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "table_id"}, {Name: "user_uuid"}},
+			DoUpdates: clause.AssignmentColumns([]string{"seed", "created_at"}),
+		}).Create(&models.ClientSeed{TableID: tableID, UserUUID: userUUID, Seed: seed}).Error
+	})
+}
+
+// ClientSeeds зерна игроков, принятые для следующей раздачи за столом
+func ClientSeeds(tableID int) ([]models.ClientSeed, error) {
+	var seeds []models.ClientSeed
+	err := database.DB.Where("table_id = ?", tableID).Order("id ASC").Find(&seeds).Error
+	return seeds, err
+}
+
+// takeSeeds забирает зерна для новой раздачи: опубликованное заранее зерно
+// сервера и зерна игроков раздачи, присланные после публикации. Зерно
+// клиента раздачи — зерна игроков в порядке мест в виде "место:зерно"
+// через запятую. Для следующей раздачи сразу создается новое зерно сервера.
+func takeSeeds(tx *gorm.DB, tableID int, players []models.TablePlayer) (serverSeed, clientSeed string, next *models.TableSeed, err error) {
+	commitment, _, err := lockTableSeed(tx, tableID)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var seeds []models.ClientSeed
+	if err := tx.Where("table_id = ?", tableID).Find(&seeds).Error; err != nil {
+		return "", "", nil, err
+	}
+	byUser := make(map[string]string, len(seeds))
+	for _, seed := range seeds {
+		byUser[seed.UserUUID] = seed.Seed
+	}
+	var parts []string
+	for _, player := range players {
+		if seed, ok := byUser[player.UserUUID]; ok {
+			parts = append(parts, fmt.Sprintf("%d:%s", player.SeatNumber, seed))
+		}
+	}
+
+	if err := tx.Where("table_id = ?", tableID).Delete(&models.ClientSeed{}).Error; err != nil {
+		return "", "", nil, err
+	}
+
+	nextSeed, err := game.NewServerSeed()
+	if err != nil {
+		return "", "", nil, err
+	}
+	next = &models.TableSeed{TableID: tableID, SeedHash: game.HashSeed(nextSeed), ServerSeed: nextSeed}
+	if err := tx.Save(next).Error; err != nil {
+		return "", "", nil, err
+	}
+
+	return commitment.ServerSeed, strings.Join(parts, ","), next, nil
+}
+
+// lockTableSeed блокирует до конца транзакции зерно сервера для следующей
+// раздачи, создавая его при необходимости. created — зерно только что
+// создано и его хеш еще не публиковался.
+func lockTableSeed(tx *gorm.DB, tableID int) (*models.TableSeed, bool, error) {
+	serverSeed, err := game.NewServerSeed()
+	if err != nil {
+		return nil, false, err
+	}
+	commitment := models.TableSeed{
+		TableID:    tableID,
+		SeedHash:   game.HashSeed(serverSeed),
+		ServerSeed: serverSeed,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&commitment)
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to create server seed: %w", result.Error)
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&commitment, "table_id = ?", tableID).Error; err != nil {
+		return nil, false, err
+	}
+	return &commitment, result.RowsAffected > 0, nil
+}
+
+// publishSeedCommitted сообщает подписчикам стола хеш зерна сервера для
+// следующей раздачи
+func publishSeedCommitted(tableID int, seedHash string) {
+	if Hub == nil {
+		return
+	}
+	Hub.Publish(models.TableEvent{
+		Type:    "seed_committed",
+		TableID: tableID,
+		Data:    map[string]string{"seed_hash": seedHash},
+	})
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"poker/database"
+	"poker/game"
+	"poker/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrGameNotFound = errors.New("game not found")
+	ErrNotYourTurn  = errors.New("it's not your turn or you cannot act")
+)
+
+// ActionError действие отклонено движком: сообщение можно показать игроку
+type ActionError struct {
+	Err error
+}
+
+func (e *ActionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
+// gameLocks блокировки раздач: действие игрока и истечение его времени
+// не должны обрабатываться одновременно
+var gameLocks sync.Map
+
+func lockGame(gameID string) func() {
+	value, _ := gameLocks.LoadOrStore(gameID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// releaseGame удаляет блокировку сыгранной раздачи. Вызывается после
+// разблокировки: кто успел взять старую блокировку, увидит, что раздача
+// уже закончена.
+func releaseGame(g *models.Game) {
+	if g != nil && g.State == models.GameStateFinished {
+		gameLocks.Delete(g.ID)
+	}
+}
+
+// LoadGame получает полное состояние игры из базы данных: в Redis
+// хранится только публичная часть
+func LoadGame(gameID string) (*models.Game, error) {
+	var gameState models.Game
+	if err := database.DB.Preload("Players.User").First(&gameState, "id = ?", gameID).Error; err != nil {
+		return nil, err
+	}
+	return &gameState, nil
+}
+
+// CanViewLive может ли пользователь видеть раздачу без задержки: пока
+// раздача идет — только ее игроки, остальные смотрят трансляцию для
+// зрителей. Завершенная раздача видна всем.
+func CanViewLive(gameID, userUUID string) (bool, error) {
+	var gameState models.Game
+	if err := database.DB.Select("id", "state").First(&gameState, "id = ?", gameID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrGameNotFound
+		}
+		return false, err
+	}
+	if gameState.State == models.GameStateFinished {
+		return true, nil
+	}
+
+	var players int64
+	err := database.DB.Model(&models.GamePlayer{}).
+		Where("game_id = ? AND user_uuid = ?", gameID, userUUID).
+		Count(&players).Error
+	return players > 0, err
+}
+
+// ApplyAction проводит действие игрока через движок, сохраняет раздачу
+// и рассылает события. Возвращает раздачу после действия и допустимые
+// действия следующего игрока.
+func ApplyAction(userUUID, gameID string, action models.PlayerAction, amount int) (*models.Game, *models.LegalActions, error) {
+	unlock := lockGame(gameID)
+	gameState, err := LoadGame(gameID)
+	if err != nil {
+		unlock()
+		return nil, nil, ErrGameNotFound
+	}
+	gameState, legal, err := applyAction(gameState, userUUID, action, amount)
+	unlock()
+
+	releaseGame(gameState)
+	return gameState, legal, err
+}
+
+// applyAction выполняет действие в загруженной раздаче. Вызывается под
+// блокировкой раздачи.
+func applyAction(gameState *models.Game, userUUID string, actionName models.PlayerAction, actionAmount int) (*models.Game, *models.LegalActions, error) {
+	gameID := gameState.ID
+	engine := game.NewPokerEngine(gameState)
+
+	// Проверяем, может ли игрок действовать
+	if !engine.CanPlayerAct(userUUID) {
+		return nil, nil, ErrNotYourTurn
+	}
+
+	// Обрабатываем действие
+	player := findPlayer(gameState, userUUID)
+	chipsBefore := player.Chips
+	street := gameState.State
+	if err := engine.ProcessAction(userUUID, actionName, actionAmount); err != nil {
+		return nil, nil, &ActionError{Err: err}
+	}
+
+	// Сохраняем действие в базу данных. Действие, после которого у игрока
+	// не осталось фишек, записывается как all_in; сумма — вложенные фишки
+	action := player.LastAction
+	amount := chipsBefore - player.Chips
+	playerActionEvent := models.PlayerActionEvent{
+		Action: action,
+		Amount: amount,
+		Player: gameState.ViewPlayer(player, ""),
+	}
+
+	gameAction := models.GameAction{
+		GameID:   gameID,
+		UserUUID: userUUID,
+		Action:   action,
+		Amount:   amount,
+		Street:   street,
+	}
+
+	// Улица меняется только когда все игроки действовали после последней агрессии
+	var stateEvents []models.GameStateEvent
+	if engine.IsRoundComplete() {
+		if err := engine.AdvanceGameState(); err != nil {
+			return nil, nil, err
+		}
+		stateEvents = append(stateEvents, engine.StateEvent())
+
+		// Ставить больше некому: докладываем оставшиеся улицы до вскрытия
+		if engine.IsRunoutNeeded() {
+			stateEvents = append(stateEvents, engine.RunOutBoard()...)
+		}
+	}
+
+	// Раздача сыграна: раскрываем зерно, переносим стеки на стол и планируем
+	// следующую. Иначе просто сохраняем состояние в базе данных и Redis.
+	// Действие записывается в одной транзакции с раздачей.
+	handOver := gameState.State == models.GameStateShowdown
+	if handOver {
+		var err error
+		if Sessions != nil {
+			err = Sessions.HandFinished(gameState, gameAction)
+		} else {
+			err = FinishHand(gameState, gameAction)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if err := SaveGame(gameState, gameAction); err != nil {
+			return nil, nil, err
+		}
+		if Clock != nil {
+			Clock.Arm(gameState)
+		}
+	}
+
+	// Отправляем событие в Kafka
+	if Kafka != nil {
+		event := models.GameEvent{
+			Type:      "player_action",
+			GameID:    gameID,
+			TableID:   gameState.TableID,
+			UserUUID:  userUUID,
+			Data:      playerActionEvent,
+			Timestamp: time.Now(),
+		}
+		Kafka.PublishGameEvent(event)
+
+		// Событие на каждую смену улицы, включая автоматическую раздачу борда
+		for _, stateEvent := range stateEvents {
+			stateEventMsg := models.GameEvent{
+				Type:      "game_state_changed",
+				GameID:    gameID,
+				TableID:   gameState.TableID,
+				Data:      stateEvent,
+				Timestamp: time.Now(),
+			}
+			Kafka.PublishGameEvent(stateEventMsg)
+		}
+
+		// Итоги раздачи со всеми банками
+		if handOver && gameState.Showdown != nil {
+			showdownEvent := models.GameEvent{
+				Type:      "showdown",
+				GameID:    gameID,
+				TableID:   gameState.TableID,
+				Data:      gameState.Showdown,
+				Timestamp: time.Now(),
+			}
+			Kafka.PublishGameEvent(showdownEvent)
+		}
+	}
+
+	// Подписчикам стола то же самое; состояние раздачи приходит с действием
+	if Hub != nil {
+		Hub.Publish(models.TableEvent{
+			Type:     "player_action",
+			TableID:  gameState.TableID,
+			GameID:   gameID,
+			UserUUID: userUUID,
+			Data:     playerActionEvent,
+			Game:     gameState.PublicView(),
+		})
+		for _, stateEvent := range stateEvents {
+			Hub.Publish(models.TableEvent{
+				Type:    "game_state_changed",
+				TableID: gameState.TableID,
+				GameID:  gameID,
+				Data:    stateEvent,
+			})
+		}
+		if handOver && gameState.Showdown != nil {
+			Hub.Publish(models.TableEvent{
+				Type:    "showdown",
+				TableID: gameState.TableID,
+				GameID:  gameID,
+				Data:    gameState.Showdown,
+			})
+		}
+	}
+
+	return gameState, engine.LegalActions(), nil
+}
+
+// findPlayer возвращает игрока раздачи по UUID
+func findPlayer(gameState *models.Game, userUUID string) *models.GamePlayer {
+	for i := range gameState.Players {
+		if gameState.Players[i].UserUUID == userUUID {
+			return &gameState.Players[i]
+		}
+	}
+	return nil
+}
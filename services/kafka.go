@@ -52,9 +52,12 @@ func InitKafka() error {
 	return nil
 }
 
-// PublishGameEvent публикует игровое событие
+// PublishGameEvent публикует игровое событие, карты — в компактном виде.
+// Номера seq у событий Kafka нет: он нужен WebSocket-клиентам, чтобы
+// дозапросить пропущенное у TableHub, а в Kafka события раздачи идут с
+// ключом game_id в одну партицию и читаются по порядку смещений.
 func (k *KafkaService) PublishGameEvent(event models.GameEvent) error {
-	data, err := json.Marshal(event)
+	data, err := models.MarshalCompact(event)
 	if err != nil {
 		return err
 	}
@@ -107,6 +110,37 @@ func (k *KafkaService) PublishTableEvent(tableID int, eventType string, data int
 	return nil
 }
 
+// PublishTournamentEvent публикует событие турнира
+func (k *KafkaService) PublishTournamentEvent(tournamentID int, eventType string, data interface{}) error {
+	event := models.GameEvent{
+		Type:         eventType,
+		TournamentID: tournamentID,
+		Data:         data,
+		Timestamp:    time.Now(),
+	}
+
+	eventData, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	topic := "poker-tournament-events"
+	message := &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(fmt.Sprintf("tournament-%d", tournamentID)),
+		Value: sarama.ByteEncoder(eventData),
+	}
+
+	_, _, err = k.producer.SendMessage(message)
+	if err != nil {
+		log.Printf("Ошибка отправки события турнира в Kafka: %v", err)
+		return err
+	}
+
+	log.Printf("Отправлено событие турнира: %s для турнира %d", eventType, tournamentID)
+	return nil
+}
+
 // ConsumeGameEvents потребляет игровые события
 func (k *KafkaService) ConsumeGameEvents(handler func(models.GameEvent)) error {
 	topic := "poker-game-events"
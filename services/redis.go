@@ -3,8 +3,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"poker/models"
@@ -12,6 +14,28 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// pubsubRetryDelay пауза перед повторным чтением подписки после ошибки
+const pubsubRetryDelay = time.Second
+
+const (
+	tableEventBuffer = 256            // Сколько последних событий стола хранится для переподключения
+	tableEventTTL    = 24 * time.Hour // Номера и события заброшенного стола удаляются
+)
+
+// appendTableEventScript дает событию стола следующий номер, записывает его
+// в поток стола ограниченной длины и публикует в канал стола. Все это одна
+// атомарная операция, поэтому порядок номеров совпадает с порядком доставки
+// на всех экземплярах. Номер дописывается первым полем JSON события.
+var appendTableEventScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+local message = '{"seq":' .. seq .. ',' .. string.sub(ARGV[1], 2)
+redis.call('XADD', KEYS[2], 'MAXLEN', ARGV[2], '*', 'seq', seq, 'event', message)
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+redis.call('PUBLISH', ARGV[4], message)
+return seq
+`)
+
 type RedisService struct {
 	client *redis.Client
 	ctx    context.Context
@@ -48,9 +72,10 @@ func InitRedis() error {
 	return nil
 }
 
-// SetGameState сохраняет состояние игры в Redis
-func (r *RedisService) SetGameState(gameID string, game *models.Game) error {
-	data, err := json.Marshal(game)
+// SetGameState сохраняет публичное состояние игры в Redis, карты — в
+// компактном виде. Закрытые карты и колода в кэш не попадают.
+func (r *RedisService) SetGameState(gameID string, game *models.GameView) error {
+	data, err := models.MarshalCompact(game)
 	if err != nil {
 		return err
 	}
@@ -59,8 +84,8 @@ func (r *RedisService) SetGameState(gameID string, game *models.Game) error {
 	return r.client.Set(r.ctx, key, data, time.Hour).Err()
 }
 
-// GetGameState получает состояние игры из Redis
-func (r *RedisService) GetGameState(gameID string) (*models.Game, error) {
+// GetGameState получает публичное состояние игры из Redis
+func (r *RedisService) GetGameState(gameID string) (*models.GameView, error) {
 	key := fmt.Sprintf("game:%s", gameID)
 	data, err := r.client.Get(r.ctx, key).Result()
 	if err != nil {
@@ -70,7 +95,7 @@ func (r *RedisService) GetGameState(gameID string) (*models.Game, error) {
 		return nil, err
 	}
 
-	var game models.Game
+	var game models.GameView
 	if err := json.Unmarshal([]byte(data), &game); err != nil {
 		return nil, err
 	}
@@ -128,9 +153,9 @@ func (r *RedisService) IsTableLocked(tableID int) bool {
 	return err != redis.Nil
 }
 
-// PublishToChannel публикует сообщение в канал Redis
+// PublishToChannel публикует сообщение в канал Redis, карты — в компактном виде
 func (r *RedisService) PublishToChannel(channel string, message interface{}) error {
-	data, err := json.Marshal(message)
+	data, err := models.MarshalCompact(message)
 	if err != nil {
 		return err
 	}
@@ -138,20 +163,114 @@ func (r *RedisService) PublishToChannel(channel string, message interface{}) err
 	return r.client.Publish(r.ctx, channel, data).Err()
 }
 
-// SubscribeToChannel подписывается на канал Redis
-func (r *RedisService) SubscribeToChannel(channel string, handler func(string)) error {
-	pubsub := r.client.Subscribe(r.ctx, channel)
-	defer pubsub.Close()
+// SubscribeToChannel подписывается на каналы Redis и передает сообщения
+// handler, пока подписку не закроют через Close. Каналы можно добавлять и
+// убирать через Subscribe и Unsubscribe подписки. После обрыва соединения
+// go-redis переподключается и заново подписывается на все каналы.
+func (r *RedisService) SubscribeToChannel(handler func(channel, payload string), channels ...string) *redis.PubSub {
+	pubsub := r.client.Subscribe(r.ctx, channels...)
 
-	ch := pubsub.Channel()
-	
 	go func() {
-		for msg := range ch {
-			handler(msg.Payload)
+		for {
+			msg, err := pubsub.ReceiveMessage(r.ctx)
+			if errors.Is(err, redis.ErrClosed) {
+				return
+			}
+			if err != nil {
+				log.Printf("Ошибка подписки Redis, переподключаемся: %v", err)
+				time.Sleep(pubsubRetryDelay)
+				continue
+			}
+			handler(msg.Channel, msg.Payload)
 		}
 	}()
 
-	return nil
+	return pubsub
+}
+
+// AppendTableEvent нумерует событие стола, сохраняет его в потоке стола
+// и публикует в канал стола. Возвращает номер события.
+func (r *RedisService) AppendTableEvent(tableID int, event interface{}) (int64, error) {
+	data, err := models.MarshalCompact(event)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := []string{
+		fmt.Sprintf("table_seq:%d", tableID),
+		fmt.Sprintf("table_stream:%d", tableID),
+	}
+	return appendTableEventScript.Run(r.ctx, r.client, keys,
+		data, tableEventBuffer, int(tableEventTTL.Seconds()), tableChannel(tableID)).Int64()
+}
+
+// GetTableEventSeq номер последнего события стола, 0 если событий не было
+func (r *RedisService) GetTableEventSeq(tableID int) (int64, error) {
+	key := fmt.Sprintf("table_seq:%d", tableID)
+	seq, err := r.client.Get(r.ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// GetTableEvents последние события стола из потока, от старых к новым
+func (r *RedisService) GetTableEvents(tableID int) ([]string, error) {
+	key := fmt.Sprintf("table_stream:%d", tableID)
+	messages, err := r.client.XRange(r.ctx, key, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]string, 0, len(messages))
+	for _, message := range messages {
+		if event, ok := message.Values["event"].(string); ok {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// TouchSpectator отмечает зрителя стола. Зрители, которые не отмечались
+// дольше ttl, удаляются.
+func (r *RedisService) TouchSpectator(tableID int, spectatorID string, ttl time.Duration) error {
+	key := fmt.Sprintf("table_spectators:%d", tableID)
+	now := time.Now()
+
+	pipe := r.client.TxPipeline()
+	pipe.ZAdd(r.ctx, key, redis.Z{Score: float64(now.Unix()), Member: spectatorID})
+	pipe.ZRemRangeByScore(r.ctx, key, "-inf", strconv.FormatInt(now.Add(-ttl).Unix(), 10))
+	pipe.Expire(r.ctx, key, ttl)
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// RemoveSpectator убирает зрителя стола
+func (r *RedisService) RemoveSpectator(tableID int, spectatorID string) error {
+	key := fmt.Sprintf("table_spectators:%d", tableID)
+	return r.client.ZRem(r.ctx, key, spectatorID).Err()
+}
+
+// CountSpectators число зрителей столов, отмечавшихся за последние ttl
+func (r *RedisService) CountSpectators(tableIDs []int, ttl time.Duration) (map[int]int, error) {
+	since := strconv.FormatInt(time.Now().Add(-ttl).Unix(), 10)
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(tableIDs))
+	for i, tableID := range tableIDs {
+		cmds[i] = pipe.ZCount(r.ctx, fmt.Sprintf("table_spectators:%d", tableID), since, "+inf")
+	}
+	if len(cmds) > 0 {
+		if _, err := pipe.Exec(r.ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	counts := make(map[int]int, len(tableIDs))
+	for i, tableID := range tableIDs {
+		counts[tableID] = int(cmds[i].Val())
+	}
+	return counts, nil
 }
 
 // Close закрывает соединение с Redis
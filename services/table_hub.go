@@ -0,0 +1,435 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"poker/database"
+	"poker/models"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// subscriberBuffer сколько событий может ждать отправки одному подписчику.
+// Подписчик, который не успевает их забирать, отключается.
+const subscriberBuffer = 64
+
+// spectatorTTL зритель, который столько не отмечался, не учитывается в
+// числе зрителей стола: его экземпляр API мог упасть
+const spectatorTTL = 2 * time.Minute
+
+// Задержка трансляции для зрителей. Сидящий за столом игрок не должен
+// следить за раздачей со стороны в реальном времени; сверху задержка
+// ограничена, потому что события ждут отправки в памяти.
+const (
+	MinSpectatorDelay = 30 * time.Second
+	MaxSpectatorDelay = 15 * time.Minute
+)
+
+// DefaultSpectatorDelay задержка трансляции новых столов в секундах
+// (SPECTATOR_DELAY_SECONDS)
+var DefaultSpectatorDelay = int(MinSpectatorDelay.Seconds())
+
+// TableHub рассылает события столов подписчикам WebSocket. С Redis события
+// идут через канал стола, поэтому доходят до подписчиков на всех экземплярах
+// API; экземпляр слушает только каналы столов, на которые у него есть
+// подписчики.
+type TableHub struct {
+	mu     sync.RWMutex
+	tables map[int]map[*Subscriber]struct{}
+	pubsub *redis.PubSub // nil без Redis: события рассылаются только локально
+
+	// Без Redis номера и последние события столов хранятся здесь: не больше
+	// tableEventBuffer событий на стол, как и в потоке Redis
+	logMu sync.Mutex
+	logs  map[int]*eventLog
+}
+
+// eventLog номер последнего события стола и последние события
+type eventLog struct {
+	seq    int64
+	events []models.TableEvent
+}
+
+// Subscriber подписчик на события одного стола
+type Subscriber struct {
+	TableID  int
+	UserUUID string // Пусто у зрителя
+
+	spectatorID string // Для подсчета зрителей
+
+	events chan models.TableEvent
+	done   chan struct{}
+
+	// Карты подписчика в текущей раздаче; читаются только из его горутины
+	cardsGame string
+	cards     models.Cards
+}
+
+var Hub *TableHub
+
+// InitTableHub инициализирует рассылку событий столов
+func InitTableHub() {
+	Hub = &TableHub{
+		tables: make(map[int]map[*Subscriber]struct{}),
+		logs:   make(map[int]*eventLog),
+	}
+	if Redis != nil {
+		Hub.pubsub = Redis.SubscribeToChannel(Hub.receive)
+	}
+
+	delay, err := strconv.Atoi(getEnv("SPECTATOR_DELAY_SECONDS", strconv.Itoa(DefaultSpectatorDelay)))
+	if err == nil && ValidSpectatorDelay(delay) {
+		DefaultSpectatorDelay = delay
+	}
+}
+
+// SpectatorDelay задержка трансляции стола для зрителей: настройка стола
+// в пределах от MinSpectatorDelay до MaxSpectatorDelay
+func SpectatorDelay(table *models.Table) time.Duration {
+	delay := time.Duration(table.SpectatorDelay) * time.Second
+	return min(max(delay, MinSpectatorDelay), MaxSpectatorDelay)
+}
+
+// ValidSpectatorDelay проверяет задержку трансляции стола в секундах
+func ValidSpectatorDelay(seconds int) bool {
+	delay := time.Duration(seconds) * time.Second
+	return delay >= MinSpectatorDelay && delay <= MaxSpectatorDelay
+}
+
+// tableChannel канал Redis с событиями стола
+func tableChannel(tableID int) string {
+	return fmt.Sprintf("table_events:%d", tableID)
+}
+
+// Subscribe подписывает пользователя на события стола
+func (h *TableHub) Subscribe(tableID int, userUUID string) *Subscriber {
+	return h.subscribe(&Subscriber{
+		TableID:  tableID,
+		UserUUID: userUUID,
+	})
+}
+
+// Watch подписывает зрителя на события стола. Зритель видит только
+// открытую информацию и учитывается в числе зрителей стола.
+func (h *TableHub) Watch(tableID int) *Subscriber {
+	s := h.subscribe(&Subscriber{
+		TableID:     tableID,
+		spectatorID: uuid.New().String(),
+	})
+	h.Touch(s)
+	return s
+}
+
+// Touch отмечает, что зритель еще смотрит стол; вызывается не реже, чем
+// раз в spectatorTTL
+func (h *TableHub) Touch(s *Subscriber) {
+	if s.spectatorID == "" || h.pubsub == nil {
+		return
+	}
+	if err := Redis.TouchSpectator(s.TableID, s.spectatorID, spectatorTTL); err != nil {
+		log.Printf("Не удалось отметить зрителя стола %d: %v", s.TableID, err)
+	}
+}
+
+// Spectators число зрителей столов на всех экземплярах API
+func (h *TableHub) Spectators(tableIDs []int) map[int]int {
+	if h.pubsub != nil {
+		counts, err := Redis.CountSpectators(tableIDs, spectatorTTL)
+		if err == nil {
+			return counts
+		}
+		log.Printf("Не удалось посчитать зрителей: %v", err)
+	}
+
+	counts := make(map[int]int, len(tableIDs))
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, tableID := range tableIDs {
+		for s := range h.tables[tableID] {
+			if s.spectatorID != "" {
+				counts[tableID]++
+			}
+		}
+	}
+	return counts
+}
+
+func (h *TableHub) subscribe(s *Subscriber) *Subscriber {
+	s.events = make(chan models.TableEvent, subscriberBuffer)
+	s.done = make(chan struct{})
+	tableID := s.TableID
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tables[tableID] == nil {
+		h.tables[tableID] = make(map[*Subscriber]struct{})
+		if h.pubsub != nil {
+			if err := h.pubsub.Subscribe(Redis.ctx, tableChannel(tableID)); err != nil {
+				log.Printf("Не удалось подписаться на канал стола %d: %v", tableID, err)
+			}
+		}
+	}
+	h.tables[tableID][s] = struct{}{}
+	return s
+}
+
+// Unsubscribe отписывает подписчика; повторный вызов ничего не делает
+func (h *TableHub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subscribers := h.tables[s.TableID]
+	if _, ok := subscribers[s]; !ok {
+		return
+	}
+	delete(subscribers, s)
+	if len(subscribers) == 0 {
+		delete(h.tables, s.TableID)
+		if h.pubsub != nil {
+			if err := h.pubsub.Unsubscribe(Redis.ctx, tableChannel(s.TableID)); err != nil {
+				log.Printf("Не удалось отписаться от канала стола %d: %v", s.TableID, err)
+			}
+		}
+	}
+	if s.spectatorID != "" && h.pubsub != nil {
+		if err := Redis.RemoveSpectator(s.TableID, s.spectatorID); err != nil {
+			log.Printf("Не удалось убрать зрителя стола %d: %v", s.TableID, err)
+		}
+	}
+	close(s.done)
+}
+
+// Close закрывает подписку на Redis
+func (h *TableHub) Close() error {
+	if h.pubsub == nil {
+		return nil
+	}
+	return h.pubsub.Close()
+}
+
+// Publish нумерует событие и рассылает его всем подписчикам стола. С Redis
+// событие публикуется в канал стола, и подписчикам его доставит receive, в
+// том числе на этом экземпляре. Если Redis недоступен, подписчики этого
+// экземпляра получают событие напрямую; при сбое Redis — без номера.
+func (h *TableHub) Publish(event models.TableEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	if h.pubsub != nil {
+		_, err := Redis.AppendTableEvent(event.TableID, event)
+		if err == nil {
+			return
+		}
+		log.Printf("Не удалось опубликовать событие стола %d в Redis: %v", event.TableID, err)
+		h.deliver(event)
+		return
+	}
+
+	// Номер и доставка под одной блокировкой, чтобы порядок совпадал
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	l := h.logs[event.TableID]
+	if l == nil {
+		l = &eventLog{}
+		h.logs[event.TableID] = l
+	}
+	l.seq++
+	event.Seq = l.seq
+	l.events = append(l.events, event)
+	if len(l.events) > tableEventBuffer {
+		l.events = append([]models.TableEvent(nil), l.events[len(l.events)-tableEventBuffer:]...)
+	}
+	h.deliver(event)
+}
+
+// DropTable забывает события закрытого стола. С Redis поток стола
+// удаляется сам по истечении tableEventTTL.
+func (h *TableHub) DropTable(tableID int) {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	delete(h.logs, tableID)
+}
+
+// Seq номер последнего события стола
+func (h *TableHub) Seq(tableID int) (int64, error) {
+	if h.pubsub != nil {
+		return Redis.GetTableEventSeq(tableID)
+	}
+
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	if l := h.logs[tableID]; l != nil {
+		return l.seq, nil
+	}
+	return 0, nil
+}
+
+// Replay события стола с номерами больше lastSeq. ok ложно, если часть
+// этих событий уже вытеснена из буфера или такого номера еще не было —
+// тогда клиенту нужен полный снимок.
+func (h *TableHub) Replay(tableID int, lastSeq int64) (events []models.TableEvent, ok bool) {
+	seq, err := h.Seq(tableID)
+	if err != nil || lastSeq > seq {
+		return nil, false
+	}
+	if lastSeq == seq {
+		return nil, true
+	}
+
+	var buffered []models.TableEvent
+	if h.pubsub != nil {
+		payloads, err := Redis.GetTableEvents(tableID)
+		if err != nil {
+			log.Printf("Не удалось прочитать события стола %d: %v", tableID, err)
+			return nil, false
+		}
+		for _, payload := range payloads {
+			event, err := decodeTableEvent(payload)
+			if err != nil {
+				log.Printf("Некорректное событие в потоке стола %d: %v", tableID, err)
+				return nil, false
+			}
+			buffered = append(buffered, event)
+		}
+	} else {
+		h.logMu.Lock()
+		if l := h.logs[tableID]; l != nil {
+			buffered = append(buffered, l.events...)
+		}
+		h.logMu.Unlock()
+	}
+
+	for _, event := range buffered {
+		if event.Seq > lastSeq {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 || events[0].Seq != lastSeq+1 {
+		return nil, false
+	}
+	return events, true
+}
+
+// deliver передает событие подписчикам стола на этом экземпляре. Не
+// блокируется: подписчики с переполненной очередью отключаются.
+func (h *TableHub) deliver(event models.TableEvent) {
+	var slow []*Subscriber
+	h.mu.RLock()
+	for s := range h.tables[event.TableID] {
+		select {
+		case s.events <- event:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range slow {
+		log.Printf("Подписчик %s не успевает получать события стола %d, отключаем", s.UserUUID, s.TableID)
+		h.Unsubscribe(s)
+	}
+}
+
+// tableEventData типы данных событий с картами. Данные из Redis читаются в
+// исходный тип, чтобы карты снова записывались так, как просил клиент.
+var tableEventData = map[string]func() interface{}{
+	"player_action":      func() interface{} { return &models.PlayerActionEvent{} },
+	"game_state_changed": func() interface{} { return &models.GameStateEvent{} },
+	"showdown":           func() interface{} { return &models.ShowdownResult{} },
+}
+
+// receive принимает событие стола из Redis
+func (h *TableHub) receive(channel, payload string) {
+	event, err := decodeTableEvent(payload)
+	if err != nil {
+		log.Printf("Некорректное событие в канале %s: %v", channel, err)
+		return
+	}
+	h.deliver(event)
+}
+
+// decodeTableEvent читает событие стола, записанное в Redis
+func decodeTableEvent(payload string) (models.TableEvent, error) {
+	var message struct {
+		models.TableEvent
+		Data json.RawMessage `json:"data,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(payload), &message); err != nil {
+		return models.TableEvent{}, err
+	}
+
+	event := message.TableEvent
+	if len(message.Data) > 0 {
+		data := interface{}(new(interface{}))
+		if newData, ok := tableEventData[event.Type]; ok {
+			data = newData()
+		}
+		if err := json.Unmarshal(message.Data, data); err != nil {
+			return models.TableEvent{}, fmt.Errorf("data of %s: %w", event.Type, err)
+		}
+		event.Data = data
+	}
+	return event, nil
+}
+
+// PublishGame рассылает событие раздачи вместе с ее публичным состоянием
+func (h *TableHub) PublishGame(eventType string, g *models.Game, data interface{}) {
+	h.Publish(models.TableEvent{
+		Type:    eventType,
+		TableID: g.TableID,
+		GameID:  g.ID,
+		Data:    data,
+		Game:    g.PublicView(),
+	})
+}
+
+// Events очередь событий подписчика
+func (s *Subscriber) Events() <-chan models.TableEvent {
+	return s.events
+}
+
+// Done закрывается, когда подписчик отключен
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// View событие глазами подписчика: если он играет в раздаче, в состоянии
+// игры открываются его карты
+func (s *Subscriber) View(event models.TableEvent) models.TableEvent {
+	if event.Game == nil || s.UserUUID == "" {
+		return event
+	}
+	for _, player := range event.Game.Players {
+		if player.UserUUID != s.UserUUID || len(player.Cards) > 0 {
+			continue
+		}
+		if s.cardsGame != event.Game.ID {
+			cards, err := PlayerCards(event.Game.ID, s.UserUUID)
+			if err != nil {
+				log.Printf("Не удалось получить карты игрока %s: %v", s.UserUUID, err)
+				return event
+			}
+			s.cardsGame, s.cards = event.Game.ID, cards
+		}
+		event.Game = event.Game.WithCards(s.UserUUID, s.cards)
+		break
+	}
+	return event
+}
+
+// PlayerCards карты игрока в раздаче
+func PlayerCards(gameID, userUUID string) (models.Cards, error) {
+	var player models.GamePlayer
+	if err := database.DB.Select("cards").
+		Where("game_id = ? AND user_uuid = ?", gameID, userUUID).
+		Limit(1).Find(&player).Error; err != nil {
+		return nil, err
+	}
+	return player.Cards, nil
+}
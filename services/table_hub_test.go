@@ -0,0 +1,132 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"poker/models"
+)
+
+// newLocalHub TableHub без Redis: номера и буфер событий хранятся в памяти
+func newLocalHub() *TableHub {
+	return &TableHub{
+		tables: make(map[int]map[*Subscriber]struct{}),
+		logs:   make(map[int]*eventLog),
+	}
+}
+
+func eventSeqs(events []models.TableEvent) []int64 {
+	seqs := make([]int64, len(events))
+	for i, event := range events {
+		seqs[i] = event.Seq
+	}
+	return seqs
+}
+
+func TestHubNumbersEventsPerTable(t *testing.T) {
+	h := newLocalHub()
+	sub := h.Subscribe(1, "alice")
+	defer h.Unsubscribe(sub)
+
+	for i := 0; i < 3; i++ {
+		h.Publish(models.TableEvent{Type: "player_action", TableID: 1})
+	}
+	h.Publish(models.TableEvent{Type: "player_joined", TableID: 2})
+
+	for want := int64(1); want <= 3; want++ {
+		select {
+		case event := <-sub.Events():
+			if event.Seq != want || event.Timestamp.IsZero() {
+				t.Errorf("event seq %d at %v, want seq %d with a timestamp", event.Seq, event.Timestamp, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not delivered", want)
+		}
+	}
+	select {
+	case event := <-sub.Events():
+		t.Errorf("subscriber of table 1 got %s of table %d", event.Type, event.TableID)
+	default:
+	}
+
+	if seq, _ := h.Seq(1); seq != 3 {
+		t.Errorf("table 1 seq %d, want 3", seq)
+	}
+	if seq, _ := h.Seq(2); seq != 1 {
+		t.Errorf("table 2 seq %d, want 1", seq)
+	}
+	if seq, _ := h.Seq(3); seq != 0 {
+		t.Errorf("table without events has seq %d", seq)
+	}
+}
+
+func TestHubReplay(t *testing.T) {
+	h := newLocalHub()
+	for i := 0; i < 5; i++ {
+		h.Publish(models.TableEvent{Type: "player_action", TableID: 1})
+	}
+
+	tests := []struct {
+		name    string
+		lastSeq int64
+		want    []int64
+		wantOK  bool
+	}{
+		{"missed the last two", 3, []int64{4, 5}, true},
+		{"missed everything", 0, []int64{1, 2, 3, 4, 5}, true},
+		{"up to date", 5, []int64{}, true},
+		{"seq from the future", 9, []int64{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, ok := h.Replay(1, tt.lastSeq)
+			if ok != tt.wantOK || !equalSeqs(eventSeqs(events), tt.want) {
+				t.Errorf("replay after %d: %v, ok %v; want %v, ok %v", tt.lastSeq, eventSeqs(events), ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHubReplayAfterBufferOverflow(t *testing.T) {
+	h := newLocalHub()
+	total := tableEventBuffer + 10
+	for i := 0; i < total; i++ {
+		h.Publish(models.TableEvent{Type: "player_action", TableID: 1})
+	}
+
+	// Первые десять событий вытеснены: клиенту нужен снимок
+	if events, ok := h.Replay(1, 5); ok || events != nil {
+		t.Errorf("replay from evicted seq 5: %d events, ok %v", len(events), ok)
+	}
+	// Самое старое событие в буфере — 11-е
+	events, ok := h.Replay(1, 10)
+	if !ok || len(events) != tableEventBuffer || events[0].Seq != 11 || events[len(events)-1].Seq != int64(total) {
+		t.Errorf("replay from seq 10: %d events starting at %v, ok %v", len(events), eventSeqs(events[:min(1, len(events))]), ok)
+	}
+}
+
+func TestHubDropTable(t *testing.T) {
+	h := newLocalHub()
+	h.Publish(models.TableEvent{Type: "player_action", TableID: 1})
+	h.DropTable(1)
+
+	if seq, _ := h.Seq(1); seq != 0 {
+		t.Errorf("seq %d after the table was dropped", seq)
+	}
+	// Клиент закрытого стола со старым номером получает снимок
+	if _, ok := h.Replay(1, 1); ok {
+		t.Error("replay of a dropped table succeeded")
+	}
+}
+
+func equalSeqs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
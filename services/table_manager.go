@@ -1,14 +1,19 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"poker/database"
 	"poker/models"
+
+	"gorm.io/gorm"
 )
 
+var ErrTableFull = errors.New("table is full")
+
 type TableManager struct {
 	ticker *time.Ticker
 	done   chan bool
@@ -107,11 +112,12 @@ func (tm *TableManager) createTableForCategory(category string) error {
 	}
 
 	newTable := models.Table{
-		Category: category,
-		Blinds:   blinds,
-		BuyIn:    buyIn,
-		Players:  0,
-		MaxSeats: maxSeats,
+		Category:       category,
+		Blinds:         blinds,
+		BuyIn:          buyIn,
+		Players:        0,
+		MaxSeats:       maxSeats,
+		SpectatorDelay: DefaultSpectatorDelay,
 	}
 
 	if err := database.DB.Create(&newTable).Error; err != nil {
@@ -126,6 +132,86 @@ func (tm *TableManager) createTableForCategory(category string) error {
 	return nil
 }
 
+// CreateTournamentTable создает стол турнира с блайндами текущего уровня
+func (tm *TableManager) CreateTournamentTable(tx *gorm.DB, tournament *models.Tournament, maxSeats int) (*models.Table, error) {
+	blinds := tournament.CurrentBlinds()
+	table := &models.Table{
+		Category:       models.TournamentCategory,
+		Blinds:         fmt.Sprintf("%d/%d", blinds.SmallBlind, blinds.BigBlind),
+		BuyIn:          tournament.BuyIn,
+		Players:        0,
+		MaxSeats:       maxSeats,
+		TournamentID:   &tournament.ID,
+		SpectatorDelay: DefaultSpectatorDelay,
+	}
+	if err := tx.Create(table).Error; err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// SeatPlayer сажает игрока на первое свободное место стола со стеком chips.
+// Счетчик игроков стола увеличивается. Возвращает номер места.
+func (tm *TableManager) SeatPlayer(tx *gorm.DB, table *models.Table, userUUID string, chips int) (int, error) {
+	var occupiedSeats []int
+	if err := tx.Model(&models.TablePlayer{}).Where("table_id = ?", table.ID).Pluck("seat_number", &occupiedSeats).Error; err != nil {
+		return 0, err
+	}
+	occupied := make(map[int]bool, len(occupiedSeats))
+	for _, seat := range occupiedSeats {
+		occupied[seat] = true
+	}
+
+	seatNumber := 1
+	for occupied[seatNumber] {
+		seatNumber++
+	}
+	if seatNumber > table.MaxSeats {
+		return 0, ErrTableFull
+	}
+
+	return seatNumber, tm.SeatPlayerAt(tx, table, userUUID, chips, seatNumber)
+}
+
+// SeatPlayerAt сажает игрока на указанное место стола со стеком chips
+func (tm *TableManager) SeatPlayerAt(tx *gorm.DB, table *models.Table, userUUID string, chips, seatNumber int) error {
+	tablePlayer := models.TablePlayer{
+		TableID:    table.ID,
+		UserUUID:   userUUID,
+		SeatNumber: seatNumber,
+		Chips:      chips,
+	}
+	if err := tx.Create(&tablePlayer).Error; err != nil {
+		return err
+	}
+
+	table.Players++
+	return tx.Model(table).Update("players", table.Players).Error
+}
+
+// MovePlayer пересаживает игрока с его стола на место seatNumber стола to
+// вместе со стеком. Счетчики игроков обоих столов обновляются.
+func (tm *TableManager) MovePlayer(tx *gorm.DB, player *models.TablePlayer, from, to *models.Table, seatNumber int) error {
+	if to.Players >= to.MaxSeats {
+		return ErrTableFull
+	}
+	if err := tx.Model(player).Updates(map[string]interface{}{
+		"table_id":    to.ID,
+		"seat_number": seatNumber,
+	}).Error; err != nil {
+		return err
+	}
+	player.TableID = to.ID
+	player.SeatNumber = seatNumber
+
+	from.Players--
+	to.Players++
+	if err := tx.Model(from).Update("players", from.Players).Error; err != nil {
+		return err
+	}
+	return tx.Model(to).Update("players", to.Players).Error
+}
+
 // CleanupEmptyTables удаляет пустые столы (кроме одного в каждой категории)
 func (tm *TableManager) CleanupEmptyTables() {
 	categories := []string{"LOW", "MID", "VIP"}
@@ -150,6 +236,9 @@ func (tm *TableManager) CleanupEmptyTables() {
 				if playerCount == 0 {
 					database.DB.Delete(&table)
 					log.Printf("Удален пустой стол ID: %d, категория: %s", table.ID, table.Category)
+					if Hub != nil {
+						Hub.DropTable(table.ID)
+					}
 					
 					// Отправляем событие в Kafka
 					if Kafka != nil {
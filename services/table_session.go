@@ -0,0 +1,451 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"poker/database"
+	"poker/game"
+	"poker/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotEnoughPlayers = errors.New("need at least 2 players with chips to start a hand")
+	ErrGameInProgress   = errors.New("game already in progress")
+	ErrTableNotFound    = errors.New("table not found")
+)
+
+// TableSession непрерывная серия раздач за одним столом
+type TableSession struct {
+	TableID int
+	timer   *time.Timer
+	held    bool // Следующая раздача ждет, пока стол не отпустят
+}
+
+// SessionManager управляет сессиями столов: после окончания раздачи
+// переносит стеки на стол и через паузу начинает следующую
+type SessionManager struct {
+	mu        sync.Mutex
+	sessions  map[int]*TableSession
+	handDelay time.Duration
+}
+
+var Sessions *SessionManager
+
+// InitSessionManager инициализирует менеджер сессий столов
+func InitSessionManager() {
+	delay, err := strconv.Atoi(getEnv("HAND_DELAY_SECONDS", "5"))
+	if err != nil || delay < 0 {
+		delay = 5
+	}
+
+	Sessions = &SessionManager{
+		sessions:  make(map[int]*TableSession),
+		handDelay: time.Duration(delay) * time.Second,
+	}
+	log.Println("Менеджер сессий столов запущен")
+}
+
+// Start запускает сессию стола: следующие раздачи начнутся автоматически
+func (sm *SessionManager) Start(tableID int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, ok := sm.sessions[tableID]; !ok {
+		sm.sessions[tableID] = &TableSession{TableID: tableID}
+		log.Printf("Сессия стола %d запущена", tableID)
+	}
+}
+
+// Stop останавливает сессию стола
+func (sm *SessionManager) Stop(tableID int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, ok := sm.sessions[tableID]; ok {
+		if session.timer != nil {
+			session.timer.Stop()
+		}
+		delete(sm.sessions, tableID)
+		log.Printf("Сессия стола %d остановлена", tableID)
+	}
+}
+
+// Hold придерживает стол: после текущей раздачи следующая не начнется,
+// пока стол не отпустят
+func (sm *SessionManager) Hold(tableID int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, ok := sm.sessions[tableID]; ok {
+		session.held = true
+	}
+}
+
+// Release отпускает придержанный стол: следующая раздача начнется через паузу
+func (sm *SessionManager) Release(tableID int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[tableID]
+	if !ok {
+		return
+	}
+	session.held = false
+	if session.timer != nil {
+		session.timer.Stop()
+	}
+	session.timer = time.AfterFunc(sm.handDelay, func() {
+		sm.nextHand(tableID)
+	})
+}
+
+// IsRunning проверяет, идет ли сессия за столом
+func (sm *SessionManager) IsRunning(tableID int) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	_, ok := sm.sessions[tableID]
+	return ok
+}
+
+// HandFinished завершает раздачу и планирует следующую, если сессия активна
+func (sm *SessionManager) HandFinished(g *models.Game, actions ...models.GameAction) error {
+	if err := FinishHand(g, actions...); err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[g.TableID]
+	if !ok {
+		return nil
+	}
+
+	if session.timer != nil {
+		session.timer.Stop()
+	}
+	if session.held {
+		return nil
+	}
+	session.timer = time.AfterFunc(sm.handDelay, func() {
+		sm.nextHand(session.TableID)
+	})
+	return nil
+}
+
+// nextHand начинает очередную раздачу, если стол не придержан; при нехватке
+// игроков сессия останавливается
+func (sm *SessionManager) nextHand(tableID int) {
+	sm.mu.Lock()
+	session, ok := sm.sessions[tableID]
+	ready := ok && !session.held
+	sm.mu.Unlock()
+	if !ready {
+		return
+	}
+
+	if _, _, err := StartHand(tableID); err != nil {
+		log.Printf("Не удалось начать раздачу за столом %d: %v", tableID, err)
+		if errors.Is(err, ErrNotEnoughPlayers) || errors.Is(err, ErrTableNotFound) {
+			sm.Stop(tableID)
+		}
+	}
+}
+
+// StartHand создает новую раздачу за столом: двигает кнопку, ставит блайнды
+// и раздает карты. Колода тасуется по схеме commit-reveal: хеш зерна сервера
+// опубликован до раздачи, до приема зерен игроков, само зерно раскрывается
+// после раздачи. Возвращает игру и поставленные блайнды.
+func StartHand(tableID int) (*models.Game, []models.GameAction, error) {
+	var newGame models.Game
+	var blinds []models.GameAction
+	var stateEvents []models.GameStateEvent
+	var nextSeed *models.TableSeed
+
+	// Строка стола блокируется до конца транзакции: раздачу за столом
+	// одновременно начинает только один вызов, остальные увидят активную игру
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var table models.Table
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&table, tableID).Error; err != nil {
+			return ErrTableNotFound
+		}
+
+		// Проверяем, нет ли уже активной игры
+		var active int64
+		if err := tx.Model(&models.Game{}).Where("table_id = ? AND state <> ?", tableID, models.GameStateFinished).Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrGameInProgress
+		}
+
+		// Вылетевшие игроки без фишек в раздаче не участвуют
+		var players []models.TablePlayer
+		if err := tx.Where("table_id = ? AND chips > 0", tableID).Order("seat_number ASC").Find(&players).Error; err != nil {
+			return fmt.Errorf("failed to get players: %w", err)
+		}
+
+		if len(players) < 2 {
+			return ErrNotEnoughPlayers
+		}
+
+		// Кнопка дилера переходит дальше от позиции прошлой раздачи
+		dealerPosition := -1
+		var previousGame models.Game
+		if err := tx.Where("table_id = ?", tableID).Order("created_at DESC").Limit(1).Find(&previousGame).Error; err != nil {
+			return err
+		}
+		if previousGame.ID != "" {
+			dealerPosition = previousGame.DealerPosition
+		}
+
+		smallBlind, bigBlind := ParseBlinds(table)
+
+		// Зерна забираются в одной транзакции с созданием раздачи: зерно игрока
+		// попадает ровно в одну раздачу
+		serverSeed, clientSeed, seed, err := takeSeeds(tx, tableID, players)
+		if err != nil {
+			return err
+		}
+		nextSeed = seed
+		deck, err := game.ShuffleDeck(serverSeed, clientSeed)
+		if err != nil {
+			return err
+		}
+
+		newGame = models.Game{
+			ID:             uuid.New().String(),
+			TableID:        tableID,
+			State:          models.GameStateWaiting,
+			Deck:           deck,
+			CommunityCards: []models.Card{},
+			DealerPosition: dealerPosition,
+			SmallBlind:     smallBlind,
+			BigBlind:       bigBlind,
+			SeedHash:       game.HashSeed(serverSeed),
+			ClientSeed:     clientSeed,
+			ServerSeed:     serverSeed,
+		}
+
+		// Позиция игрока в раздаче совпадает с местом за столом
+		for _, player := range players {
+			newGame.Players = append(newGame.Players, models.GamePlayer{
+				GameID:   newGame.ID,
+				UserUUID: player.UserUUID,
+				Position: player.SeatNumber,
+				Cards:    []models.Card{},
+				Chips:    player.Chips,
+			})
+		}
+
+		engine := game.NewPokerEngine(&newGame)
+		engine.MoveButton()
+		blinds, err = engine.StartHand()
+		if err != nil {
+			return err
+		}
+
+		stateEvents = []models.GameStateEvent{engine.StateEvent()}
+
+		// Блайнды могли отправить в олл-ин всех, кроме одного
+		if engine.IsRunoutNeeded() {
+			stateEvents = append(stateEvents, engine.RunOutBoard()...)
+		}
+
+		if err := tx.Create(&newGame).Error; err != nil {
+			return fmt.Errorf("failed to create game: %w", err)
+		}
+
+		// Блайнды записываем в историю действий вместе с раздачей
+		return createActions(tx, blinds)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	publishSeedCommitted(tableID, nextSeed.SeedHash)
+
+	if Redis != nil {
+		Redis.SetGameState(newGame.ID, newGame.PublicView())
+	}
+
+	// Время на ход идет с момента раздачи карт
+	if Clock != nil {
+		Clock.Arm(&newGame)
+	}
+
+	if Kafka != nil {
+		Kafka.PublishGameEvent(models.GameEvent{
+			Type:      "game_started",
+			GameID:    newGame.ID,
+			TableID:   tableID,
+			Data:      newGame.PublicView(),
+			Timestamp: time.Now(),
+		})
+
+		for _, stateEvent := range stateEvents {
+			Kafka.PublishGameEvent(models.GameEvent{
+				Type:      "game_state_changed",
+				GameID:    newGame.ID,
+				TableID:   tableID,
+				Data:      stateEvent,
+				Timestamp: time.Now(),
+			})
+		}
+	}
+
+	if Hub != nil {
+		Hub.PublishGame("game_started", &newGame, nil)
+		for _, stateEvent := range stateEvents {
+			Hub.Publish(models.TableEvent{
+				Type:    "game_state_changed",
+				TableID: tableID,
+				GameID:  newGame.ID,
+				Data:    stateEvent,
+			})
+		}
+	}
+
+	if newGame.State == models.GameStateShowdown {
+		if Sessions != nil {
+			err = Sessions.HandFinished(&newGame)
+		} else {
+			err = FinishHand(&newGame)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &newGame, blinds, nil
+}
+
+// FinishHand закрывает раздачу, раскрывает зерно сервера
+// и переносит стеки игроков обратно на стол. Последние действия раздачи
+// actions записываются в той же транзакции.
+func FinishHand(g *models.Game, actions ...models.GameAction) error {
+	g.State = models.GameStateFinished
+	if Clock != nil {
+		Clock.Stop(g.ID)
+	}
+
+	// Зерно не попадает в JSON и кэш Redis, поэтому берем его из базы
+	if g.Showdown != nil {
+		var serverSeed string
+		if err := database.DB.Model(&models.Game{}).Where("id = ?", g.ID).Pluck("server_seed", &serverSeed).Error; err == nil {
+			g.Showdown.ServerSeed = serverSeed
+		}
+	}
+
+	tx := database.DB.Begin()
+	if err := createActions(tx, actions); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, player := range g.Players {
+		result := tx.Model(&models.TablePlayer{}).
+			Where("table_id = ? AND user_uuid = ?", g.TableID, player.UserUUID).
+			Update("chips", player.Chips)
+		if result.Error != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update stack: %w", result.Error)
+		}
+		// Игрок раздачи обязан сидеть за столом, иначе его фишки пропадут
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			return fmt.Errorf("failed to update stack: %s is not seated at table %d", player.UserUUID, g.TableID)
+		}
+	}
+	if err := saveGame(tx, g); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	if Redis != nil {
+		Redis.SetGameState(g.ID, g.PublicView())
+	}
+
+	if Kafka != nil {
+		Kafka.PublishGameEvent(models.GameEvent{
+			Type:      "game_finished",
+			GameID:    g.ID,
+			TableID:   g.TableID,
+			Data:      g.PublicView().Players,
+			Timestamp: time.Now(),
+		})
+	}
+
+	if Hub != nil {
+		Hub.PublishGame("game_finished", g, nil)
+	}
+
+	// За столом турнира фиксируем выбывших
+	if Tournaments != nil {
+		Tournaments.HandFinished(g)
+	}
+	return nil
+}
+
+// SaveGame сохраняет игру вместе с состоянием всех игроков раздачи и
+// действиями actions в одной транзакции
+func SaveGame(g *models.Game, actions ...models.GameAction) error {
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := createActions(tx, actions); err != nil {
+			return err
+		}
+		return saveGame(tx, g)
+	}); err != nil {
+		return err
+	}
+
+	if Redis != nil {
+		Redis.SetGameState(g.ID, g.PublicView())
+	}
+	return nil
+}
+
+// saveGame не трогает server_seed: зерно записывается только при создании
+// раздачи
+func saveGame(db *gorm.DB, g *models.Game) error {
+	if err := db.Omit(clause.Associations, "server_seed").Save(g).Error; err != nil {
+		return fmt.Errorf("failed to save game: %w", err)
+	}
+	for i := range g.Players {
+		if err := db.Omit(clause.Associations).Save(&g.Players[i]).Error; err != nil {
+			return fmt.Errorf("failed to save player: %w", err)
+		}
+	}
+	return nil
+}
+
+// createActions записывает действия раздачи в историю
+func createActions(tx *gorm.DB, actions []models.GameAction) error {
+	for i := range actions {
+		if err := tx.Create(&actions[i]).Error; err != nil {
+			return fmt.Errorf("failed to record action: %w", err)
+		}
+	}
+	return nil
+}
+
+// ParseBlinds возвращает блайнды стола из строки вида "1/2".
+// Если строка некорректна, блайнды считаются от buy-in.
+func ParseBlinds(table models.Table) (int, int) {
+	var smallBlind, bigBlind int
+	if _, err := fmt.Sscanf(table.Blinds, "%d/%d", &smallBlind, &bigBlind); err == nil && smallBlind > 0 && bigBlind >= smallBlind {
+		return smallBlind, bigBlind
+	}
+	return max(table.BuyIn/100, 1), max(table.BuyIn/50, 2) // 1% и 2% от buy-in
+}
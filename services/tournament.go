@@ -0,0 +1,1046 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"poker/database"
+	"poker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTournamentNotFound  = errors.New("tournament not found")
+	ErrInvalidTournament   = errors.New("invalid tournament")
+	ErrRegistrationClosed  = errors.New("registration is closed")
+	ErrAlreadyRegistered   = errors.New("already registered")
+	ErrNotRegistered       = errors.New("not registered")
+	ErrTournamentFull      = errors.New("tournament is full")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrNotInTournament     = errors.New("not in the tournament")
+	ErrRebuyClosed         = errors.New("rebuy period is over")
+	ErrRebuyNotAllowed     = errors.New("rebuy is not allowed")
+	ErrAddOnClosed         = errors.New("add-on is only available at the break")
+	ErrAddOnTaken          = errors.New("add-on already taken")
+	ErrHandInProgress      = errors.New("wait for the current hand to finish")
+)
+
+const (
+	maxSitAndGoPlayers   = 10 // Sit & Go играется за одним столом
+	maxMultiTablePlayers = 1000
+	maxTableSize         = 10
+	defaultTableSize     = 9
+	defaultLevelMinutes  = 10
+	maxActionTimeout     = 300 // Секунд на ход
+	maxBuyIn             = 1000000
+	maxFeePercent        = 20 // Взнос площадки не больше 20% от buy-in
+)
+
+// DefaultBlindLevels расписание блайндов по умолчанию
+var DefaultBlindLevels = models.BlindLevels{
+	{SmallBlind: 10, BigBlind: 20},
+	{SmallBlind: 15, BigBlind: 30},
+	{SmallBlind: 25, BigBlind: 50},
+	{SmallBlind: 50, BigBlind: 100},
+	{SmallBlind: 75, BigBlind: 150},
+	{SmallBlind: 100, BigBlind: 200},
+	{SmallBlind: 150, BigBlind: 300},
+	{SmallBlind: 200, BigBlind: 400},
+	{SmallBlind: 300, BigBlind: 600},
+	{SmallBlind: 400, BigBlind: 800},
+	{SmallBlind: 600, BigBlind: 1200},
+	{SmallBlind: 800, BigBlind: 1600},
+	{SmallBlind: 1000, BigBlind: 2000},
+}
+
+// DefaultPayouts структура выплат по умолчанию для числа участников
+func DefaultPayouts(players int) models.PayoutStructure {
+	switch {
+	case players <= 3:
+		return models.PayoutStructure{100}
+	case players <= 6:
+		return models.PayoutStructure{65, 35}
+	case players <= 10:
+		return models.PayoutStructure{50, 30, 20}
+	case players <= 30:
+		return models.PayoutStructure{40, 25, 15, 12, 8}
+	}
+	return models.PayoutStructure{30, 20, 14, 10, 8, 6, 5, 4, 3}
+}
+
+// TournamentManager ведет турниры: запускает их, следит за часами блайндов,
+// фиксирует выбывание игроков, ломает и выравнивает столы и выплачивает
+// призы. Раздачи за столами турнира играются обычными сессиями столов.
+// Все состояние турнира хранится в базе, в памяти — только текущий круг
+// hand-for-hand.
+type TournamentManager struct {
+	mu     sync.Mutex
+	rounds map[int]map[int]bool // Столы, сыгравшие раздачу текущего круга hand-for-hand
+	ticker *time.Ticker
+	done   chan bool
+}
+
+var Tournaments *TournamentManager
+
+// InitTournamentManager инициализирует менеджер турниров
+func InitTournamentManager() {
+	Tournaments = &TournamentManager{
+		rounds: make(map[int]map[int]bool),
+		ticker: time.NewTicker(5 * time.Second),
+		done:   make(chan bool),
+	}
+
+	Tournaments.resume()
+	go Tournaments.run()
+	log.Println("Менеджер турниров запущен")
+}
+
+func (tm *TournamentManager) run() {
+	for {
+		select {
+		case <-tm.done:
+			return
+		case <-tm.ticker.C:
+			tm.tick()
+		}
+	}
+}
+
+// Stop останавливает менеджер турниров
+func (tm *TournamentManager) Stop() {
+	tm.ticker.Stop()
+	tm.done <- true
+	log.Println("Менеджер турниров остановлен")
+}
+
+// Create проверяет параметры турнира, подставляет значения по умолчанию
+// и открывает регистрацию
+func (tm *TournamentManager) Create(t *models.Tournament) error {
+	if err := prepareTournament(t); err != nil {
+		return err
+	}
+
+	t.State = models.TournamentRegistering
+	t.PrizePool = 0
+	t.Level = 0
+	t.LevelStartedAt, t.StartedAt, t.FinishedAt = nil, nil, nil
+	t.HandForHand = false
+	if err := database.DB.Omit(clause.Associations).Create(t).Error; err != nil {
+		return err
+	}
+
+	if Kafka != nil {
+		Kafka.PublishTournamentEvent(t.ID, "tournament_created", t)
+	}
+	return nil
+}
+
+func prepareTournament(t *models.Tournament) error {
+	if t.Type == "" {
+		t.Type = models.TournamentSitAndGo
+	}
+	switch t.Type {
+	case models.TournamentSitAndGo:
+		t.TableSize = t.MaxPlayers
+	case models.TournamentMultiTable:
+		if t.TableSize == 0 {
+			t.TableSize = defaultTableSize
+		}
+	}
+	if t.LevelMinutes == 0 {
+		t.LevelMinutes = defaultLevelMinutes
+	}
+	if len(t.Levels) == 0 {
+		t.Levels = append(models.BlindLevels(nil), DefaultBlindLevels...)
+	}
+	if len(t.Payouts) == 0 {
+		t.Payouts = DefaultPayouts(t.MaxPlayers)
+	}
+
+	switch {
+	case t.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidTournament)
+	case t.Type != models.TournamentSitAndGo && t.Type != models.TournamentMultiTable:
+		return fmt.Errorf("%w: unknown type %s", ErrInvalidTournament, t.Type)
+	case t.Type == models.TournamentSitAndGo && (t.MaxPlayers < 2 || t.MaxPlayers > maxSitAndGoPlayers):
+		return fmt.Errorf("%w: sit & go needs 2-%d players", ErrInvalidTournament, maxSitAndGoPlayers)
+	case t.Type == models.TournamentSitAndGo && (t.StartsAt != nil || t.LateRegLevels != 0):
+		return fmt.Errorf("%w: sit & go starts when full and has no late registration", ErrInvalidTournament)
+	case t.Type == models.TournamentMultiTable && (t.MaxPlayers < 2 || t.MaxPlayers > maxMultiTablePlayers):
+		return fmt.Errorf("%w: multi-table tournament needs 2-%d players", ErrInvalidTournament, maxMultiTablePlayers)
+	case t.Type == models.TournamentMultiTable && t.StartsAt == nil:
+		return fmt.Errorf("%w: start time is required", ErrInvalidTournament)
+	case t.Type == models.TournamentMultiTable && !t.StartsAt.After(time.Now()):
+		return fmt.Errorf("%w: start time must be in the future", ErrInvalidTournament)
+	case t.TableSize < 2 || t.TableSize > maxTableSize:
+		return fmt.Errorf("%w: table size must be 2-%d", ErrInvalidTournament, maxTableSize)
+	case t.LateRegLevels < 0 || t.LateRegLevels > len(t.Levels):
+		return fmt.Errorf("%w: late registration must end within the blind schedule", ErrInvalidTournament)
+	case t.StartingStack <= 0:
+		return fmt.Errorf("%w: starting stack must be positive", ErrInvalidTournament)
+	case t.BuyIn < 0 || t.Fee < 0:
+		return fmt.Errorf("%w: buy-in and fee cannot be negative", ErrInvalidTournament)
+	case t.BuyIn > maxBuyIn:
+		return fmt.Errorf("%w: buy-in cannot exceed %d", ErrInvalidTournament, maxBuyIn)
+	case t.Fee*100 > t.BuyIn*maxFeePercent:
+		return fmt.Errorf("%w: fee cannot exceed %d%% of the buy-in", ErrInvalidTournament, maxFeePercent)
+	case t.Bounty < 0 || t.Bounty > t.BuyIn:
+		return fmt.Errorf("%w: bounty must be part of the buy-in", ErrInvalidTournament)
+	case t.RebuyLevels < 0 || t.RebuyLevels > len(t.Levels) || t.MaxRebuys < 0:
+		return fmt.Errorf("%w: rebuys must end within the blind schedule", ErrInvalidTournament)
+	case t.AddOnCost < 0 || t.AddOnChips < 0:
+		return fmt.Errorf("%w: add-on cannot be negative", ErrInvalidTournament)
+	case t.LevelMinutes < 0:
+		return fmt.Errorf("%w: level duration cannot be negative", ErrInvalidTournament)
+	case t.ActionTimeout < 0 || t.ActionTimeout > maxActionTimeout:
+		return fmt.Errorf("%w: action timeout must be 0-%d seconds", ErrInvalidTournament, maxActionTimeout)
+	}
+
+	breaks := 0
+	for i, level := range t.Levels {
+		if level.Break {
+			if i == 0 {
+				return fmt.Errorf("%w: the first level cannot be a break", ErrInvalidTournament)
+			}
+			breaks++
+			continue
+		}
+		if level.SmallBlind <= 0 || level.BigBlind < level.SmallBlind {
+			return fmt.Errorf("%w: level %d has invalid blinds %d/%d", ErrInvalidTournament, i+1, level.SmallBlind, level.BigBlind)
+		}
+	}
+	if t.AddOnChips > 0 && breaks == 0 {
+		return fmt.Errorf("%w: add-on needs a break in the blind schedule", ErrInvalidTournament)
+	}
+
+	if len(t.Payouts) > t.MaxPlayers {
+		return fmt.Errorf("%w: more paid places than players", ErrInvalidTournament)
+	}
+	total := 0
+	for _, share := range t.Payouts {
+		if share <= 0 {
+			return fmt.Errorf("%w: payout shares must be positive", ErrInvalidTournament)
+		}
+		total += share
+	}
+	if total != 100 {
+		return fmt.Errorf("%w: payout shares add up to %d%%, not 100%%", ErrInvalidTournament, total)
+	}
+	return nil
+}
+
+// Register записывает игрока в турнир: списывает buy-in и взнос с баланса.
+// Награда за голову из buy-in в призовой фонд не идет.
+// Когда набрано нужное число игроков, Sit & Go стартует. В идущий турнир
+// со многими столами можно зайти, пока открыта поздняя регистрация: игрок
+// садится за стол, где меньше всего игроков.
+func (tm *TournamentManager) Register(tournamentID int, user *models.User) (*models.Tournament, error) {
+	var t models.Tournament
+	full, late := false, false
+	tm.mu.Lock()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, tournamentID).Error; err != nil {
+			return ErrTournamentNotFound
+		}
+		late = t.Type == models.TournamentMultiTable && t.LateRegistrationOpen()
+		if t.State != models.TournamentRegistering && !late {
+			return ErrRegistrationClosed
+		}
+
+		var entries int64
+		if err := tx.Model(&models.TournamentEntry{}).Where("tournament_id = ?", t.ID).Count(&entries).Error; err != nil {
+			return err
+		}
+		if entries >= int64(t.MaxPlayers) {
+			return ErrTournamentFull
+		}
+
+		var registered int64
+		tx.Model(&models.TournamentEntry{}).Where("tournament_id = ? AND user_uuid = ?", t.ID, user.UUID).Count(&registered)
+		if registered > 0 {
+			return ErrAlreadyRegistered
+		}
+
+		cost := t.BuyIn + t.Fee
+		if err := changeBalance(tx, user.UUID, -cost, models.TransactionTournamentBuyIn, &t.ID); err != nil {
+			return err
+		}
+		if err := postHouse(tx, user.UUID, t.Fee, models.TransactionTournamentFee, &t.ID); err != nil {
+			return err
+		}
+
+		entry := models.TournamentEntry{TournamentID: t.ID, UserUUID: user.UUID, Bounty: t.Bounty}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+
+		t.PrizePool += t.BuyIn - t.Bounty
+		if err := tx.Model(&t).Update("prize_pool", t.PrizePool).Error; err != nil {
+			return err
+		}
+		if late {
+			if err := tm.seatLatePlayer(tx, &t, user.UUID); err != nil {
+				return err
+			}
+		}
+
+		user.Balance -= cost
+		full = t.Type == models.TournamentSitAndGo && entries+1 == int64(t.MaxPlayers)
+		return nil
+	})
+	tm.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if Kafka != nil {
+		Kafka.PublishTournamentEvent(t.ID, "player_registered", map[string]interface{}{
+			"user_uuid":  user.UUID,
+			"prize_pool": t.PrizePool,
+			"late":       late,
+		})
+	}
+
+	// Поздно зарегистрированный игрок мог оказаться вторым за пустым столом
+	if late {
+		tm.resumeTables(&t, false)
+	}
+
+	// Если старт не удался, его повторит часовой цикл менеджера
+	if full {
+		if err := tm.start(t.ID); err != nil {
+			log.Printf("Не удалось запустить турнир %d: %v", t.ID, err)
+		}
+		database.DB.First(&t, t.ID)
+	}
+	return &t, nil
+}
+
+// Unregister снимает игрока с турнира до старта и возвращает деньги
+func (tm *TournamentManager) Unregister(tournamentID int, user *models.User) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var t models.Tournament
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, tournamentID).Error; err != nil {
+			return ErrTournamentNotFound
+		}
+		if t.State != models.TournamentRegistering {
+			return ErrRegistrationClosed
+		}
+
+		result := tx.Where("tournament_id = ? AND user_uuid = ?", t.ID, user.UUID).Delete(&models.TournamentEntry{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotRegistered
+		}
+
+		refund := t.BuyIn + t.Fee
+		if err := changeBalance(tx, user.UUID, refund, models.TransactionTournamentRefund, &t.ID); err != nil {
+			return err
+		}
+		if err := postHouse(tx, user.UUID, -t.Fee, models.TransactionTournamentFeeRefund, &t.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&t).Update("prize_pool", t.PrizePool-t.BuyIn+t.Bounty).Error; err != nil {
+			return err
+		}
+
+		user.Balance += refund
+		return nil
+	})
+}
+
+// start рассаживает участников в случайном порядке за столы турнира,
+// включает часы блайндов и начинает первые раздачи. Турнир со многими
+// столами, в который к старту не записалось двое, отменяется.
+func (tm *TournamentManager) start(tournamentID int) error {
+	var t models.Tournament
+	var tables []*models.Table
+	cancelled := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, tournamentID).Error; err != nil {
+			return ErrTournamentNotFound
+		}
+		if t.State != models.TournamentRegistering {
+			return nil
+		}
+
+		var entries []models.TournamentEntry
+		if err := tx.Where("tournament_id = ?", t.ID).Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) < 2 {
+			if t.Type != models.TournamentMultiTable {
+				return ErrNotEnoughPlayers
+			}
+			cancelled = true
+			return tm.cancel(tx, &t, entries)
+		}
+		rand.Shuffle(len(entries), func(i, j int) {
+			entries[i], entries[j] = entries[j], entries[i]
+		})
+
+		now := time.Now()
+		t.State = models.TournamentRunning
+		t.Level = 1
+		t.LevelStartedAt = &now
+		t.StartedAt = &now
+
+		// Игроки раздаются по столам по кругу, так что столы равны с точностью до одного
+		count := (len(entries) + t.TableSize - 1) / t.TableSize
+		for i := 0; i < count; i++ {
+			table, err := Manager.CreateTournamentTable(tx, &t, t.TableSize)
+			if err != nil {
+				return err
+			}
+			tables = append(tables, table)
+		}
+		for i, entry := range entries {
+			if _, err := Manager.SeatPlayer(tx, tables[i%count], entry.UserUUID, t.StartingStack); err != nil {
+				return err
+			}
+		}
+		return tx.Omit(clause.Associations).Save(&t).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if cancelled {
+		log.Printf("Турнир %d отменен: не набралось игроков", t.ID)
+		if Kafka != nil {
+			Kafka.PublishTournamentEvent(t.ID, "tournament_cancelled", t)
+		}
+		return nil
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	tableIDs := make([]int, len(tables))
+	for i, table := range tables {
+		tableIDs[i] = table.ID
+	}
+	log.Printf("Турнир %d начался за столами %v", t.ID, tableIDs)
+	if Kafka != nil {
+		Kafka.PublishTournamentEvent(t.ID, "tournament_started", map[string]interface{}{
+			"tournament": t,
+			"table_ids":  tableIDs,
+		})
+	}
+
+	for _, tableID := range tableIDs {
+		tm.startTable(tableID)
+	}
+	return nil
+}
+
+// cancel отменяет турнир и возвращает участникам buy-in и взнос
+func (tm *TournamentManager) cancel(tx *gorm.DB, t *models.Tournament, entries []models.TournamentEntry) error {
+	refund := t.BuyIn + t.Fee
+	for _, entry := range entries {
+		if err := changeBalance(tx, entry.UserUUID, refund, models.TransactionTournamentRefund, &t.ID); err != nil {
+			return err
+		}
+		if err := postHouse(tx, entry.UserUUID, -t.Fee, models.TransactionTournamentFeeRefund, &t.ID); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	t.State = models.TournamentCancelled
+	t.PrizePool = 0
+	t.FinishedAt = &now
+	return tx.Omit(clause.Associations).Save(t).Error
+}
+
+// startTable запускает непрерывные раздачи за столом турнира
+func (tm *TournamentManager) startTable(tableID int) {
+	if Sessions != nil {
+		Sessions.Start(tableID)
+	}
+	if _, _, err := StartHand(tableID); err != nil && !errors.Is(err, ErrGameInProgress) {
+		log.Printf("Не удалось начать раздачу за столом турнира %d: %v", tableID, err)
+	}
+}
+
+// tournamentUpdate итог обработки турнира в транзакции: что сделать со
+// столами и какие события отправить после ее фиксации
+type tournamentUpdate struct {
+	tournament  models.Tournament
+	busted      []models.TournamentEntry // Остались без фишек, но могут сделать ребай
+	eliminated  []models.TournamentEntry
+	moves       []tableMove
+	broken      []int // Сломанные столы
+	finalTable  int   // Стол, за которым собрались все оставшиеся игроки
+	hold        []int // Столы, которые ждут окончания круга hand-for-hand
+	release     bool  // Начать следующую раздачу за всеми столами
+	handForHand bool  // Режим hand-for-hand включился или выключился
+}
+
+// HandFinished учитывает раздачу, сыгранную за столом турнира: фиксирует
+// выбывших, ломает и выравнивает столы и следит за режимом hand-for-hand.
+// Игроки, вылетевшие в одной раздаче, делят места по стеку на начало
+// раздачи: у кого было больше фишек, тот занимает место выше. В режиме
+// hand-for-hand так же делят места все, кто вылетел за один круг. Когда
+// остается один игрок, турнир завершается.
+func (tm *TournamentManager) HandFinished(g *models.Game) {
+	var table models.Table
+	if err := database.DB.First(&table, g.TableID).Error; err != nil || table.TournamentID == nil {
+		return
+	}
+
+	tm.mu.Lock()
+	update, err := tm.handFinished(*table.TournamentID, &table, g)
+	tm.mu.Unlock()
+	if err != nil {
+		log.Printf("Не удалось учесть раздачу в турнире %d: %v", *table.TournamentID, err)
+		return
+	}
+	tm.apply(update)
+}
+
+func (tm *TournamentManager) handFinished(tournamentID int, table *models.Table, g *models.Game) (*tournamentUpdate, error) {
+	update := &tournamentUpdate{}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		t := &update.tournament
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(t, tournamentID).Error; err != nil {
+			return err
+		}
+		if t.State != models.TournamentRunning {
+			return nil
+		}
+
+		if err := tm.recordBusts(tx, t, table, g, update); err != nil {
+			return err
+		}
+
+		// В hand-for-hand стол ждет, пока раздачу не доиграют все столы
+		roundComplete := false
+		if t.HandForHand {
+			round := tm.rounds[t.ID]
+			if round == nil {
+				round = make(map[int]bool)
+				tm.rounds[t.ID] = round
+			}
+			round[table.ID] = true
+
+			var playing []int
+			if err := tx.Model(&models.Table{}).Where("tournament_id = ? AND players >= 2", t.ID).Pluck("id", &playing).Error; err != nil {
+				return err
+			}
+			for _, tableID := range playing {
+				if !round[tableID] {
+					update.hold = []int{table.ID}
+					return nil
+				}
+			}
+			delete(tm.rounds, t.ID)
+			roundComplete = true
+		}
+
+		// Забирать игроков можно только из столов, где раздача не идет и не начнется
+		movable := func(tableID int) bool {
+			return roundComplete || tableID == table.ID || Sessions == nil || !Sessions.IsRunning(tableID)
+		}
+		return tm.settle(tx, t, movable, update)
+	})
+	return update, err
+}
+
+// recordBusts выплачивает награды за головы игроков, проигравших все фишки,
+// и отмечает их выбывшими. Пока открыты ребаи, игрок остается за столом без
+// фишек и может вернуться в игру. Места выбывшим назначает settle.
+func (tm *TournamentManager) recordBusts(tx *gorm.DB, t *models.Tournament, table *models.Table, g *models.Game, update *tournamentUpdate) error {
+	now := time.Now()
+	out := 0
+	for _, player := range g.Players {
+		if player.Chips > 0 {
+			continue
+		}
+		var entry models.TournamentEntry
+		if err := tx.Where("tournament_id = ? AND user_uuid = ? AND eliminated_at IS NULL", t.ID, player.UserUUID).
+			Limit(1).Find(&entry).Error; err != nil {
+			return err
+		}
+		if entry.ID == 0 {
+			continue
+		}
+		if err := tm.payBounty(tx, t, &entry, g); err != nil {
+			return err
+		}
+
+		if t.RebuyOpen() && (t.MaxRebuys == 0 || entry.Rebuys < t.MaxRebuys) {
+			if err := tx.Omit(clause.Associations).Save(&entry).Error; err != nil {
+				return err
+			}
+			update.busted = append(update.busted, entry)
+			continue
+		}
+
+		// Стек на начало раздачи у вылетевшего равен его вкладу в банк
+		entry.EliminatedAt = &now
+		entry.EliminatedStack = player.TotalBet
+		if err := tx.Omit(clause.Associations).Save(&entry).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("table_id = ? AND user_uuid = ?", table.ID, player.UserUUID).Delete(&models.TablePlayer{}).Error; err != nil {
+			return err
+		}
+		out++
+	}
+	if out == 0 {
+		return nil
+	}
+	return tx.Model(table).Update("players", gorm.Expr("players - ?", out)).Error
+}
+
+// settle назначает места вылетевшим, ломает и выравнивает столы и включает
+// или выключает hand-for-hand. Hand-for-hand идет на пузыре — когда до
+// призовых мест остается один вылет, а столов больше одного.
+func (tm *TournamentManager) settle(tx *gorm.DB, t *models.Tournament, movable func(tableID int) bool, update *tournamentUpdate) error {
+	if err := tm.placeEliminated(tx, t, update); err != nil {
+		return err
+	}
+	if t.State == models.TournamentFinished {
+		return tx.Omit(clause.Associations).Save(t).Error
+	}
+
+	tables, err := tm.balanceTables(tx, t, movable, update)
+	if err != nil {
+		return err
+	}
+	bubble := onBubble(t, tables)
+	switch {
+	case bubble && !t.HandForHand:
+		t.HandForHand = true
+		update.handForHand = true
+
+		// Столы без текущей раздачи уже доиграли круг; остальные доиграют
+		round := make(map[int]bool)
+		for _, tt := range tables {
+			var active int64
+			if err := tx.Model(&models.Game{}).Where("table_id = ? AND state <> ?", tt.table.ID, models.GameStateFinished).Count(&active).Error; err != nil {
+				return err
+			}
+			if active == 0 {
+				round[tt.table.ID] = true
+			}
+			update.hold = append(update.hold, tt.table.ID)
+		}
+		if len(round) == len(tables) {
+			update.hold = nil
+			update.release = true
+		} else {
+			tm.rounds[t.ID] = round
+		}
+	case !bubble && t.HandForHand:
+		t.HandForHand = false
+		update.handForHand = true
+		update.release = true
+		delete(tm.rounds, t.ID)
+	case bubble:
+		// Круг доигран, начинается следующий
+		update.release = true
+	}
+	return tx.Omit(clause.Associations).Save(t).Error
+}
+
+// onBubble турнир на пузыре: до призовых мест остается один вылет, а столов
+// больше одного. На пузыре играется hand-for-hand.
+func onBubble(t *models.Tournament, tables []*tournamentTable) bool {
+	alive := 0
+	for _, tt := range tables {
+		alive += len(tt.players)
+	}
+	return len(tables) > 1 && alive == len(t.Payouts)+1
+}
+
+// placeEliminated назначает места вылетевшим, которым место еще не назначено.
+// Все они вылетели в одной раздаче или одном круге hand-for-hand.
+// Если остался один игрок, он занимает первое место и турнир завершается.
+func (tm *TournamentManager) placeEliminated(tx *gorm.DB, t *models.Tournament, update *tournamentUpdate) error {
+	var pending []models.TournamentEntry
+	if err := tx.Where("tournament_id = ? AND eliminated_at IS NOT NULL AND place = 0", t.ID).
+		Order("eliminated_stack DESC, id").Find(&pending).Error; err != nil {
+		return err
+	}
+	var alive []models.TournamentEntry
+	if err := tx.Where("tournament_id = ? AND eliminated_at IS NULL", t.ID).Find(&alive).Error; err != nil {
+		return err
+	}
+
+	splitPrizes(t, len(alive)+1, pending)
+
+	// Остался один игрок — он победитель
+	if len(alive) == 1 {
+		now := time.Now()
+		winner := alive[0]
+		winner.Place = 1
+		winner.Prize = Prize(t, 1)
+		winner.EliminatedAt = &now
+		// Награду за свою голову победитель забирает себе
+		if err := changeBalance(tx, winner.UserUUID, winner.Bounty, models.TransactionTournamentBounty, &t.ID); err != nil {
+			return err
+		}
+		winner.BountiesWon += winner.Bounty
+		winner.Bounty = 0
+		pending = append(pending, winner)
+
+		t.State = models.TournamentFinished
+		t.FinishedAt = &now
+		t.HandForHand = false
+		delete(tm.rounds, t.ID)
+	}
+
+	for i := range pending {
+		entry := &pending[i]
+		if err := tm.payPrize(tx, t, entry); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(entry).Error; err != nil {
+			return err
+		}
+		update.eliminated = append(update.eliminated, *entry)
+	}
+	return nil
+}
+
+// apply выполняет решения, принятые в транзакции: останавливает сломанные
+// столы, придерживает и запускает раздачи и отправляет события
+func (tm *TournamentManager) apply(update *tournamentUpdate) {
+	t := &update.tournament
+	if t.ID == 0 || (t.State != models.TournamentRunning && t.State != models.TournamentFinished) {
+		return
+	}
+
+	if Sessions != nil {
+		for _, tableID := range update.broken {
+			Sessions.Stop(tableID)
+		}
+		for _, tableID := range update.hold {
+			Sessions.Start(tableID)
+			Sessions.Hold(tableID)
+		}
+	}
+
+	closed := update.broken
+	switch {
+	case t.State == models.TournamentFinished:
+		log.Printf("Турнир %d завершен", t.ID)
+		var tableIDs []int
+		database.DB.Model(&models.Table{}).Where("tournament_id = ?", t.ID).Pluck("id", &tableIDs)
+		if Sessions != nil {
+			for _, tableID := range tableIDs {
+				Sessions.Stop(tableID)
+			}
+		}
+		closed = append(closed, tableIDs...)
+	case update.release || !t.HandForHand:
+		tm.resumeTables(t, update.release)
+	}
+
+	// Пересаженный игрок уходит с одного стола и садится за другой
+	if Hub != nil {
+		for _, move := range update.moves {
+			Hub.Publish(models.TableEvent{
+				Type:     "player_left",
+				TableID:  move.FromTableID,
+				UserUUID: move.UserUUID,
+			})
+			Hub.Publish(models.TableEvent{
+				Type:     "player_joined",
+				TableID:  move.ToTableID,
+				UserUUID: move.UserUUID,
+				Data: map[string]interface{}{
+					"seat_number": move.SeatNumber,
+				},
+			})
+		}
+		// События закрытых столов больше никто не запросит
+		for _, tableID := range closed {
+			Hub.DropTable(tableID)
+		}
+	}
+
+	if Kafka == nil {
+		return
+	}
+	for _, entry := range update.busted {
+		Kafka.PublishTournamentEvent(t.ID, "player_busted", entry)
+	}
+	for _, entry := range update.eliminated {
+		eventType := "player_eliminated"
+		if entry.Place == 1 {
+			eventType = "tournament_winner"
+		}
+		Kafka.PublishTournamentEvent(t.ID, eventType, entry)
+	}
+	for _, move := range update.moves {
+		Kafka.PublishTournamentEvent(t.ID, "player_moved", move)
+	}
+	for _, tableID := range update.broken {
+		Kafka.PublishTournamentEvent(t.ID, "table_broken", map[string]interface{}{
+			"table_id": tableID,
+		})
+	}
+	if update.finalTable != 0 {
+		Kafka.PublishTournamentEvent(t.ID, "final_table", map[string]interface{}{
+			"table_id": update.finalTable,
+		})
+	}
+	if update.handForHand {
+		eventType := "hand_for_hand_ended"
+		if t.HandForHand {
+			eventType = "hand_for_hand_started"
+		}
+		Kafka.PublishTournamentEvent(t.ID, eventType, nil)
+	}
+	if t.State == models.TournamentFinished {
+		Kafka.PublishTournamentEvent(t.ID, "tournament_finished", t)
+	}
+}
+
+// resumeTables запускает раздачи за столами турнира, где есть хотя бы двое.
+// С release придержанные столы отпускаются. В перерыв столы, наоборот,
+// придерживаются.
+func (tm *TournamentManager) resumeTables(t *models.Tournament, release bool) {
+	var tables []models.Table
+	database.DB.Where("tournament_id = ? AND players >= 2", t.ID).Find(&tables)
+	for _, table := range tables {
+		switch {
+		case t.OnBreak():
+			if Sessions != nil {
+				Sessions.Start(table.ID)
+				Sessions.Hold(table.ID)
+			}
+		case Sessions != nil && Sessions.IsRunning(table.ID):
+			if release {
+				Sessions.Release(table.ID)
+			}
+		default:
+			tm.startTable(table.ID)
+		}
+	}
+}
+
+// resume продолжает идущие турниры после перезапуска сервера. Раздачи,
+// прерванные перезапуском, доигрываются; за остальными столами начинаются
+// новые. Если турнир был в hand-for-hand, столы ждут, пока доиграют
+// прерванные раздачи.
+func (tm *TournamentManager) resume() {
+	var tournamentIDs []int
+	database.DB.Model(&models.Tournament{}).Where("state = ?", models.TournamentRunning).Pluck("id", &tournamentIDs)
+	for _, tournamentID := range tournamentIDs {
+		tm.mu.Lock()
+		update, err := tm.resumeTournament(tournamentID)
+		tm.mu.Unlock()
+		if err != nil {
+			log.Printf("Не удалось продолжить турнир %d: %v", tournamentID, err)
+			continue
+		}
+		log.Printf("Турнир %d продолжен после перезапуска", tournamentID)
+		tm.apply(update)
+	}
+}
+
+func (tm *TournamentManager) resumeTournament(tournamentID int) (*tournamentUpdate, error) {
+	update := &tournamentUpdate{}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		t := &update.tournament
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(t, tournamentID).Error; err != nil {
+			return err
+		}
+		if t.State != models.TournamentRunning {
+			return nil
+		}
+
+		var activeTables []int
+		if err := tx.Model(&models.Game{}).
+			Joins("JOIN tables ON tables.id = games.table_id").
+			Where("tables.tournament_id = ? AND games.state <> ?", t.ID, models.GameStateFinished).
+			Pluck("games.table_id", &activeTables).Error; err != nil {
+			return err
+		}
+		active := make(map[int]bool, len(activeTables))
+		for _, tableID := range activeTables {
+			active[tableID] = true
+		}
+
+		if t.HandForHand && len(active) > 0 {
+			round := make(map[int]bool)
+			var playing []int
+			if err := tx.Model(&models.Table{}).Where("tournament_id = ? AND players >= 2", t.ID).Pluck("id", &playing).Error; err != nil {
+				return err
+			}
+			for _, tableID := range playing {
+				if !active[tableID] {
+					round[tableID] = true
+				}
+				update.hold = append(update.hold, tableID)
+			}
+			tm.rounds[t.ID] = round
+			return nil
+		}
+
+		movable := func(tableID int) bool {
+			return !active[tableID]
+		}
+		return tm.settle(tx, t, movable, update)
+	})
+	return update, err
+}
+
+// payPrize начисляет приз за занятое место на баланс игрока
+func (tm *TournamentManager) payPrize(tx *gorm.DB, t *models.Tournament, entry *models.TournamentEntry) error {
+	return changeBalance(tx, entry.UserUUID, entry.Prize, models.TransactionTournamentPrize, &t.ID)
+}
+
+// splitPrizes назначает места и призы вылетевшим одновременно; pending
+// отсортированы по стеку на начало раздачи по убыванию, firstPlace — лучшее
+// из свободных мест. Игроки с равным стеком занимают лучшее из своих мест и
+// поровну делят сумму призов за эти места, остаток от деления получает первый.
+func splitPrizes(t *models.Tournament, firstPlace int, pending []models.TournamentEntry) {
+	for i := 0; i < len(pending); {
+		j := i + 1
+		for j < len(pending) && pending[j].EliminatedStack == pending[i].EliminatedStack {
+			j++
+		}
+		total := 0
+		for place := firstPlace + i; place < firstPlace+j; place++ {
+			total += Prize(t, place)
+		}
+		tied := j - i
+		for k := i; k < j; k++ {
+			pending[k].Place = firstPlace + i
+			pending[k].Prize = total / tied
+		}
+		pending[i].Prize += total % tied
+		i = j
+	}
+}
+
+// Prize приз за место в турнире. Остаток от округления долей получает победитель.
+func Prize(t *models.Tournament, place int) int {
+	if place < 1 || place > len(t.Payouts) {
+		return 0
+	}
+	prize := t.PrizePool * t.Payouts[place-1] / 100
+	if place == 1 {
+		paid := 0
+		for _, share := range t.Payouts {
+			paid += t.PrizePool * share / 100
+		}
+		prize += t.PrizePool - paid
+	}
+	return prize
+}
+
+// tick поднимает блайнды по часам, запускает заполненные Sit & Go,
+// которые не удалось стартовать сразу, и турниры, время которых пришло
+func (tm *TournamentManager) tick() {
+	now := time.Now()
+
+	var running []models.Tournament
+	database.DB.Where("state = ?", models.TournamentRunning).Find(&running)
+	for i := range running {
+		tm.advanceLevel(&running[i], now)
+	}
+
+	var registering []models.Tournament
+	database.DB.Where("state = ?", models.TournamentRegistering).Find(&registering)
+	for _, t := range registering {
+		switch t.Type {
+		case models.TournamentSitAndGo:
+			var entries int64
+			database.DB.Model(&models.TournamentEntry{}).Where("tournament_id = ?", t.ID).Count(&entries)
+			if entries < int64(t.MaxPlayers) {
+				continue
+			}
+		case models.TournamentMultiTable:
+			if t.StartsAt == nil || now.Before(*t.StartsAt) {
+				continue
+			}
+		}
+		if err := tm.start(t.ID); err != nil {
+			log.Printf("Не удалось запустить турнир %d: %v", t.ID, err)
+		}
+	}
+}
+
+// advanceLevel переводит турнир на следующий уровень, когда истекло время
+// текущего. Новые блайнды действуют со следующей раздачи. Перерыв длится
+// столько же, сколько уровень: столы доигрывают текущую раздачу и ждут.
+func (tm *TournamentManager) advanceLevel(t *models.Tournament, now time.Time) {
+	if t.LevelStartedAt == nil || t.LevelMinutes <= 0 {
+		return
+	}
+
+	duration := time.Duration(t.LevelMinutes) * time.Minute
+	level, startedAt := t.Level, *t.LevelStartedAt
+	for level < len(t.Levels) && !now.Before(startedAt.Add(duration)) {
+		level++
+		startedAt = startedAt.Add(duration)
+	}
+	if level == t.Level {
+		return
+	}
+
+	wasBreak, rebuyOpen := t.OnBreak(), t.RebuyOpen()
+	t.Level = level
+	t.LevelStartedAt = &startedAt
+	if err := database.DB.Model(t).Updates(map[string]interface{}{
+		"level":            level,
+		"level_started_at": startedAt,
+	}).Error; err != nil {
+		log.Printf("Не удалось поднять блайнды в турнире %d: %v", t.ID, err)
+		return
+	}
+
+	blinds := t.CurrentBlinds()
+	database.DB.Model(&models.Table{}).Where("tournament_id = ?", t.ID).
+		Update("blinds", fmt.Sprintf("%d/%d", blinds.SmallBlind, blinds.BigBlind))
+
+	if rebuyOpen && !t.RebuyOpen() {
+		tm.closeRebuys(t.ID)
+	}
+
+	if t.OnBreak() {
+		tm.resumeTables(t, false)
+		if Kafka != nil {
+			Kafka.PublishTournamentEvent(t.ID, "break_started", map[string]interface{}{
+				"level":   level,
+				"minutes": t.LevelMinutes,
+				"add_on":  t.AddOnOpen(),
+			})
+		}
+		return
+	}
+	if wasBreak {
+		// Круг hand-for-hand, прерванный перерывом, начинается заново
+		tm.mu.Lock()
+		delete(tm.rounds, t.ID)
+		tm.mu.Unlock()
+		tm.resumeTables(t, true)
+		if Kafka != nil {
+			Kafka.PublishTournamentEvent(t.ID, "break_ended", map[string]interface{}{
+				"level": level,
+			})
+		}
+	}
+
+	if Kafka != nil {
+		Kafka.PublishTournamentEvent(t.ID, "blinds_up", map[string]interface{}{
+			"level":       level,
+			"small_blind": blinds.SmallBlind,
+			"big_blind":   blinds.BigBlind,
+		})
+	}
+}
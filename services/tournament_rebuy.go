@@ -0,0 +1,295 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"poker/database"
+	"poker/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Rebuy докупает стартовый стек по цене buy-in, пока открыт период ребаев.
+// Ребай можно сделать, когда стек не больше стартового, и только между
+// раздачами. Награда за голову игрока растет на bounty турнира.
+func (tm *TournamentManager) Rebuy(tournamentID int, user *models.User) (*models.TournamentEntry, error) {
+	var t models.Tournament
+	var entry models.TournamentEntry
+	cost := 0
+	tm.mu.Lock()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, tournamentID).Error; err != nil {
+			return ErrTournamentNotFound
+		}
+		if !t.RebuyOpen() {
+			return ErrRebuyClosed
+		}
+
+		seat, err := tm.activeSeat(tx, &t, user.UUID, &entry)
+		if err != nil {
+			return err
+		}
+		if err := checkRebuy(&t, &entry, seat.Chips); err != nil {
+			return err
+		}
+
+		cost = t.BuyIn + t.Fee
+		if err := changeBalance(tx, user.UUID, -cost, models.TransactionTournamentRebuy, &t.ID); err != nil {
+			return err
+		}
+		if err := postHouse(tx, user.UUID, t.Fee, models.TransactionTournamentFee, &t.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(seat).Update("chips", gorm.Expr("chips + ?", t.StartingStack)).Error; err != nil {
+			return err
+		}
+
+		entry.Rebuys++
+		entry.Bounty += t.Bounty
+		if err := tx.Omit(clause.Associations).Save(&entry).Error; err != nil {
+			return err
+		}
+		t.PrizePool += t.BuyIn - t.Bounty
+		return tx.Model(&t).Update("prize_pool", t.PrizePool).Error
+	})
+	tm.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	user.Balance -= cost
+
+	if Kafka != nil {
+		Kafka.PublishTournamentEvent(t.ID, "player_rebuy", map[string]interface{}{
+			"entry":      entry,
+			"prize_pool": t.PrizePool,
+		})
+	}
+
+	// Игрок мог ждать ребая за столом, где больше никого с фишками нет
+	tm.resumeTables(&t, false)
+	return &entry, nil
+}
+
+// checkRebuy проверяет, может ли участник со стеком chips сделать ребай
+func checkRebuy(t *models.Tournament, entry *models.TournamentEntry, chips int) error {
+	if !t.RebuyOpen() {
+		return ErrRebuyClosed
+	}
+	if t.MaxRebuys > 0 && entry.Rebuys >= t.MaxRebuys {
+		return fmt.Errorf("%w: limit of %d rebuys reached", ErrRebuyNotAllowed, t.MaxRebuys)
+	}
+	if chips > t.StartingStack {
+		return fmt.Errorf("%w: stack is above the starting stack", ErrRebuyNotAllowed)
+	}
+	return nil
+}
+
+// AddOn разово докупает фишки в перерыв. Деньги целиком идут в призовой фонд.
+func (tm *TournamentManager) AddOn(tournamentID int, user *models.User) (*models.TournamentEntry, error) {
+	var t models.Tournament
+	var entry models.TournamentEntry
+	tm.mu.Lock()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, tournamentID).Error; err != nil {
+			return ErrTournamentNotFound
+		}
+		if !t.AddOnOpen() {
+			return ErrAddOnClosed
+		}
+
+		seat, err := tm.activeSeat(tx, &t, user.UUID, &entry)
+		if err != nil {
+			return err
+		}
+		if entry.AddOn {
+			return ErrAddOnTaken
+		}
+
+		if err := changeBalance(tx, user.UUID, -t.AddOnCost, models.TransactionTournamentAddOn, &t.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(seat).Update("chips", gorm.Expr("chips + ?", t.AddOnChips)).Error; err != nil {
+			return err
+		}
+
+		entry.AddOn = true
+		if err := tx.Omit(clause.Associations).Save(&entry).Error; err != nil {
+			return err
+		}
+		t.PrizePool += t.AddOnCost
+		return tx.Model(&t).Update("prize_pool", t.PrizePool).Error
+	})
+	tm.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	user.Balance -= t.AddOnCost
+
+	if Kafka != nil {
+		Kafka.PublishTournamentEvent(t.ID, "player_add_on", map[string]interface{}{
+			"entry":      entry,
+			"prize_pool": t.PrizePool,
+		})
+	}
+	return &entry, nil
+}
+
+// activeSeat находит участника турнира, который еще в игре, и его место
+// за столом. Фишки нельзя докупать, пока игрок участвует в раздаче: после
+// нее стек будет перезаписан.
+func (tm *TournamentManager) activeSeat(tx *gorm.DB, t *models.Tournament, userUUID string, entry *models.TournamentEntry) (*models.TablePlayer, error) {
+	if err := tx.Where("tournament_id = ? AND user_uuid = ?", t.ID, userUUID).Limit(1).Find(entry).Error; err != nil {
+		return nil, err
+	}
+	if entry.ID == 0 {
+		return nil, ErrNotRegistered
+	}
+	if entry.EliminatedAt != nil {
+		return nil, ErrNotInTournament
+	}
+
+	var seat models.TablePlayer
+	if err := tx.Select("table_players.*").
+		Joins("JOIN tables ON tables.id = table_players.table_id").
+		Where("tables.tournament_id = ? AND table_players.user_uuid = ?", t.ID, userUUID).
+		Limit(1).Find(&seat).Error; err != nil {
+		return nil, err
+	}
+	if seat.ID == 0 {
+		return nil, ErrNotInTournament
+	}
+
+	var inHand int64
+	if err := tx.Model(&models.GamePlayer{}).
+		Joins("JOIN games ON games.id = game_players.game_id").
+		Where("games.table_id = ? AND games.state <> ? AND game_players.user_uuid = ?", seat.TableID, models.GameStateFinished, userUUID).
+		Count(&inHand).Error; err != nil {
+		return nil, err
+	}
+	if inHand > 0 {
+		return nil, ErrHandInProgress
+	}
+	return &seat, nil
+}
+
+// payBounty выплачивает награду за голову игрока, проигравшего все фишки,
+// тем, кто выиграл последний банк, на который он претендовал. Награда
+// делится поровну, остаток получает первый из победителей.
+func (tm *TournamentManager) payBounty(tx *gorm.DB, t *models.Tournament, entry *models.TournamentEntry, g *models.Game) error {
+	winners := bustedBy(g, entry.UserUUID)
+	if len(winners) == 0 {
+		return nil
+	}
+
+	entry.EliminatedBy = splitBounty(entry.Bounty, winners)
+	for _, payment := range entry.EliminatedBy {
+		if err := changeBalance(tx, payment.UserUUID, payment.Amount, models.TransactionTournamentBounty, &t.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TournamentEntry{}).
+			Where("tournament_id = ? AND user_uuid = ?", t.ID, payment.UserUUID).
+			Updates(map[string]interface{}{
+				"bounties_won": gorm.Expr("bounties_won + ?", payment.Amount),
+				"knockouts":    gorm.Expr("knockouts + 1"),
+			}).Error; err != nil {
+			return err
+		}
+	}
+	entry.Bounty = 0
+	return nil
+}
+
+// splitBounty делит награду поровну между победителями, остаток получает первый
+func splitBounty(bounty int, winners []string) models.Bounties {
+	payments := make(models.Bounties, len(winners))
+	for i, userUUID := range winners {
+		payments[i] = models.BountyPayment{UserUUID: userUUID, Amount: bounty / len(winners)}
+	}
+	payments[0].Amount += bounty % len(winners)
+	return payments
+}
+
+// bustedBy победители последнего банка, на который претендовал игрок
+func bustedBy(g *models.Game, userUUID string) []string {
+	if g.Showdown == nil {
+		return nil
+	}
+	var winners []string
+	for _, pot := range g.Showdown.Pots {
+		if slices.Contains(pot.Eligible, userUUID) {
+			winners = pot.Winners
+		}
+	}
+	return winners
+}
+
+// closeRebuys выводит из турнира тех, кто к концу периода ребаев остался
+// без фишек
+func (tm *TournamentManager) closeRebuys(tournamentID int) {
+	tm.mu.Lock()
+	update, err := tm.eliminateBroke(tournamentID)
+	tm.mu.Unlock()
+	if err != nil {
+		log.Printf("Не удалось закрыть ребаи в турнире %d: %v", tournamentID, err)
+		return
+	}
+	tm.apply(update)
+}
+
+func (tm *TournamentManager) eliminateBroke(tournamentID int) (*tournamentUpdate, error) {
+	update := &tournamentUpdate{}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var t models.Tournament
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, tournamentID).Error; err != nil {
+			return err
+		}
+		if t.State != models.TournamentRunning {
+			return nil
+		}
+
+		var broke []models.TablePlayer
+		if err := tx.Select("table_players.*").
+			Joins("JOIN tables ON tables.id = table_players.table_id").
+			Where("tables.tournament_id = ? AND table_players.chips = 0", t.ID).
+			Find(&broke).Error; err != nil {
+			return err
+		}
+		if len(broke) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		for i := range broke {
+			if err := tx.Model(&models.TournamentEntry{}).
+				Where("tournament_id = ? AND user_uuid = ? AND eliminated_at IS NULL", t.ID, broke[i].UserUUID).
+				Updates(map[string]interface{}{
+					"eliminated_at":    now,
+					"eliminated_stack": 0,
+				}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&broke[i]).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Table{}).Where("id = ?", broke[i].TableID).
+				Update("players", gorm.Expr("players - 1")).Error; err != nil {
+				return err
+			}
+		}
+
+		update.tournament = t
+		// В hand-for-hand места назначатся в конце круга
+		if t.HandForHand {
+			return nil
+		}
+		movable := func(tableID int) bool {
+			return Sessions == nil || !Sessions.IsRunning(tableID)
+		}
+		return tm.settle(tx, &update.tournament, movable, update)
+	})
+	return update, err
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"poker/models"
+)
+
+func TestBustedBy(t *testing.T) {
+	tests := []struct {
+		name     string
+		showdown *models.ShowdownResult
+		want     []string
+	}{
+		{
+			name: "single winner",
+			showdown: &models.ShowdownResult{Pots: []models.PotResult{
+				{Amount: 600, Eligible: []string{"alice", "bob", "carol"}, Winners: []string{"alice"}},
+			}},
+			want: []string{"alice"},
+		},
+		{
+			name: "split pot",
+			showdown: &models.ShowdownResult{Pots: []models.PotResult{
+				{Amount: 600, Eligible: []string{"alice", "bob", "carol"}, Winners: []string{"alice", "carol"}},
+			}},
+			want: []string{"alice", "carol"},
+		},
+		{
+			// Боб претендовал только на основной банк; побочный разыгран без него
+			name: "last pot the player was eligible for",
+			showdown: &models.ShowdownResult{Pots: []models.PotResult{
+				{Amount: 300, Eligible: []string{"alice", "bob", "carol"}, Winners: []string{"carol"}},
+				{Amount: 400, Eligible: []string{"alice", "carol"}, Winners: []string{"alice"}},
+			}},
+			want: []string{"carol"},
+		},
+		{
+			name: "side pot decides the bust",
+			showdown: &models.ShowdownResult{Pots: []models.PotResult{
+				{Amount: 300, Eligible: []string{"alice", "bob", "carol"}, Winners: []string{"bob"}},
+				{Amount: 400, Eligible: []string{"alice", "bob"}, Winners: []string{"alice"}},
+			}},
+			want: []string{"alice"},
+		},
+		{
+			name: "no showdown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &models.Game{Showdown: tt.showdown}
+			if got := bustedBy(g, "bob"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("busted by %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitBounty(t *testing.T) {
+	tests := []struct {
+		name    string
+		bounty  int
+		winners []string
+		want    models.Bounties
+	}{
+		{"single winner", 50, []string{"alice"}, models.Bounties{{UserUUID: "alice", Amount: 50}}},
+		{"even split", 50, []string{"alice", "carol"}, models.Bounties{
+			{UserUUID: "alice", Amount: 25},
+			{UserUUID: "carol", Amount: 25},
+		}},
+		{"remainder to the first winner", 50, []string{"alice", "carol", "dave"}, models.Bounties{
+			{UserUUID: "alice", Amount: 18},
+			{UserUUID: "carol", Amount: 16},
+			{UserUUID: "dave", Amount: 16},
+		}},
+		{"no bounty", 0, []string{"alice", "carol"}, models.Bounties{
+			{UserUUID: "alice", Amount: 0},
+			{UserUUID: "carol", Amount: 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitBounty(tt.bounty, tt.winners); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("payments %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRebuy(t *testing.T) {
+	running := func(level int) *models.Tournament {
+		return &models.Tournament{
+			State:         models.TournamentRunning,
+			Level:         level,
+			RebuyLevels:   3,
+			MaxRebuys:     2,
+			StartingStack: 1500,
+		}
+	}
+	tests := []struct {
+		name       string
+		tournament *models.Tournament
+		rebuys     int
+		chips      int
+		want       error
+	}{
+		{"busted in the rebuy period", running(2), 0, 0, nil},
+		{"last rebuy level", running(3), 1, 900, nil},
+		{"window closed", running(4), 0, 0, ErrRebuyClosed},
+		{"tournament finished", &models.Tournament{State: models.TournamentFinished, Level: 1, RebuyLevels: 3}, 0, 0, ErrRebuyClosed},
+		{"no rebuy period", &models.Tournament{State: models.TournamentRunning, Level: 1}, 0, 0, ErrRebuyClosed},
+		{"rebuy limit reached", running(2), 2, 0, ErrRebuyNotAllowed},
+		{"stack above starting", running(2), 0, 1600, ErrRebuyNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &models.TournamentEntry{Rebuys: tt.rebuys}
+			err := checkRebuy(tt.tournament, entry, tt.chips)
+			if tt.want == nil && err != nil {
+				t.Errorf("rebuy rejected: %v", err)
+			} else if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"poker/game"
+	"poker/models"
+
+	"gorm.io/gorm"
+)
+
+// tableMove пересадка игрока на другой стол турнира
+type tableMove struct {
+	UserUUID    string `json:"user_uuid"`
+	FromTableID int    `json:"from_table_id"`
+	ToTableID   int    `json:"to_table_id"`
+	SeatNumber  int    `json:"seat_number"`
+}
+
+// tournamentTable стол турнира с игроками и кнопкой дилера прошлой раздачи
+type tournamentTable struct {
+	table   models.Table
+	players []models.TablePlayer
+	dealer  int // -1, если за столом еще не играли
+}
+
+// loadTournamentTables загружает столы турнира, за которыми есть игроки
+func loadTournamentTables(tx *gorm.DB, tournamentID int) ([]*tournamentTable, error) {
+	var tables []models.Table
+	if err := tx.Where("tournament_id = ? AND players > 0", tournamentID).Order("id").Find(&tables).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*tournamentTable, 0, len(tables))
+	for _, table := range tables {
+		tt, err := loadTournamentTable(tx, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(tt.players) > 0 {
+			result = append(result, tt)
+		}
+	}
+	return result, nil
+}
+
+func loadTournamentTable(tx *gorm.DB, table models.Table) (*tournamentTable, error) {
+	tt := &tournamentTable{table: table, dealer: -1}
+	if err := tx.Where("table_id = ?", table.ID).Order("seat_number ASC").Find(&tt.players).Error; err != nil {
+		return nil, err
+	}
+	var lastGame models.Game
+	if err := tx.Where("table_id = ?", table.ID).Order("created_at DESC").Limit(1).Find(&lastGame).Error; err != nil {
+		return nil, err
+	}
+	if lastGame.ID != "" {
+		tt.dealer = lastGame.DealerPosition
+	}
+	return tt, nil
+}
+
+func (tt *tournamentTable) seats() []int {
+	seats := make([]int, len(tt.players))
+	for i, player := range tt.players {
+		seats[i] = player.SeatNumber
+	}
+	return seats
+}
+
+// nextBigBlind индекс игрока, которому раньше всех ставить большой блайнд
+func (tt *tournamentTable) nextBigBlind() int {
+	seats := tt.seats()
+	best, bestHands := 0, len(seats)+1
+	for i, seat := range seats {
+		if hands := handsUntilBigBlind(seats, tt.dealer, seat); hands < bestHands {
+			best, bestHands = i, hands
+		}
+	}
+	return best
+}
+
+// worstSeat худшее свободное место стола — то, до которого большой блайнд
+// дойдет раньше всего. 0, если свободных мест нет.
+func (tt *tournamentTable) worstSeat() int {
+	seats := tt.seats()
+	occupied := make(map[int]bool, len(seats))
+	for _, seat := range seats {
+		occupied[seat] = true
+	}
+
+	best, bestHands := 0, tt.table.MaxSeats+1
+	for seat := 1; seat <= tt.table.MaxSeats; seat++ {
+		if occupied[seat] {
+			continue
+		}
+		if hands := handsUntilBigBlind(append(seats, seat), tt.dealer, seat); hands < bestHands {
+			best, bestHands = seat, hands
+		}
+	}
+	return best
+}
+
+// handsUntilBigBlind через сколько раздач игрок на месте seat поставит
+// большой блайнд, если за столом заняты места seats, а кнопка в прошлой
+// раздаче была на месте dealer. Считается тем же движком, что двигает кнопку.
+func handsUntilBigBlind(seats []int, dealer, seat int) int {
+	g := &models.Game{DealerPosition: dealer}
+	for _, position := range seats {
+		g.Players = append(g.Players, models.GamePlayer{Position: position})
+	}
+
+	engine := game.NewPokerEngine(g)
+	for hands := 0; hands < len(seats); hands++ {
+		engine.MoveButton()
+		if _, bigBlind := engine.BlindPositions(); bigBlind == seat {
+			return hands
+		}
+	}
+	return len(seats)
+}
+
+// seatMover пересаживает игрока на место seat стола to
+type seatMover func(player *models.TablePlayer, from, to *models.Table, seat int) error
+
+// balanceTables ломает и выравнивает столы турнира, записывая пересадки в базу
+func (tm *TournamentManager) balanceTables(tx *gorm.DB, t *models.Tournament, movable func(tableID int) bool, update *tournamentUpdate) ([]*tournamentTable, error) {
+	tables, err := loadTournamentTables(tx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return rebalance(tables, t.TableSize, movable, update, func(player *models.TablePlayer, from, to *models.Table, seat int) error {
+		return Manager.MovePlayer(tx, player, from, to, seat)
+	})
+}
+
+// rebalance ломает столы, пока оставшиеся вмещают всех игроков, и
+// выравнивает столы так, чтобы они различались не больше чем на одного
+// игрока. Забирать игроков можно только из столов, для которых movable
+// вернет true; остальные дождутся конца своей раздачи. Пересаживается
+// игрок, которому следующим ставить большой блайнд, на худшее свободное
+// место нового стола, поэтому никто не пропускает блайнды. Возвращает
+// столы, за которыми остались игроки.
+func rebalance(tables []*tournamentTable, tableSize int, movable func(tableID int) bool, update *tournamentUpdate, move seatMover) ([]*tournamentTable, error) {
+	alive := 0
+	for _, tt := range tables {
+		alive += len(tt.players)
+	}
+
+	// Ломается самый маленький стол
+	for len(tables) > 1 && (alive+tableSize-1)/tableSize < len(tables) {
+		broken := -1
+		for i, tt := range tables {
+			if !movable(tt.table.ID) {
+				continue
+			}
+			if broken == -1 || len(tt.players) <= len(tables[broken].players) {
+				broken = i
+			}
+		}
+		if broken == -1 || len(tables[broken].players) > len(smallestTable(tables).players) {
+			break
+		}
+
+		source := tables[broken]
+		tables = append(tables[:broken], tables[broken+1:]...)
+		for len(source.players) > 0 {
+			if err := moveNextBigBlind(source, smallestTable(tables), update, move); err != nil {
+				return nil, err
+			}
+		}
+		update.broken = append(update.broken, source.table.ID)
+		if len(tables) == 1 {
+			update.finalTable = tables[0].table.ID
+		}
+	}
+
+	for {
+		target := smallestTable(tables)
+		var source *tournamentTable
+		for _, tt := range tables {
+			if movable(tt.table.ID) && len(tt.players) > len(target.players)+1 &&
+				(source == nil || len(tt.players) > len(source.players)) {
+				source = tt
+			}
+		}
+		if source == nil {
+			return tables, nil
+		}
+		if err := moveNextBigBlind(source, target, update, move); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// smallestTable стол, за которым меньше всего игроков
+func smallestTable(tables []*tournamentTable) *tournamentTable {
+	var smallest *tournamentTable
+	for _, tt := range tables {
+		if smallest == nil || len(tt.players) < len(smallest.players) {
+			smallest = tt
+		}
+	}
+	return smallest
+}
+
+// moveNextBigBlind пересаживает игрока, которому следующим ставить большой
+// блайнд, на худшее свободное место стола to
+func moveNextBigBlind(from, to *tournamentTable, update *tournamentUpdate, move seatMover) error {
+	seat := to.worstSeat()
+	if seat == 0 {
+		return ErrTableFull
+	}
+
+	i := from.nextBigBlind()
+	player := from.players[i]
+	if err := move(&player, &from.table, &to.table, seat); err != nil {
+		return err
+	}
+	from.players = append(from.players[:i], from.players[i+1:]...)
+	to.players = append(to.players, player)
+
+	update.moves = append(update.moves, tableMove{
+		UserUUID:    player.UserUUID,
+		FromTableID: from.table.ID,
+		ToTableID:   to.table.ID,
+		SeatNumber:  seat,
+	})
+	return nil
+}
+
+// seatLatePlayer сажает поздно зарегистрированного игрока на худшее место
+// стола, где меньше всего игроков. Если все столы заполнены, открывается новый.
+func (tm *TournamentManager) seatLatePlayer(tx *gorm.DB, t *models.Tournament, userUUID string) error {
+	tables, err := loadTournamentTables(tx, t.ID)
+	if err != nil {
+		return err
+	}
+
+	target := smallestTable(tables)
+	if target == nil || len(target.players) >= target.table.MaxSeats {
+		table, err := Manager.CreateTournamentTable(tx, t, t.TableSize)
+		if err != nil {
+			return err
+		}
+		target = &tournamentTable{table: *table, dealer: -1}
+	}
+	return Manager.SeatPlayerAt(tx, &target.table, userUUID, t.StartingStack, target.worstSeat())
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"poker/models"
+)
+
+// newTestTable стол турнира с игроками на местах seats; игрок на месте N
+// стола T получает UUID "T-N"
+func newTestTable(id, maxSeats, dealer int, seats ...int) *tournamentTable {
+	tt := &tournamentTable{
+		table:  models.Table{ID: id, MaxSeats: maxSeats, Players: len(seats)},
+		dealer: dealer,
+	}
+	for _, seat := range seats {
+		tt.players = append(tt.players, models.TablePlayer{
+			TableID:    id,
+			UserUUID:   fmt.Sprintf("%d-%d", id, seat),
+			SeatNumber: seat,
+		})
+	}
+	return tt
+}
+
+// testMover пересаживает игрока так же, как TableManager.MovePlayer, но без базы
+func testMover(player *models.TablePlayer, from, to *models.Table, seat int) error {
+	if to.Players >= to.MaxSeats {
+		return ErrTableFull
+	}
+	player.TableID = to.ID
+	player.SeatNumber = seat
+	from.Players--
+	to.Players++
+	return nil
+}
+
+func tableSizes(tables []*tournamentTable) []int {
+	sizes := make([]int, len(tables))
+	for i, tt := range tables {
+		sizes[i] = len(tt.players)
+	}
+	return sizes
+}
+
+func TestHandsUntilBigBlind(t *testing.T) {
+	tests := []struct {
+		name   string
+		seats  []int
+		dealer int
+		want   map[int]int // место -> раздач до большого блайнда
+	}{
+		{"full ring", []int{1, 2, 3, 4}, 1, map[int]int{4: 0, 1: 1, 2: 2, 3: 3}},
+		{"no hands played yet", []int{1, 2, 3}, -1, map[int]int{3: 0, 1: 1, 2: 2}},
+		{"button on an empty seat", []int{1, 3, 5}, 4, map[int]int{3: 0, 5: 1, 1: 2}},
+		{"heads-up dealer posts small blind", []int{2, 5}, 2, map[int]int{2: 0, 5: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seat, want := range tt.want {
+				if got := handsUntilBigBlind(tt.seats, tt.dealer, seat); got != want {
+					t.Errorf("seat %d: %d hands until big blind, want %d", seat, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWorstSeat(t *testing.T) {
+	tests := []struct {
+		name  string
+		table *tournamentTable
+		want  int
+	}{
+		// Место 6 ставит большой блайнд через раздачу, место 3 — через четыре
+		{"seat reached by the big blind first", newTestTable(1, 6, 1, 1, 2, 4, 5), 6},
+		{"empty table", newTestTable(1, 6, -1), 1},
+		{"full table", newTestTable(1, 3, 1, 1, 2, 3), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.table.worstSeat(); got != tt.want {
+				t.Errorf("worst seat %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	tests := []struct {
+		name       string
+		tableSize  int
+		tables     []*tournamentTable
+		busy       map[int]bool // Столы, где идет раздача
+		wantSizes  []int
+		wantBroken []int
+		wantFinal  int
+	}{
+		{
+			name:      "even out tables",
+			tableSize: 9,
+			tables: []*tournamentTable{
+				newTestTable(1, 9, 1, 1, 2, 3, 4, 5, 6, 7, 8, 9),
+				newTestTable(2, 9, 3, 1, 2, 3, 4, 5, 6, 7, 8, 9),
+				newTestTable(3, 9, 2, 2, 5, 8),
+			},
+			wantSizes: []int{7, 7, 7},
+		},
+		{
+			name:      "break the smallest table",
+			tableSize: 9,
+			tables: []*tournamentTable{
+				newTestTable(1, 9, 1, 1, 2, 3, 4, 5, 6),
+				newTestTable(2, 9, 4, 2, 3, 4, 5, 6, 7),
+				newTestTable(3, 9, 9, 1, 3, 7, 9),
+			},
+			wantSizes:  []int{8, 8},
+			wantBroken: []int{3},
+		},
+		{
+			name:      "break down to the final table",
+			tableSize: 9,
+			tables: []*tournamentTable{
+				newTestTable(1, 9, 1, 1, 4, 7),
+				newTestTable(2, 9, 2, 2, 6),
+			},
+			wantSizes:  []int{5},
+			wantBroken: []int{2},
+			wantFinal:  1,
+		},
+		{
+			name:      "busy table is not broken",
+			tableSize: 9,
+			tables: []*tournamentTable{
+				newTestTable(1, 9, 1, 1, 2, 3, 4, 5, 6),
+				newTestTable(2, 9, 4, 2, 3, 4, 5, 6, 7),
+				newTestTable(3, 9, 9, 1, 3, 7, 9),
+			},
+			busy:      map[int]bool{3: true},
+			wantSizes: []int{5, 6, 5},
+		},
+		{
+			name:      "busy table keeps its players until the hand ends",
+			tableSize: 9,
+			tables: []*tournamentTable{
+				newTestTable(1, 9, 1, 1, 2, 3, 4, 5, 6, 7, 8, 9),
+				newTestTable(2, 9, 1, 1, 2, 3),
+			},
+			busy:      map[int]bool{1: true},
+			wantSizes: []int{9, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alive := 0
+			for _, table := range tt.tables {
+				alive += len(table.players)
+			}
+			movable := func(tableID int) bool { return !tt.busy[tableID] }
+
+			update := &tournamentUpdate{}
+			tables, err := rebalance(tt.tables, tt.tableSize, movable, update, testMover)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sizes := tableSizes(tables); !reflect.DeepEqual(sizes, tt.wantSizes) {
+				t.Errorf("table sizes %v, want %v", sizes, tt.wantSizes)
+			}
+			if !reflect.DeepEqual(update.broken, tt.wantBroken) {
+				t.Errorf("broken tables %v, want %v", update.broken, tt.wantBroken)
+			}
+			if update.finalTable != tt.wantFinal {
+				t.Errorf("final table %d, want %d", update.finalTable, tt.wantFinal)
+			}
+
+			seated := 0
+			for _, table := range tables {
+				seated += len(table.players)
+				if table.table.Players != len(table.players) {
+					t.Errorf("table %d counts %d players, seats %d", table.table.ID, table.table.Players, len(table.players))
+				}
+				seats := make(map[int]bool)
+				for _, player := range table.players {
+					if player.TableID != table.table.ID || seats[player.SeatNumber] {
+						t.Errorf("table %d: bad seat for %s (table %d, seat %d)", table.table.ID, player.UserUUID, player.TableID, player.SeatNumber)
+					}
+					seats[player.SeatNumber] = true
+				}
+			}
+			if seated != alive {
+				t.Errorf("%d players seated, want %d", seated, alive)
+			}
+			if len(update.moves) != 0 && len(tt.busy) == 0 {
+				sizes := tableSizes(tables)
+				min, max := sizes[0], sizes[0]
+				for _, size := range sizes {
+					if size < min {
+						min = size
+					}
+					if size > max {
+						max = size
+					}
+				}
+				if max-min > 1 {
+					t.Errorf("table sizes %v differ by more than one", sizes)
+				}
+			}
+		})
+	}
+}
+
+// Игрок сломанного стола, которому следующим ставить большой блайнд, садится
+// туда, где большой блайнд дойдет до него раньше всего, и не пропускает блайнды
+func TestBreakTableKeepsBigBlind(t *testing.T) {
+	tables := []*tournamentTable{
+		newTestTable(1, 6, 2, 1, 2, 3, 4),
+		newTestTable(2, 6, 4, 1, 2, 3, 4),
+		newTestTable(3, 6, 2, 2, 5),
+	}
+	update := &tournamentUpdate{}
+	if _, err := rebalance(tables, 6, func(int) bool { return true }, update, testMover); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []tableMove{
+		// На месте 2 третьего стола большой блайнд в следующей раздаче, и на
+		// месте 5 первого стола тоже
+		{UserUUID: "3-2", FromTableID: 3, ToTableID: 1, SeatNumber: 5},
+		// Место 5 второго стола — одно из двух свободных, до которых блайнд
+		// дойдет через три раздачи; у игрока на третьем столе была одна
+		{UserUUID: "3-5", FromTableID: 3, ToTableID: 2, SeatNumber: 5},
+	}
+	if !reflect.DeepEqual(update.moves, want) {
+		t.Fatalf("moves %+v, want %+v", update.moves, want)
+	}
+	if hands := handsUntilBigBlind(tables[0].seats(), tables[0].dealer, 5); hands != 0 {
+		t.Errorf("moved big blind waits %d hands at the new table, want 0", hands)
+	}
+}
+
+func TestOnBubble(t *testing.T) {
+	tournament := &models.Tournament{Payouts: models.PayoutStructure{50, 30, 20}}
+	tests := []struct {
+		name   string
+		tables []*tournamentTable
+		want   bool
+	}{
+		{
+			name:   "one bust from the money on two tables",
+			tables: []*tournamentTable{newTestTable(1, 9, 1, 1, 2), newTestTable(2, 9, 1, 1, 2)},
+			want:   true,
+		},
+		{
+			name:   "two busts from the money",
+			tables: []*tournamentTable{newTestTable(1, 9, 1, 1, 2, 3), newTestTable(2, 9, 1, 1, 2)},
+		},
+		{
+			name:   "in the money",
+			tables: []*tournamentTable{newTestTable(1, 9, 1, 1, 2), newTestTable(2, 9, 1, 1)},
+		},
+		{
+			name:   "final table plays normally",
+			tables: []*tournamentTable{newTestTable(1, 9, 1, 1, 2, 3, 4)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := onBubble(tournament, tt.tables); got != tt.want {
+				t.Errorf("on bubble = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
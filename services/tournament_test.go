@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+
+	"poker/models"
+)
+
+func TestPrize(t *testing.T) {
+	tournament := &models.Tournament{PrizePool: 1001, Payouts: models.PayoutStructure{50, 30, 20}}
+	tests := []struct {
+		place int
+		want  int
+	}{
+		{1, 501}, // 500 + остаток от округления
+		{2, 300},
+		{3, 200},
+		{4, 0},
+		{0, 0},
+	}
+	total := 0
+	for _, tt := range tests {
+		got := Prize(tournament, tt.place)
+		if got != tt.want {
+			t.Errorf("place %d: prize %d, want %d", tt.place, got, tt.want)
+		}
+		total += got
+	}
+	if total != tournament.PrizePool {
+		t.Errorf("paid %d, want the whole pool %d", total, tournament.PrizePool)
+	}
+}
+
+func TestSplitPrizes(t *testing.T) {
+	tournament := &models.Tournament{PrizePool: 1000, Payouts: models.PayoutStructure{40, 25, 15, 12, 8}}
+	tests := []struct {
+		name       string
+		firstPlace int
+		stacks     []int
+		wantPlaces []int
+		wantPrizes []int
+	}{
+		{
+			name:       "single bust",
+			firstPlace: 5,
+			stacks:     []int{300},
+			wantPlaces: []int{5},
+			wantPrizes: []int{80},
+		},
+		{
+			name:       "bigger stack places higher",
+			firstPlace: 4,
+			stacks:     []int{900, 300},
+			wantPlaces: []int{4, 5},
+			wantPrizes: []int{120, 80},
+		},
+		{
+			name:       "equal stacks split places 4 and 5",
+			firstPlace: 4,
+			stacks:     []int{300, 300},
+			wantPlaces: []int{4, 4},
+			wantPrizes: []int{100, 100},
+		},
+		{
+			name:       "tie below a bigger stack",
+			firstPlace: 3,
+			stacks:     []int{800, 200, 200},
+			wantPlaces: []int{3, 4, 4},
+			wantPrizes: []int{150, 100, 100},
+		},
+		{
+			name:       "three-way tie with remainder",
+			firstPlace: 2,
+			stacks:     []int{500, 500, 500},
+			wantPlaces: []int{2, 2, 2},
+			wantPrizes: []int{174, 173, 173}, // 250+150+120 = 520
+		},
+		{
+			name:       "tie across the bubble",
+			firstPlace: 5,
+			stacks:     []int{400, 400},
+			wantPlaces: []int{5, 5},
+			wantPrizes: []int{40, 40},
+		},
+		{
+			name:       "tie outside the money",
+			firstPlace: 6,
+			stacks:     []int{400, 400},
+			wantPlaces: []int{6, 6},
+			wantPrizes: []int{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending := make([]models.TournamentEntry, len(tt.stacks))
+			for i, stack := range tt.stacks {
+				pending[i].EliminatedStack = stack
+			}
+			splitPrizes(tournament, tt.firstPlace, pending)
+
+			paid, want := 0, 0
+			for i, entry := range pending {
+				if entry.Place != tt.wantPlaces[i] || entry.Prize != tt.wantPrizes[i] {
+					t.Errorf("entry %d: place %d prize %d, want place %d prize %d",
+						i, entry.Place, entry.Prize, tt.wantPlaces[i], tt.wantPrizes[i])
+				}
+				paid += entry.Prize
+				want += Prize(tournament, tt.firstPlace+i)
+			}
+			if paid != want {
+				t.Errorf("paid %d, want %d for places %d-%d", paid, want, tt.firstPlace, tt.firstPlace+len(pending)-1)
+			}
+		})
+	}
+}